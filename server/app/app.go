@@ -0,0 +1,151 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"server/config"
+	"server/database"
+	"server/email"
+	"server/graph"
+	"server/routes"
+	"server/sessionstore"
+	"server/token"
+)
+
+// App owns the long lived dependencies of the server and their lifecycle
+type App struct {
+	Config   *config.Config
+	Logger   *logrus.Logger
+	DB       *database.Database
+	Sessions sessionstore.SessionStore
+	Server   *http.Server
+}
+
+// New returns an application that has not been started yet
+func New(cfg *config.Config, logger *logrus.Logger) *App {
+	return &App{
+		Config: cfg,
+		Logger: logger,
+	}
+}
+
+// Start opens the database and the session store, applies pending migrations
+// when AutoMigrate is set, builds the router and starts listening.
+// It returns once the listener is bound; serving happens in the background
+// and any serve error is reported on the returned channel.
+func (a *App) Start() (<-chan error, error) {
+	db, err := database.NewDatabase(a.Config)
+	if err != nil {
+		return nil, err
+	}
+	a.DB = db
+
+	if a.Config.AutoMigrate {
+		applied, err := a.DB.Migrator.Up(context.Background())
+		if err != nil {
+			a.closeStores()
+			return nil, err
+		}
+		for _, m := range applied {
+			a.Logger.Infof("Applied migration %d_%s", m.Version, m.Name)
+		}
+	}
+
+	tokens, err := token.NewManager(a.Config)
+	if err != nil {
+		a.closeStores()
+		return nil, err
+	}
+	sessions, err := sessionstore.New(a.Config)
+	if err != nil {
+		a.closeStores()
+		return nil, err
+	}
+	a.Sessions = sessions
+	resolver := graph.NewResolver(a.Config, a.DB, tokens, email.New(a.Config), a.Sessions)
+
+	a.Server = &http.Server{
+		Addr:              ":" + a.Config.Port,
+		Handler:           routes.InitRouter(a.Logger, resolver),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	listener, err := net.Listen("tcp", a.Server.Addr)
+	if err != nil {
+		a.closeStores()
+		return nil, fmt.Errorf("failed to listen on %s: %w", a.Server.Addr, err)
+	}
+
+	a.Logger.Printf("Server starting on port %s", a.Config.Port)
+	a.Logger.Printf("GraphQL Playground available at http://localhost:%s/", a.Config.Port)
+
+	errCh := make(chan error, 1)
+	go func() {
+		if err := a.Server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			errCh <- err
+		}
+		close(errCh)
+	}()
+
+	return errCh, nil
+}
+
+// Run starts the application and blocks until ctx is cancelled (typically
+// by SIGINT / SIGTERM) or the server fails, then shuts everything down
+func (a *App) Run(ctx context.Context) error {
+	errCh, err := a.Start()
+	if err != nil {
+		return err
+	}
+
+	select {
+	case err := <-errCh:
+		a.closeStores()
+		return err
+	case <-ctx.Done():
+		a.Logger.Info("Shutdown signal received")
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), a.Config.ShutdownTimeout)
+	defer cancel()
+	return a.Shutdown(shutdownCtx)
+}
+
+// Shutdown stops accepting new connections, waits for in-flight requests to
+// finish until ctx expires and finally closes the database and session store
+func (a *App) Shutdown(ctx context.Context) error {
+	var shutdownErr error
+	if a.Server != nil {
+		if err := a.Server.Shutdown(ctx); err != nil {
+			shutdownErr = fmt.Errorf("failed to drain in-flight requests: %w", err)
+			a.Logger.WithError(err).Error("Forcing server close")
+			_ = a.Server.Close()
+		}
+	}
+
+	a.closeStores()
+	a.Logger.Info("Server stopped")
+	return shutdownErr
+}
+
+func (a *App) closeStores() {
+	if a.Sessions != nil {
+		if err := a.Sessions.Close(); err != nil {
+			a.Logger.WithError(err).Error("Failed to close session store")
+		}
+		a.Sessions = nil
+	}
+	if a.DB != nil {
+		if err := a.DB.Close(); err != nil {
+			a.Logger.WithError(err).Error("Failed to close database")
+		}
+		a.DB = nil
+	}
+}
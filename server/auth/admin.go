@@ -0,0 +1,39 @@
+package auth
+
+import (
+	"context"
+	"crypto/subtle"
+
+	"server/crypto"
+)
+
+// AdminSecretHeader carries the admin secret on back office requests
+const AdminSecretHeader = "x-authorizer-admin-secret"
+
+// AdminSessionKey is the session store key of an admin session token
+func AdminSessionKey(token string) string {
+	return "admin_session:" + crypto.HashToken(token)
+}
+
+// IsAdminSecret reports whether secret matches the configured admin secret.
+// An empty configured secret disables the admin API.
+func IsAdminSecret(configured, secret string) bool {
+	if configured == "" || secret == "" {
+		return false
+	}
+	// comparing the hashes keeps the time independent of the lengths
+	return subtle.ConstantTimeCompare([]byte(crypto.HashToken(configured)), []byte(crypto.HashToken(secret))) == 1
+}
+
+type adminKey struct{}
+
+// WithAdmin returns a copy of ctx marked as authenticated with the admin secret
+func WithAdmin(ctx context.Context) context.Context {
+	return context.WithValue(ctx, adminKey{}, true)
+}
+
+// IsAdmin reports whether the request authenticated with the admin secret
+func IsAdmin(ctx context.Context) bool {
+	admin, _ := ctx.Value(adminKey{}).(bool)
+	return admin
+}
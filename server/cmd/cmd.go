@@ -0,0 +1,38 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+
+	"server/config"
+)
+
+const usage = `Usage: server [command]
+
+Commands:
+  serve                   Start the HTTP server (default)
+  migrate up|down|status  Apply, roll back or list schema migrations
+  seed [-file path]       Load users and OAuth clients from a fixture file`
+
+// Execute runs the sub-command named by the first argument, starting the
+// server when none is given
+func Execute(args []string, cfg *config.Config, logger *logrus.Logger) error {
+	if len(args) == 0 {
+		return Serve(cfg, logger)
+	}
+
+	switch args[0] {
+	case "serve":
+		return Serve(cfg, logger)
+	case "migrate":
+		return Migrate(args[1:], cfg)
+	case "seed":
+		return Seed(args[1:], cfg)
+	case "help", "-h", "--help":
+		fmt.Println(usage)
+		return nil
+	default:
+		return fmt.Errorf("unknown command %q\n\n%s", args[0], usage)
+	}
+}
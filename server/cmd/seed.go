@@ -0,0 +1,39 @@
+package cmd
+
+import (
+	"context"
+	"flag"
+	"fmt"
+
+	"server/config"
+	"server/database"
+	"server/seed"
+)
+
+// Seed loads the fixture file given by -file into the configured database
+func Seed(args []string, cfg *config.Config) error {
+	flags := flag.NewFlagSet("seed", flag.ContinueOnError)
+	file := flags.String("file", "seeds/dev.yaml", "path to a .yaml or .json fixture file")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	fixtures, err := seed.Load(*file)
+	if err != nil {
+		return err
+	}
+
+	db, err := database.NewDatabase(cfg)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	res, err := seed.Run(context.Background(), cfg, db, fixtures)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Users: %d created, %d updated\n", res.UsersCreated, res.UsersUpdated)
+	fmt.Printf("OAuth clients: %d created, %d updated\n", res.OAuthClientsCreated, res.OAuthClientsUpdated)
+	return nil
+}
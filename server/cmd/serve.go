@@ -0,0 +1,22 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/sirupsen/logrus"
+
+	"server/app"
+	"server/config"
+)
+
+// Serve starts the server and blocks until SIGINT / SIGTERM triggers a
+// graceful shutdown
+func Serve(cfg *config.Config, logger *logrus.Logger) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	return app.New(cfg, logger).Run(ctx)
+}
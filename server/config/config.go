@@ -3,20 +3,137 @@ package config
 import (
 	"log"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
+
+	"server/constants"
 )
 
 type Config struct {
-	Port          string
-	DBType        string
-	DBName        string
-	DBHost        string
-	DBPort        string
-	DBUser        string
-	DBPassword    string
+	Port            string
+	ShutdownTimeout time.Duration
+	DBType          string
+	DBName          string
+	DBHost          string
+	DBPort          string
+	DBUser          string
+	DBPassword      string
+	// DBSSLMode is one of disable, allow, prefer, require, verify-ca or verify-full
+	DBSSLMode string
+	// DBCert, DBCertKey and DBCACert hold PEM content or paths to PEM files
+	DBCert            string
+	DBCertKey         string
+	DBCACert          string
+	DBMaxOpenConns    int
+	DBMaxIdleConns    int
+	DBConnMaxLifetime time.Duration
+	DBConnMaxIdleTime time.Duration
+	// AutoMigrate applies pending migrations when the server starts
+	AutoMigrate   bool
 	MongoURI      string
 	MongoDatabase string
+	// RedisURL selects the Redis session store, sessions are kept in memory
+	// when it is empty or DisableRedisForEnv is set
+	RedisURL           string
+	DisableRedisForEnv bool
+
+	// AuthorizerURL is the public URL of this server, used as token issuer
+	AuthorizerURL string
+	// JwtType is the signing algorithm, e.g. HS256
+	JwtType   string
+	JwtSecret string
+	// JwtPrivateKey and JwtPublicKey are PEM encoded keys for the RS* and ES* algorithms
+	JwtPrivateKey string
+	JwtPublicKey  string
+	// JwtRoleClaim is the name of the claim carrying the roles of the user
+	JwtRoleClaim string
+	// JWK holds extra public keys published in the JWKS, as a JWK or JWK Set
+	JWK string
+	// AccessTokenExpiryTime is the lifetime of access tokens
+	AccessTokenExpiryTime time.Duration
+	// RefreshTokenExpiryTime is the lifetime of refresh tokens
+	RefreshTokenExpiryTime time.Duration
+	// Roles lists every role that can be assigned to a user
+	Roles []string
+	// DefaultRoles are assigned to new users
+	DefaultRoles []string
+	// ProtectedRoles can only be assigned by admins, never requested by users.
+	// The admin role is protected even when it is not listed.
+	ProtectedRoles             []string
+	DisableSignUp              bool
+	DisableBasicAuthentication bool
+	// AppCookieSecure marks the session cookie Secure, disable it to serve
+	// the cookie over plain http
+	AppCookieSecure bool
+	// AdminSecret grants access to the admin API, which is disabled when empty
+	AdminSecret       string
+	AdminCookieSecure bool
+	// DisableStrongPassword turns the password policy below off
+	DisableStrongPassword bool
+	// PasswordMinLength counts characters
+	PasswordMinLength int
+	// PasswordMaxLength counts bytes, which is what bcrypt limits
+	PasswordMaxLength        int
+	PasswordRequireLowercase bool
+	PasswordRequireUppercase bool
+	PasswordRequireDigit     bool
+	PasswordRequireSpecial   bool
+	// EnforceMultiFactorAuthentication makes every password login ask for a
+	// TOTP code, users without an authenticator app enrol at their next login
+	EnforceMultiFactorAuthentication bool
+	// DisableMultiFactorAuthentication and DisableTOTPLogin stop asking for
+	// TOTP codes, the authenticators users enrolled are kept
+	DisableMultiFactorAuthentication bool
+	DisableTOTPLogin                 bool
+	// OrganizationName is the issuer shown by authenticator apps
+	OrganizationName string
+
+	// AppURL is the URL of the frontend, used to build links sent by email
+	AppURL string
+	// VerifyEmailURL is the frontend page that calls the verifyEmail mutation
+	VerifyEmailURL              string
+	VerificationTokenExpiryTime time.Duration
+	// ResetPasswordURL is the frontend page that calls the resetPassword mutation
+	ResetPasswordURL             string
+	ResetPasswordTokenExpiryTime time.Duration
+	DisableMagicLinkLogin        bool
+	MagicLinkExpiryTime          time.Duration
+	// LoginURL is the frontend login page /authorize sends anonymous users to
+	LoginURL string
+	// DefaultAuthorizeResponseType and DefaultAuthorizeResponseMode apply to
+	// /authorize requests that do not set response_type or response_mode. An
+	// empty mode picks query for code and fragment for token.
+	DefaultAuthorizeResponseType string
+	DefaultAuthorizeResponseMode string
+	// AllowedOrigins lists the origins redirect URIs may point to besides
+	// APP_URL and AUTHORIZER_URL, "*" allows any origin
+	AllowedOrigins           []string
+	DisableEmailVerification bool
+	IsEmailServiceEnabled    bool
+	SMTPHost                 string
+	SMTPPort                 int
+	SMTPUsername             string
+	SMTPPassword             string
+	SMTPLocalName            string
+	SenderEmail              string
+	SenderName               string
+
+	// OAuthProviders holds the client credentials of the login providers
+	// that are enabled, keyed by provider name
+	OAuthProviders map[string]OAuthProviderCredentials
+	// MicrosoftTenantID restricts Microsoft logins to a directory, common
+	// accepts any work, school or personal account
+	MicrosoftTenantID string
+}
+
+// OAuthProviderCredentials are the client credentials registered with a
+// login provider
+type OAuthProviderCredentials struct {
+	ClientID     string
+	ClientSecret string
 }
 
 func LoadConfig() *Config {
@@ -24,17 +141,107 @@ func LoadConfig() *Config {
 		log.Println("No .env file found")
 	}
 
-	return &Config{
-		Port:          getEnv("PORT", "8080"),
-		DBType:        getEnv("DB_TYPE", "sqlite"),
-		DBName:        getEnv("DB_NAME", "app.db"),
-		DBHost:        getEnv("DB_HOST", "localhost"),
-		DBPort:        getEnv("DB_PORT", "5432"),
-		DBUser:        getEnv("DB_USER", ""),
-		DBPassword:    getEnv("DB_PASSWORD", ""),
-		MongoURI:      getEnv("MONGO_URI", "mongodb://localhost:27017"),
-		MongoDatabase: getEnv("MONGO_DATABASE", "myapp"),
+	cfg := &Config{
+		Port:               getEnv("PORT", "8080"),
+		ShutdownTimeout:    getEnvDuration("SHUTDOWN_TIMEOUT", 15*time.Second),
+		DBType:             getEnv("DB_TYPE", "sqlite"),
+		DBName:             getEnv("DB_NAME", "app.db"),
+		DBHost:             getEnv("DB_HOST", "localhost"),
+		DBPort:             getEnv("DB_PORT", "5432"),
+		DBUser:             getEnv("DB_USER", ""),
+		DBPassword:         getEnv("DB_PASSWORD", ""),
+		DBSSLMode:          getEnv("DB_SSL_MODE", "disable"),
+		DBCert:             getEnv(constants.EnvKeyDatabaseCert, ""),
+		DBCertKey:          getEnv(constants.EnvKeyDatabaseCertKey, ""),
+		DBCACert:           getEnv(constants.EnvKeyDatabaseCACert, ""),
+		DBMaxOpenConns:     getEnvInt("DB_MAX_OPEN_CONNS", 25),
+		DBMaxIdleConns:     getEnvInt("DB_MAX_IDLE_CONNS", 5),
+		DBConnMaxLifetime:  getEnvDuration("DB_CONN_MAX_LIFETIME", 30*time.Minute),
+		DBConnMaxIdleTime:  getEnvDuration("DB_CONN_MAX_IDLE_TIME", 5*time.Minute),
+		AutoMigrate:        getEnvBool("AUTO_MIGRATE", false),
+		MongoURI:           getEnv("MONGO_URI", "mongodb://localhost:27017"),
+		MongoDatabase:      getEnv("MONGO_DATABASE", "myapp"),
+		RedisURL:           getEnv(constants.EnvKeyRedisURL, ""),
+		DisableRedisForEnv: getEnvBool(constants.EnvKeyDisableRedisForEnv, false),
+
+		AuthorizerURL:              getEnv(constants.EnvKeyAuthorizerURL, "http://localhost:8080"),
+		JwtType:                    getEnv(constants.EnvKeyJwtType, "HS256"),
+		JwtSecret:                  getEnv(constants.EnvKeyJwtSecret, ""),
+		JwtPrivateKey:              getEnv(constants.EnvKeyJwtPrivateKey, ""),
+		JwtPublicKey:               getEnv(constants.EnvKeyJwtPublicKey, ""),
+		JwtRoleClaim:               getEnv(constants.EnvKeyJwtRoleClaim, "roles"),
+		JWK:                        getEnv(constants.EnvKeyJWK, ""),
+		AccessTokenExpiryTime:      getEnvDuration(constants.EnvKeyAccessTokenExpiryTime, 30*time.Minute),
+		RefreshTokenExpiryTime:     getEnvDuration("REFRESH_TOKEN_EXPIRY_TIME", 30*24*time.Hour),
+		Roles:                      getEnvSlice(constants.EnvKeyRoles, []string{"user"}),
+		DefaultRoles:               getEnvSlice(constants.EnvKeyDefaultRoles, []string{"user"}),
+		ProtectedRoles:             getEnvSlice(constants.EnvKeyProtectedRoles, nil),
+		DisableSignUp:              getEnvBool(constants.EnvKeyDisableSignUp, false),
+		DisableBasicAuthentication: getEnvBool(constants.EnvKeyDisableBasicAuthentication, false),
+		AppCookieSecure:            getEnvBool(constants.EnvKeyAppCookieSecure, true),
+		AdminSecret:                getEnv(constants.EnvKeyAdminSecret, ""),
+		AdminCookieSecure:          getEnvBool(constants.EnvKeyAdminCookieSecure, true),
+		DisableStrongPassword:      getEnvBool(constants.EnvKeyDisableStrongPassword, false),
+		PasswordMinLength:          getEnvInt("PASSWORD_MIN_LENGTH", 8),
+		PasswordMaxLength:          getEnvInt("PASSWORD_MAX_LENGTH", 72),
+		PasswordRequireLowercase:   getEnvBool("PASSWORD_REQUIRE_LOWERCASE", true),
+		PasswordRequireUppercase:   getEnvBool("PASSWORD_REQUIRE_UPPERCASE", true),
+		PasswordRequireDigit:       getEnvBool("PASSWORD_REQUIRE_DIGIT", true),
+		PasswordRequireSpecial:     getEnvBool("PASSWORD_REQUIRE_SPECIAL", true),
 	}
+
+	cfg.AppURL = strings.TrimSuffix(getEnv(constants.EnvKeyAppURL, cfg.AuthorizerURL), "/")
+	cfg.VerifyEmailURL = getEnv("VERIFY_EMAIL_URL", cfg.AppURL+"/verify-email")
+	cfg.VerificationTokenExpiryTime = getEnvDuration("VERIFICATION_TOKEN_EXPIRY_TIME", 24*time.Hour)
+	cfg.ResetPasswordURL = getEnv(constants.EnvKeyResetPasswordURL, cfg.AppURL+"/reset-password")
+	cfg.ResetPasswordTokenExpiryTime = getEnvDuration("RESET_PASSWORD_TOKEN_EXPIRY_TIME", time.Hour)
+	cfg.DisableMagicLinkLogin = getEnvBool(constants.EnvKeyDisableMagicLinkLogin, false)
+	cfg.MagicLinkExpiryTime = getEnvDuration("MAGIC_LINK_EXPIRY_TIME", 15*time.Minute)
+	cfg.LoginURL = getEnv("LOGIN_URL", cfg.AppURL+"/login")
+	cfg.DefaultAuthorizeResponseType = getEnv(constants.EnvKeyDefaultAuthorizeResponseType, "code")
+	cfg.DefaultAuthorizeResponseMode = getEnv(constants.EnvKeyDefaultAuthorizeResponseMode, "")
+	cfg.AllowedOrigins = getEnvSlice(constants.EnvKeyAllowedOrigins, nil)
+	cfg.DisableEmailVerification = getEnvBool(constants.EnvKeyDisableEmailVerification, false)
+	cfg.IsEmailServiceEnabled = getEnvBool(constants.EnvKeyIsEmailServiceEnabled, false)
+	cfg.SMTPHost = getEnv(constants.EnvKeySmtpHost, "")
+	cfg.SMTPPort = getEnvInt(constants.EnvKeySmtpPort, 587)
+	cfg.SMTPUsername = getEnv(constants.EnvKeySmtpUsername, "")
+	cfg.SMTPPassword = getEnv(constants.EnvKeySmtpPassword, "")
+	cfg.SMTPLocalName = getEnv(constants.EnvKeySmtpLocalName, "")
+	cfg.SenderEmail = getEnv(constants.EnvKeySenderEmail, "")
+	cfg.SenderName = getEnv(constants.EnvKeySenderName, "Account-Verse")
+	cfg.EnforceMultiFactorAuthentication = getEnvBool(constants.EnvKeyEnforceMultiFactorAuthentication, false)
+	cfg.DisableMultiFactorAuthentication = getEnvBool(constants.EnvKeyDisableMultiFactorAuthentication, false)
+	cfg.DisableTOTPLogin = getEnvBool(constants.EnvKeyDisableTOTPLogin, false)
+	cfg.OrganizationName = getEnv(constants.EnvKeyOrganizationName, "Account-Verse")
+	cfg.OAuthProviders = loadOAuthProviders()
+	cfg.MicrosoftTenantID = getEnv(constants.EnvKeyMicrosoftActiveDirectoryTenantID, "common")
+
+	return cfg
+}
+
+// loadOAuthProviders returns the credentials of the providers that have a
+// client ID set
+func loadOAuthProviders() map[string]OAuthProviderCredentials {
+	keys := map[string][2]string{
+		constants.OAuthProviderGoogle:    {constants.EnvKeyGoogleClientID, constants.EnvKeyGoogleClientSecret},
+		constants.OAuthProviderGithub:    {constants.EnvKeyGithubClientID, constants.EnvKeyGithubClientSecret},
+		constants.OAuthProviderFacebook:  {constants.EnvKeyFacebookClientID, constants.EnvKeyFacebookClientSecret},
+		constants.OAuthProviderLinkedIn:  {constants.EnvKeyLinkedInClientID, constants.EnvKeyLinkedInClientSecret},
+		constants.OAuthProviderApple:     {constants.EnvKeyAppleClientID, constants.EnvKeyAppleClientSecret},
+		constants.OAuthProviderDiscord:   {constants.EnvKeyDiscordClientID, constants.EnvKeyDiscordClientSecret},
+		constants.OAuthProviderTwitter:   {constants.EnvKeyTwitterClientID, constants.EnvKeyTwitterClientSecret},
+		constants.OAuthProviderMicrosoft: {constants.EnvKeyMicrosoftClientID, constants.EnvKeyMicrosoftClientSecret},
+		constants.OAuthProviderTwitch:    {constants.EnvKeyTwitchClientID, constants.EnvKeyTwitchClientSecret},
+		constants.OAuthProviderRoblox:    {constants.EnvKeyRobloxClientID, constants.EnvKeyRobloxClientSecret},
+	}
+	providers := map[string]OAuthProviderCredentials{}
+	for name, key := range keys {
+		if clientID := getEnv(key[0], ""); clientID != "" {
+			providers[name] = OAuthProviderCredentials{ClientID: clientID, ClientSecret: getEnv(key[1], "")}
+		}
+	}
+	return providers
 }
 
 func getEnv(key, defaultValue string) string {
@@ -43,3 +250,63 @@ func getEnv(key, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+// getEnvSlice parses a comma separated list, falling back to the default
+// when the variable is unset or holds no value
+func getEnvSlice(key string, defaultValue []string) []string {
+	var res []string
+	for _, v := range strings.Split(os.Getenv(key), ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			res = append(res, v)
+		}
+	}
+	if len(res) == 0 {
+		return defaultValue
+	}
+	return res
+}
+
+// getEnvBool parses values such as "true", "1" or "false", falling back to
+// the default when the variable is unset or invalid
+func getEnvBool(key string, defaultValue bool) bool {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		log.Printf("Invalid boolean %q for %s, using %t", value, key, defaultValue)
+		return defaultValue
+	}
+	return b
+}
+
+// getEnvInt parses an integer, falling back to the default when the
+// variable is unset or invalid
+func getEnvInt(key string, defaultValue int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	i, err := strconv.Atoi(value)
+	if err != nil {
+		log.Printf("Invalid integer %q for %s, using %d", value, key, defaultValue)
+		return defaultValue
+	}
+	return i
+}
+
+// getEnvDuration parses values such as "30s" or "5m", falling back to the
+// default when the variable is unset or invalid
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		log.Printf("Invalid duration %q for %s, using %s", value, key, defaultValue)
+		return defaultValue
+	}
+	return d
+}
@@ -0,0 +1,18 @@
+package constants
+
+const (
+	// ErrCodeBadUserInput is the GraphQL error code for invalid input
+	ErrCodeBadUserInput = "BAD_USER_INPUT"
+	// ErrCodeUnauthenticated is the GraphQL error code for missing or invalid credentials
+	ErrCodeUnauthenticated = "UNAUTHENTICATED"
+	// ErrCodeForbidden is the GraphQL error code for disabled or forbidden operations
+	ErrCodeForbidden = "FORBIDDEN"
+	// ErrCodeEmailNotVerified is the GraphQL error code for logins of unverified users
+	ErrCodeEmailNotVerified = "EMAIL_NOT_VERIFIED"
+	// ErrCodeNotFound is the GraphQL error code for missing resources
+	ErrCodeNotFound = "NOT_FOUND"
+	// ErrCodeConflict is the GraphQL error code for unique constraint violations
+	ErrCodeConflict = "CONFLICT"
+	// ErrCodeInternal is the GraphQL error code for unexpected failures
+	ErrCodeInternal = "INTERNAL_SERVER_ERROR"
+)
@@ -0,0 +1,24 @@
+package constants
+
+const (
+	// OAuthProviderGoogle is the google login provider
+	OAuthProviderGoogle = "google"
+	// OAuthProviderGithub is the github login provider
+	OAuthProviderGithub = "github"
+	// OAuthProviderFacebook is the facebook login provider
+	OAuthProviderFacebook = "facebook"
+	// OAuthProviderLinkedIn is the linkedin login provider
+	OAuthProviderLinkedIn = "linkedin"
+	// OAuthProviderApple is the apple login provider
+	OAuthProviderApple = "apple"
+	// OAuthProviderDiscord is the discord login provider
+	OAuthProviderDiscord = "discord"
+	// OAuthProviderTwitter is the twitter login provider
+	OAuthProviderTwitter = "twitter"
+	// OAuthProviderMicrosoft is the microsoft login provider
+	OAuthProviderMicrosoft = "microsoft"
+	// OAuthProviderTwitch is the twitch login provider
+	OAuthProviderTwitch = "twitch"
+	// OAuthProviderRoblox is the roblox login provider
+	OAuthProviderRoblox = "roblox"
+)
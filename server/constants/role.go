@@ -0,0 +1,5 @@
+package constants
+
+// RoleAdmin is the role granting the @isAdmin operations. It is always
+// protected: users cannot request it, only admins assign it.
+const RoleAdmin = "admin"
@@ -0,0 +1,100 @@
+package cookie
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"server/config"
+)
+
+// SessionName is the name of the cookie holding the refresh token of the
+// browser session
+const SessionName = "account_verse_session"
+
+// AdminName is the name of the cookie holding the admin session token
+const AdminName = "account_verse_admin"
+
+// OAuthStateName is the name of the cookie binding a login through an
+// external provider to the browser that started it
+const OAuthStateName = "account_verse_oauth_state"
+
+// SetSession stores the refresh token in an HttpOnly cookie. It is
+// SameSite=Lax so other sites cannot send it along their requests.
+func SetSession(c *gin.Context, cfg *config.Config, refreshToken string) {
+	set(c, SessionName, refreshToken, int(cfg.RefreshTokenExpiryTime/time.Second), cfg.AppCookieSecure, http.SameSiteLaxMode)
+}
+
+// DeleteSession expires the session cookie
+func DeleteSession(c *gin.Context, cfg *config.Config) {
+	set(c, SessionName, "", -1, cfg.AppCookieSecure, http.SameSiteLaxMode)
+}
+
+// GetSession returns the refresh token of the session cookie, if any
+func GetSession(c *gin.Context) string {
+	return get(c, SessionName)
+}
+
+// SetAdmin stores the admin session token, Secure unless ADMIN_COOKIE_SECURE
+// is disabled. It is SameSite=Strict, the admin API is never reached by
+// following a link.
+func SetAdmin(c *gin.Context, cfg *config.Config, value string, ttl time.Duration) {
+	set(c, AdminName, value, int(ttl/time.Second), cfg.AdminCookieSecure, http.SameSiteStrictMode)
+}
+
+// DeleteAdmin expires the admin cookie
+func DeleteAdmin(c *gin.Context, cfg *config.Config) {
+	set(c, AdminName, "", -1, cfg.AdminCookieSecure, http.SameSiteStrictMode)
+}
+
+// GetAdmin returns the admin session token of the admin cookie, if any
+func GetAdmin(c *gin.Context) string {
+	return get(c, AdminName)
+}
+
+// SetOAuthState stores the state of a login through an external provider.
+// Secure cookies use SameSite=None so the providers posting their response
+// back get it.
+func SetOAuthState(c *gin.Context, cfg *config.Config, state string, ttl time.Duration) {
+	set(c, OAuthStateName, state, int(ttl/time.Second), cfg.AppCookieSecure, crossSite(cfg.AppCookieSecure))
+}
+
+// DeleteOAuthState expires the login state cookie
+func DeleteOAuthState(c *gin.Context, cfg *config.Config) {
+	set(c, OAuthStateName, "", -1, cfg.AppCookieSecure, crossSite(cfg.AppCookieSecure))
+}
+
+// GetOAuthState returns the state of the login state cookie, if any
+func GetOAuthState(c *gin.Context) string {
+	return get(c, OAuthStateName)
+}
+
+func get(c *gin.Context, name string) string {
+	value, err := c.Cookie(name)
+	if err != nil {
+		return ""
+	}
+	return value
+}
+
+// crossSite returns SameSite=None, which browsers only accept on Secure
+// cookies, falling back to Lax
+func crossSite(secure bool) http.SameSite {
+	if secure {
+		return http.SameSiteNoneMode
+	}
+	return http.SameSiteLaxMode
+}
+
+func set(c *gin.Context, name, value string, maxAge int, secure bool, sameSite http.SameSite) {
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     "/",
+		MaxAge:   maxAge,
+		Secure:   secure,
+		HttpOnly: true,
+		SameSite: sameSite,
+	})
+}
@@ -0,0 +1,38 @@
+package crypto
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+)
+
+// GenerateToken returns a random URL safe token with 256 bits of entropy
+func GenerateToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// GenerateRecoveryCode returns a random code with 60 bits of entropy, short
+// enough to be written down, e.g. k3v9-q2xm-7hfp
+func GenerateRecoveryCode() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	code := strings.ToLower(base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(b))[:12]
+	return code[:4] + "-" + code[4:8] + "-" + code[8:], nil
+}
+
+// HashToken returns the hex encoded SHA-256 of a high entropy secret such as
+// a client secret or a one time token, so it can be stored and looked up
+// without keeping the plain value
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
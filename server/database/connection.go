@@ -2,7 +2,7 @@ package database
 
 import (
 	"context"
-	"log"
+	"fmt"
 	"server/config"
 	"server/database/mongodb"
 	"server/database/sql"
@@ -11,13 +11,26 @@ import (
 	"gorm.io/gorm"
 )
 
+// Database holds the raw connection of the configured backend together with
+// the repositories built on top of it. The embedded repositories make
+// *Database satisfy Repository, so callers never need to branch on Type.
 type Database struct {
-	Type  string
-	SQL   *gorm.DB
-	Mongo *mongo.Database
+	UserRepository
+	OAuthClientRepository
+	VerificationRequestRepository
+	OIDCProviderRepository
+
+	Type     string
+	SQL      *gorm.DB
+	Mongo    *mongo.Database
+	Migrator Migrator
 }
 
-func NewDatabase(cfg *config.Config) *Database {
+var _ Repository = (*Database)(nil)
+
+// NewDatabase connects to the backend selected by cfg.DBType and wires the
+// matching repositories
+func NewDatabase(cfg *config.Config) (*Database, error) {
 	db := &Database{
 		Type: cfg.DBType,
 	}
@@ -26,22 +39,33 @@ func NewDatabase(cfg *config.Config) *Database {
 	case "sqlite", "postgres", "mysql":
 		sqlDB, err := sql.NewSQLConnection(cfg)
 		if err != nil {
-			log.Fatalf("Failed to connect to SQL database: %v", err)
+			return nil, fmt.Errorf("failed to connect to SQL database: %w", err)
 		}
 		db.SQL = sqlDB
+		db.UserRepository = sql.NewUserRepository(sqlDB)
+		db.OAuthClientRepository = sql.NewOAuthClientRepository(sqlDB)
+		db.VerificationRequestRepository = sql.NewVerificationRequestRepository(sqlDB)
+		db.OIDCProviderRepository = sql.NewOIDCProviderRepository(sqlDB)
+		db.Migrator = sql.NewMigrator(sqlDB)
 	case "mongodb", "mongo":
 		mongoDB, err := mongodb.NewMongoConnection(cfg)
 		if err != nil {
-			log.Fatalf("Failed to connect to MongoDB: %v", err)
+			return nil, fmt.Errorf("failed to connect to MongoDB: %w", err)
 		}
 		db.Mongo = mongoDB
+		db.UserRepository = mongodb.NewUserRepository(mongoDB)
+		db.OAuthClientRepository = mongodb.NewOAuthClientRepository(mongoDB)
+		db.VerificationRequestRepository = mongodb.NewVerificationRequestRepository(mongoDB)
+		db.OIDCProviderRepository = mongodb.NewOIDCProviderRepository(mongoDB)
+		db.Migrator = mongodb.NewMigrator(mongoDB)
 	default:
-		log.Fatalf("Unsupported database type: %s", cfg.DBType)
+		return nil, fmt.Errorf("unsupported database type: %s", cfg.DBType)
 	}
 
-	return db
+	return db, nil
 }
 
+// Close releases the underlying connection
 func (db *Database) Close() error {
 	if db.SQL != nil {
 		sqlDB, err := db.SQL.DB()
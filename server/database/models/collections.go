@@ -0,0 +1,15 @@
+package models
+
+// Table / collection names shared by every storage backend
+const (
+	// CollectionUsers is the table / collection holding users
+	CollectionUsers = "users"
+	// CollectionOAuthClients is the table / collection holding OAuth clients
+	CollectionOAuthClients = "oauth_clients"
+	// CollectionVerificationRequests is the table / collection holding email tokens
+	CollectionVerificationRequests = "verification_requests"
+	// CollectionOIDCProviders is the table / collection holding upstream OpenID Connect providers
+	CollectionOIDCProviders = "oidc_providers"
+	// CollectionSchemaMigrations is the table / collection tracking applied migrations
+	CollectionSchemaMigrations = "schema_migrations"
+)
@@ -0,0 +1,10 @@
+package models
+
+import "errors"
+
+var (
+	// ErrNotFound is returned by repositories when no record matches the lookup
+	ErrNotFound = errors.New("record not found")
+	// ErrDuplicate is returned by repositories when a unique constraint is violated
+	ErrDuplicate = errors.New("record already exists")
+)
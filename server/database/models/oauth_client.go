@@ -0,0 +1,36 @@
+package models
+
+// OAuthClient is an application allowed to authenticate users through the
+// OAuth endpoints. Public clients (SPAs, mobile apps) have no secret and must
+// use PKCE.
+type OAuthClient struct {
+	ID       string `gorm:"primaryKey;type:char(36)" json:"id" bson:"_id"`
+	ClientID string `gorm:"type:varchar(256);uniqueIndex" json:"client_id" bson:"client_id"`
+	// ClientSecret is the SHA-256 hash of the secret, see crypto.HashToken
+	ClientSecret string `gorm:"type:varchar(256)" json:"-" bson:"client_secret"`
+	Name         string `gorm:"type:varchar(256)" json:"name" bson:"name"`
+	// RedirectURIs is the comma separated list of allowed redirect URIs
+	RedirectURIs string `gorm:"type:text" json:"redirect_uris" bson:"redirect_uris"`
+	CreatedAt    int64  `gorm:"autoCreateTime" json:"created_at" bson:"created_at"`
+	UpdatedAt    int64  `gorm:"autoUpdateTime" json:"updated_at" bson:"updated_at"`
+}
+
+// TableName overrides the table name used by gorm
+func (OAuthClient) TableName() string {
+	return CollectionOAuthClients
+}
+
+// IsPublic reports whether the client has no secret
+func (c *OAuthClient) IsPublic() bool {
+	return c.ClientSecret == ""
+}
+
+// RedirectURIList returns the allowed redirect URIs as a slice
+func (c *OAuthClient) RedirectURIList() []string {
+	return splitList(c.RedirectURIs)
+}
+
+// SetRedirectURIs replaces the allowed redirect URIs
+func (c *OAuthClient) SetRedirectURIs(uris []string) {
+	c.RedirectURIs = joinList(uris)
+}
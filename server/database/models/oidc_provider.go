@@ -0,0 +1,61 @@
+package models
+
+import "server/graph/model"
+
+// OIDCProvider is an upstream OpenID Connect identity provider registered at
+// runtime, users log in with it through /oauth_login/<name>
+type OIDCProvider struct {
+	ID string `gorm:"primaryKey;type:char(36)" json:"id" bson:"_id"`
+	// Name identifies the provider in the login routes
+	Name string `gorm:"type:varchar(64);uniqueIndex" json:"name" bson:"name"`
+	// Issuer is the issuer URL, its discovery document is served at
+	// /.well-known/openid-configuration
+	Issuer   string `gorm:"type:varchar(512)" json:"issuer" bson:"issuer"`
+	ClientID string `gorm:"type:varchar(256)" json:"client_id" bson:"client_id"`
+	// ClientSecret authenticates this server to the provider so it is kept
+	// in clear
+	ClientSecret string `gorm:"type:text" json:"-" bson:"client_secret"`
+	// Scopes is the comma separated list of scopes requested
+	Scopes string `gorm:"type:text" json:"scopes" bson:"scopes"`
+	// EmailClaim, EmailVerifiedClaim and NameClaim name the claims the profile
+	// is read from, the standard claims are used when they are empty
+	EmailClaim         string `gorm:"type:varchar(256)" json:"email_claim" bson:"email_claim"`
+	EmailVerifiedClaim string `gorm:"type:varchar(256)" json:"email_verified_claim" bson:"email_verified_claim"`
+	NameClaim          string `gorm:"type:varchar(256)" json:"name_claim" bson:"name_claim"`
+	CreatedAt          int64  `gorm:"autoCreateTime" json:"created_at" bson:"created_at"`
+	UpdatedAt          int64  `gorm:"autoUpdateTime" json:"updated_at" bson:"updated_at"`
+}
+
+// TableName overrides the table name used by gorm
+func (OIDCProvider) TableName() string {
+	return CollectionOIDCProviders
+}
+
+// ScopeList returns the requested scopes as a slice
+func (p *OIDCProvider) ScopeList() []string {
+	return splitList(p.Scopes)
+}
+
+// SetScopes replaces the requested scopes
+func (p *OIDCProvider) SetScopes(scopes []string) {
+	p.Scopes = joinList(scopes)
+}
+
+// AsAPIOIDCProvider converts the storage provider into the GraphQL type,
+// leaving the client secret out
+func (p *OIDCProvider) AsAPIOIDCProvider() *model.OIDCProvider {
+	return &model.OIDCProvider{
+		ID:       p.ID,
+		Name:     p.Name,
+		Issuer:   p.Issuer,
+		ClientID: p.ClientID,
+		Scopes:   p.ScopeList(),
+		ClaimMapping: &model.OIDCClaimMapping{
+			Email:         p.EmailClaim,
+			EmailVerified: p.EmailVerifiedClaim,
+			Name:          p.NameClaim,
+		},
+		CreatedAt: int(p.CreatedAt),
+		UpdatedAt: int(p.UpdatedAt),
+	}
+}
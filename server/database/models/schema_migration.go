@@ -0,0 +1,21 @@
+package models
+
+// SchemaMigration records a migration that has been applied to the database
+type SchemaMigration struct {
+	Version   int64  `gorm:"primaryKey;autoIncrement:false" json:"version" bson:"_id"`
+	Name      string `gorm:"type:varchar(256)" json:"name" bson:"name"`
+	AppliedAt int64  `json:"applied_at" bson:"applied_at"`
+}
+
+// TableName overrides the table name used by gorm
+func (SchemaMigration) TableName() string {
+	return CollectionSchemaMigrations
+}
+
+// MigrationStatus describes a known migration and whether it has been applied
+type MigrationStatus struct {
+	Version   int64
+	Name      string
+	Applied   bool
+	AppliedAt int64
+}
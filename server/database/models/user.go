@@ -0,0 +1,144 @@
+package models
+
+import (
+	"slices"
+	"strings"
+	"time"
+
+	"server/graph/model"
+)
+
+// User is the storage representation of a user shared by the SQL and MongoDB backends
+type User struct {
+	ID    string `gorm:"primaryKey;type:char(36)" json:"id" bson:"_id"`
+	Name  string `gorm:"type:varchar(256)" json:"name" bson:"name"`
+	Email string `gorm:"type:varchar(256);uniqueIndex" json:"email" bson:"email"`
+	// Password is the bcrypt hash of the password, empty for passwordless users
+	Password string `gorm:"type:text" json:"-" bson:"password"`
+	// EmailVerifiedAt is the unix time the email was verified, nil until then
+	EmailVerifiedAt *int64 `json:"email_verified_at" bson:"email_verified_at"`
+	// Roles is the comma separated list of roles granted to the user
+	Roles string `gorm:"type:text" json:"roles" bson:"roles"`
+	// SessionsRevokedAt is the unix time the sessions of the user were last
+	// revoked, tokens issued before it are no longer accepted
+	SessionsRevokedAt *int64 `json:"sessions_revoked_at" bson:"sessions_revoked_at"`
+	// TOTPSecret is the base32 secret shared with the authenticator app
+	TOTPSecret string `gorm:"type:text" json:"-" bson:"totp_secret"`
+	// TOTPVerifiedAt is the unix time the user confirmed the authenticator
+	// app with a first code, logins ask for a code once it is set
+	TOTPVerifiedAt *int64 `json:"totp_verified_at" bson:"totp_verified_at"`
+	// RecoveryCodes is the comma separated list of the hashes of the recovery
+	// codes that were not used yet
+	RecoveryCodes string `gorm:"type:text" json:"-" bson:"recovery_codes"`
+	CreatedAt     int64  `gorm:"autoCreateTime" json:"created_at" bson:"created_at"`
+	UpdatedAt     int64  `gorm:"autoUpdateTime" json:"updated_at" bson:"updated_at"`
+}
+
+// TableName overrides the table name used by gorm
+func (User) TableName() string {
+	return CollectionUsers
+}
+
+// AsAPIUser converts the storage user into the GraphQL user type
+func (u *User) AsAPIUser() *model.User {
+	return &model.User{
+		ID:            u.ID,
+		Name:          u.Name,
+		Email:         u.Email,
+		EmailVerified: u.IsEmailVerified(),
+		Roles:         u.RoleList(),
+		MfaEnabled:    u.IsTOTPEnabled(),
+	}
+}
+
+// IsEmailVerified reports whether the user confirmed their email address
+func (u *User) IsEmailVerified() bool {
+	return u.EmailVerifiedAt != nil
+}
+
+// RevokeSessions invalidates every token issued to the user so far
+func (u *User) RevokeSessions() {
+	now := time.Now().Unix()
+	u.SessionsRevokedAt = &now
+}
+
+// IsSessionRevoked reports whether a token issued at issuedAt was revoked.
+// Revocation has a one second granularity, matching the iat claim.
+func (u *User) IsSessionRevoked(issuedAt time.Time) bool {
+	return u.SessionsRevokedAt != nil && issuedAt.Unix() < *u.SessionsRevokedAt
+}
+
+// IsTOTPEnabled reports whether the user enrolled an authenticator app
+func (u *User) IsTOTPEnabled() bool {
+	return u.TOTPVerifiedAt != nil
+}
+
+// EnableTOTP stores the confirmed secret of the authenticator app and the
+// hashes of new recovery codes
+func (u *User) EnableTOTP(secret string, recoveryCodeHashes []string) {
+	now := time.Now().Unix()
+	u.TOTPSecret = secret
+	u.TOTPVerifiedAt = &now
+	u.RecoveryCodes = joinList(recoveryCodeHashes)
+}
+
+// DisableTOTP removes the authenticator app and the recovery codes
+func (u *User) DisableTOTP() {
+	u.TOTPSecret = ""
+	u.TOTPVerifiedAt = nil
+	u.RecoveryCodes = ""
+}
+
+// HasRecoveryCode reports whether the recovery code with the given hash is
+// unused
+func (u *User) HasRecoveryCode(hash string) bool {
+	return slices.Contains(splitList(u.RecoveryCodes), hash)
+}
+
+// UseRecoveryCode removes the recovery code with the given hash, reporting
+// whether it was unused
+func (u *User) UseRecoveryCode(hash string) bool {
+	codes := splitList(u.RecoveryCodes)
+	i := slices.Index(codes, hash)
+	if i < 0 {
+		return false
+	}
+	u.RecoveryCodes = joinList(slices.Delete(codes, i, i+1))
+	return true
+}
+
+// RoleList returns the roles of the user as a slice
+func (u *User) RoleList() []string {
+	return splitList(u.Roles)
+}
+
+// SetRoles replaces the roles of the user
+func (u *User) SetRoles(roles []string) {
+	u.Roles = joinList(roles)
+}
+
+// splitList splits a comma separated column into its trimmed, non-empty values
+func splitList(value string) []string {
+	res := []string{}
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			res = append(res, v)
+		}
+	}
+	return res
+}
+
+// joinList is the inverse of splitList, dropping empty and duplicated values
+func joinList(values []string) string {
+	seen := make(map[string]struct{}, len(values))
+	res := make([]string, 0, len(values))
+	for _, v := range values {
+		v = strings.TrimSpace(v)
+		if _, ok := seen[v]; ok || v == "" {
+			continue
+		}
+		seen[v] = struct{}{}
+		res = append(res, v)
+	}
+	return strings.Join(res, ",")
+}
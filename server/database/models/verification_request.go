@@ -0,0 +1,53 @@
+package models
+
+// Identifiers of the verification requests, describing what the token proves
+const (
+	// VerificationTypeVerifyEmail confirms the email address of a new user
+	VerificationTypeVerifyEmail = "verify_email"
+	// VerificationTypeForgotPassword allows the user to set a new password
+	VerificationTypeForgotPassword = "forgot_password"
+	// VerificationTypeMagicLinkLogin logs the user in without a password
+	VerificationTypeMagicLinkLogin = "magic_link_login"
+)
+
+// VerificationRequest is a single use token sent by email
+type VerificationRequest struct {
+	ID string `gorm:"primaryKey;type:char(36)" json:"id" bson:"_id"`
+	// Token is the SHA-256 hash of the token sent by email, see crypto.HashToken
+	Token      string `gorm:"type:varchar(64);uniqueIndex" json:"-" bson:"token"`
+	Identifier string `gorm:"type:varchar(64)" json:"identifier" bson:"identifier"`
+	Email      string `gorm:"type:varchar(256);index" json:"email" bson:"email"`
+	// RedirectURI is where the user is sent once the token is consumed
+	RedirectURI string `gorm:"type:text" json:"redirect_uri" bson:"redirect_uri"`
+	// Roles and Scope are the comma separated roles and scopes requested for
+	// the session created when a login token is consumed
+	Roles     string `gorm:"type:text" json:"roles" bson:"roles"`
+	Scope     string `gorm:"type:text" json:"scope" bson:"scope"`
+	ExpiresAt int64  `json:"expires_at" bson:"expires_at"`
+	CreatedAt int64  `gorm:"autoCreateTime" json:"created_at" bson:"created_at"`
+}
+
+// TableName overrides the table name used by gorm
+func (VerificationRequest) TableName() string {
+	return CollectionVerificationRequests
+}
+
+// RoleList returns the requested roles as a slice
+func (v *VerificationRequest) RoleList() []string {
+	return splitList(v.Roles)
+}
+
+// SetRoles replaces the requested roles
+func (v *VerificationRequest) SetRoles(roles []string) {
+	v.Roles = joinList(roles)
+}
+
+// ScopeList returns the requested scopes as a slice
+func (v *VerificationRequest) ScopeList() []string {
+	return splitList(v.Scope)
+}
+
+// SetScope replaces the requested scopes
+func (v *VerificationRequest) SetScope(scope []string) {
+	v.Scope = joinList(scope)
+}
@@ -0,0 +1,155 @@
+package mongodb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"server/database/models"
+)
+
+// migration is a numbered, reversible collection / index change. Migrations
+// must never be edited once released: add a new one instead.
+type migration struct {
+	version int64
+	name    string
+	up      func(ctx context.Context, db *mongo.Database) error
+	down    func(ctx context.Context, db *mongo.Database) error
+}
+
+// Migrator is the MongoDB implementation of database.Migrator
+type Migrator struct {
+	db         *mongo.Database
+	migrations []migration
+}
+
+// NewMigrator returns a migrator running the MongoDB migrations on db
+func NewMigrator(db *mongo.Database) *Migrator {
+	sorted := make([]migration, len(migrations))
+	copy(sorted, migrations)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].version < sorted[j].version })
+
+	return &Migrator{db: db, migrations: sorted}
+}
+
+func (m *Migrator) collection() *mongo.Collection {
+	return m.db.Collection(models.CollectionSchemaMigrations)
+}
+
+// Up applies every pending migration. MongoDB transactions require a replica
+// set, so each migration is expected to be idempotent instead.
+func (m *Migrator) Up(ctx context.Context) ([]models.MigrationStatus, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var res []models.MigrationStatus
+	for _, mig := range m.migrations {
+		if _, ok := applied[mig.version]; ok {
+			continue
+		}
+
+		if err := mig.up(ctx, m.db); err != nil {
+			return res, fmt.Errorf("migration %d_%s failed: %w", mig.version, mig.name, err)
+		}
+		record := models.SchemaMigration{
+			Version:   mig.version,
+			Name:      mig.name,
+			AppliedAt: time.Now().Unix(),
+		}
+		if _, err := m.collection().InsertOne(ctx, record); err != nil {
+			return res, err
+		}
+		res = append(res, statusOf(mig, &record))
+	}
+	return res, nil
+}
+
+// Down rolls back the latest applied migration
+func (m *Migrator) Down(ctx context.Context) (*models.MigrationStatus, error) {
+	var latest models.SchemaMigration
+	err := m.collection().FindOne(ctx, bson.M{}, options.FindOne().SetSort(bson.M{"_id": -1})).Decode(&latest)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	mig, ok := m.find(latest.Version)
+	if !ok {
+		return nil, fmt.Errorf("migration %d is applied but unknown to this binary", latest.Version)
+	}
+
+	if err := mig.down(ctx, m.db); err != nil {
+		return nil, fmt.Errorf("rollback of %d_%s failed: %w", mig.version, mig.name, err)
+	}
+	if _, err := m.collection().DeleteOne(ctx, bson.M{"_id": latest.Version}); err != nil {
+		return nil, err
+	}
+
+	status := statusOf(mig, nil)
+	return &status, nil
+}
+
+// Status lists every known migration with its applied state
+func (m *Migrator) Status(ctx context.Context) ([]models.MigrationStatus, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]models.MigrationStatus, 0, len(m.migrations))
+	for _, mig := range m.migrations {
+		var record *models.SchemaMigration
+		if r, ok := applied[mig.version]; ok {
+			record = &r
+		}
+		res = append(res, statusOf(mig, record))
+	}
+	return res, nil
+}
+
+func (m *Migrator) applied(ctx context.Context) (map[int64]models.SchemaMigration, error) {
+	cursor, err := m.collection().Find(ctx, bson.M{})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var records []models.SchemaMigration
+	if err := cursor.All(ctx, &records); err != nil {
+		return nil, err
+	}
+
+	applied := make(map[int64]models.SchemaMigration, len(records))
+	for _, r := range records {
+		applied[r.Version] = r
+	}
+	return applied, nil
+}
+
+func (m *Migrator) find(version int64) (migration, bool) {
+	for _, mig := range m.migrations {
+		if mig.version == version {
+			return mig, true
+		}
+	}
+	return migration{}, false
+}
+
+func statusOf(mig migration, record *models.SchemaMigration) models.MigrationStatus {
+	status := models.MigrationStatus{Version: mig.version, Name: mig.name}
+	if record != nil {
+		status.Applied = true
+		status.AppliedAt = record.AppliedAt
+	}
+	return status
+}
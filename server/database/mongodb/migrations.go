@@ -0,0 +1,67 @@
+package mongodb
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"server/database/models"
+)
+
+// createIndex creates the named index, which is a no-op when it already exists
+func createIndex(ctx context.Context, db *mongo.Database, collection, name string, keys bson.D, unique bool) error {
+	opts := options.Index().SetName(name)
+	if unique {
+		opts.SetUnique(true)
+	}
+	_, err := db.Collection(collection).Indexes().CreateOne(ctx, mongo.IndexModel{Keys: keys, Options: opts})
+	return err
+}
+
+var migrations = []migration{
+	{
+		version: 1,
+		name:    "create_users",
+		up: func(ctx context.Context, db *mongo.Database) error {
+			return createIndex(ctx, db, models.CollectionUsers, "users_email_unique", bson.D{{Key: "email", Value: 1}}, true)
+		},
+		down: func(ctx context.Context, db *mongo.Database) error {
+			return db.Collection(models.CollectionUsers).Drop(ctx)
+		},
+	},
+	{
+		version: 2,
+		name:    "create_oauth_clients",
+		up: func(ctx context.Context, db *mongo.Database) error {
+			return createIndex(ctx, db, models.CollectionOAuthClients, "oauth_clients_client_id_unique", bson.D{{Key: "client_id", Value: 1}}, true)
+		},
+		down: func(ctx context.Context, db *mongo.Database) error {
+			return db.Collection(models.CollectionOAuthClients).Drop(ctx)
+		},
+	},
+	{
+		version: 3,
+		name:    "create_verification_requests",
+		up: func(ctx context.Context, db *mongo.Database) error {
+			if err := createIndex(ctx, db, models.CollectionVerificationRequests, "verification_requests_token_unique", bson.D{{Key: "token", Value: 1}}, true); err != nil {
+				return err
+			}
+			return createIndex(ctx, db, models.CollectionVerificationRequests, "verification_requests_email_identifier", bson.D{{Key: "email", Value: 1}, {Key: "identifier", Value: 1}}, false)
+		},
+		down: func(ctx context.Context, db *mongo.Database) error {
+			return db.Collection(models.CollectionVerificationRequests).Drop(ctx)
+		},
+	},
+	{
+		version: 4,
+		name:    "create_oidc_providers",
+		up: func(ctx context.Context, db *mongo.Database) error {
+			return createIndex(ctx, db, models.CollectionOIDCProviders, "oidc_providers_name_unique", bson.D{{Key: "name", Value: 1}}, true)
+		},
+		down: func(ctx context.Context, db *mongo.Database) error {
+			return db.Collection(models.CollectionOIDCProviders).Drop(ctx)
+		},
+	},
+}
@@ -0,0 +1,60 @@
+package mongodb
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"server/database/models"
+)
+
+// OAuthClientRepository is the MongoDB implementation of database.OAuthClientRepository
+type OAuthClientRepository struct {
+	collection *mongo.Collection
+}
+
+// NewOAuthClientRepository returns an OAuth client repository backed by the given mongo database
+func NewOAuthClientRepository(db *mongo.Database) *OAuthClientRepository {
+	return &OAuthClientRepository{collection: db.Collection(models.CollectionOAuthClients)}
+}
+
+// CreateOAuthClient stores a new client
+func (r *OAuthClientRepository) CreateOAuthClient(ctx context.Context, client *models.OAuthClient) (*models.OAuthClient, error) {
+	if client.ID == "" {
+		client.ID = uuid.New().String()
+	}
+	now := time.Now().Unix()
+	client.CreatedAt = now
+	client.UpdatedAt = now
+
+	if _, err := r.collection.InsertOne(ctx, client); err != nil {
+		return nil, translateError(err)
+	}
+	return client, nil
+}
+
+// GetOAuthClientByClientID returns the client with the given client_id
+func (r *OAuthClientRepository) GetOAuthClientByClientID(ctx context.Context, clientID string) (*models.OAuthClient, error) {
+	var client models.OAuthClient
+	if err := r.collection.FindOne(ctx, bson.M{"client_id": clientID}).Decode(&client); err != nil {
+		return nil, translateError(err)
+	}
+	return &client, nil
+}
+
+// UpdateOAuthClient persists the changes made to an existing client
+func (r *OAuthClientRepository) UpdateOAuthClient(ctx context.Context, client *models.OAuthClient) (*models.OAuthClient, error) {
+	client.UpdatedAt = time.Now().Unix()
+
+	res, err := r.collection.ReplaceOne(ctx, bson.M{"_id": client.ID}, client)
+	if err != nil {
+		return nil, translateError(err)
+	}
+	if res.MatchedCount == 0 {
+		return nil, models.ErrNotFound
+	}
+	return client, nil
+}
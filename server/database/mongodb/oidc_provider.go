@@ -0,0 +1,88 @@
+package mongodb
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"server/database/models"
+)
+
+// OIDCProviderRepository is the MongoDB implementation of database.OIDCProviderRepository
+type OIDCProviderRepository struct {
+	collection *mongo.Collection
+}
+
+// NewOIDCProviderRepository returns an OIDC provider repository backed by the given mongo database
+func NewOIDCProviderRepository(db *mongo.Database) *OIDCProviderRepository {
+	return &OIDCProviderRepository{collection: db.Collection(models.CollectionOIDCProviders)}
+}
+
+// CreateOIDCProvider stores a new provider
+func (r *OIDCProviderRepository) CreateOIDCProvider(ctx context.Context, provider *models.OIDCProvider) (*models.OIDCProvider, error) {
+	if provider.ID == "" {
+		provider.ID = uuid.New().String()
+	}
+	now := time.Now().Unix()
+	provider.CreatedAt = now
+	provider.UpdatedAt = now
+
+	if _, err := r.collection.InsertOne(ctx, provider); err != nil {
+		return nil, translateError(err)
+	}
+	return provider, nil
+}
+
+// GetOIDCProviderByName returns the provider with the given name
+func (r *OIDCProviderRepository) GetOIDCProviderByName(ctx context.Context, name string) (*models.OIDCProvider, error) {
+	var provider models.OIDCProvider
+	if err := r.collection.FindOne(ctx, bson.M{"name": name}).Decode(&provider); err != nil {
+		return nil, translateError(err)
+	}
+	return &provider, nil
+}
+
+// ListOIDCProviders returns all the providers ordered by creation time
+func (r *OIDCProviderRepository) ListOIDCProviders(ctx context.Context) ([]*models.OIDCProvider, error) {
+	cursor, err := r.collection.Find(ctx, bson.M{}, options.Find().SetSort(bson.M{"created_at": 1}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	providers := []*models.OIDCProvider{}
+	if err := cursor.All(ctx, &providers); err != nil {
+		return nil, err
+	}
+	return providers, nil
+}
+
+// UpdateOIDCProvider persists the changes made to an existing provider
+func (r *OIDCProviderRepository) UpdateOIDCProvider(ctx context.Context, provider *models.OIDCProvider) (*models.OIDCProvider, error) {
+	provider.UpdatedAt = time.Now().Unix()
+
+	res, err := r.collection.ReplaceOne(ctx, bson.M{"_id": provider.ID}, provider)
+	if err != nil {
+		return nil, translateError(err)
+	}
+	if res.MatchedCount == 0 {
+		return nil, models.ErrNotFound
+	}
+	return provider, nil
+}
+
+// DeleteOIDCProvider removes the provider with the given id
+func (r *OIDCProviderRepository) DeleteOIDCProvider(ctx context.Context, id string) error {
+	res, err := r.collection.DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		return err
+	}
+	if res.DeletedCount == 0 {
+		return models.ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package mongodb
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"server/database/models"
+)
+
+// UserRepository is the MongoDB implementation of database.UserRepository
+type UserRepository struct {
+	collection *mongo.Collection
+}
+
+// NewUserRepository returns a user repository backed by the given mongo database
+func NewUserRepository(db *mongo.Database) *UserRepository {
+	return &UserRepository{collection: db.Collection(models.CollectionUsers)}
+}
+
+// CreateUser stores a new user
+func (r *UserRepository) CreateUser(ctx context.Context, user *models.User) (*models.User, error) {
+	if user.ID == "" {
+		user.ID = uuid.New().String()
+	}
+	now := time.Now().Unix()
+	user.CreatedAt = now
+	user.UpdatedAt = now
+
+	if _, err := r.collection.InsertOne(ctx, user); err != nil {
+		return nil, translateError(err)
+	}
+	return user, nil
+}
+
+// GetUserByID returns the user with the given id
+func (r *UserRepository) GetUserByID(ctx context.Context, id string) (*models.User, error) {
+	var user models.User
+	if err := r.collection.FindOne(ctx, bson.M{"_id": id}).Decode(&user); err != nil {
+		return nil, translateError(err)
+	}
+	return &user, nil
+}
+
+// GetUserByEmail returns the user with the given email
+func (r *UserRepository) GetUserByEmail(ctx context.Context, email string) (*models.User, error) {
+	var user models.User
+	if err := r.collection.FindOne(ctx, bson.M{"email": email}).Decode(&user); err != nil {
+		return nil, translateError(err)
+	}
+	return &user, nil
+}
+
+// ListUsers returns all the users ordered by creation time
+func (r *UserRepository) ListUsers(ctx context.Context) ([]*models.User, error) {
+	cursor, err := r.collection.Find(ctx, bson.M{}, options.Find().SetSort(bson.M{"created_at": 1}))
+	if err != nil {
+		return nil, translateError(err)
+	}
+	defer cursor.Close(ctx)
+
+	users := []*models.User{}
+	if err := cursor.All(ctx, &users); err != nil {
+		return nil, translateError(err)
+	}
+	return users, nil
+}
+
+// UpdateUser persists the changes made to an existing user
+func (r *UserRepository) UpdateUser(ctx context.Context, user *models.User) (*models.User, error) {
+	user.UpdatedAt = time.Now().Unix()
+
+	res, err := r.collection.ReplaceOne(ctx, bson.M{"_id": user.ID}, user)
+	if err != nil {
+		return nil, translateError(err)
+	}
+	if res.MatchedCount == 0 {
+		return nil, models.ErrNotFound
+	}
+	return user, nil
+}
+
+// DeleteUser removes the user with the given id
+func (r *UserRepository) DeleteUser(ctx context.Context, id string) error {
+	res, err := r.collection.DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		return translateError(err)
+	}
+	if res.DeletedCount == 0 {
+		return models.ErrNotFound
+	}
+	return nil
+}
+
+// translateError maps mongo errors to the storage-agnostic errors of the models package
+func translateError(err error) error {
+	switch {
+	case errors.Is(err, mongo.ErrNoDocuments):
+		return models.ErrNotFound
+	case mongo.IsDuplicateKeyError(err):
+		return models.ErrDuplicate
+	default:
+		return err
+	}
+}
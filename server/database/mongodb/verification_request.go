@@ -0,0 +1,62 @@
+package mongodb
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"server/database/models"
+)
+
+// VerificationRequestRepository is the MongoDB implementation of database.VerificationRequestRepository
+type VerificationRequestRepository struct {
+	collection *mongo.Collection
+}
+
+// NewVerificationRequestRepository returns a verification request repository backed by the given mongo database
+func NewVerificationRequestRepository(db *mongo.Database) *VerificationRequestRepository {
+	return &VerificationRequestRepository{collection: db.Collection(models.CollectionVerificationRequests)}
+}
+
+// CreateVerificationRequest stores a new request
+func (r *VerificationRequestRepository) CreateVerificationRequest(ctx context.Context, req *models.VerificationRequest) (*models.VerificationRequest, error) {
+	if req.ID == "" {
+		req.ID = uuid.New().String()
+	}
+	req.CreatedAt = time.Now().Unix()
+
+	if _, err := r.collection.InsertOne(ctx, req); err != nil {
+		return nil, translateError(err)
+	}
+	return req, nil
+}
+
+// GetVerificationRequestByToken returns the request with the given hashed token
+func (r *VerificationRequestRepository) GetVerificationRequestByToken(ctx context.Context, token string) (*models.VerificationRequest, error) {
+	var req models.VerificationRequest
+	if err := r.collection.FindOne(ctx, bson.M{"token": token}).Decode(&req); err != nil {
+		return nil, translateError(err)
+	}
+	return &req, nil
+}
+
+// DeleteVerificationRequest removes the request with the given id
+func (r *VerificationRequestRepository) DeleteVerificationRequest(ctx context.Context, id string) error {
+	res, err := r.collection.DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		return err
+	}
+	if res.DeletedCount == 0 {
+		return models.ErrNotFound
+	}
+	return nil
+}
+
+// DeleteVerificationRequestsByEmail removes every request of the given identifier for the email
+func (r *VerificationRequestRepository) DeleteVerificationRequestsByEmail(ctx context.Context, email, identifier string) error {
+	_, err := r.collection.DeleteMany(ctx, bson.M{"email": email, "identifier": identifier})
+	return err
+}
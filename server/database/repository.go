@@ -0,0 +1,88 @@
+package database
+
+import (
+	"context"
+
+	"server/database/models"
+)
+
+// UserRepository is the storage-agnostic contract for persisting users.
+// Lookups return models.ErrNotFound when no user matches and writes return
+// models.ErrDuplicate when the email is already taken.
+type UserRepository interface {
+	// CreateUser stores a new user, generating its id when empty
+	CreateUser(ctx context.Context, user *models.User) (*models.User, error)
+	// GetUserByID returns the user with the given id
+	GetUserByID(ctx context.Context, id string) (*models.User, error)
+	// GetUserByEmail returns the user with the given email
+	GetUserByEmail(ctx context.Context, email string) (*models.User, error)
+	// ListUsers returns all the users
+	ListUsers(ctx context.Context) ([]*models.User, error)
+	// UpdateUser persists the changes made to an existing user
+	UpdateUser(ctx context.Context, user *models.User) (*models.User, error)
+	// DeleteUser removes the user with the given id
+	DeleteUser(ctx context.Context, id string) error
+}
+
+// OAuthClientRepository is the storage-agnostic contract for OAuth clients.
+// Lookups return models.ErrNotFound when no client matches.
+type OAuthClientRepository interface {
+	// CreateOAuthClient stores a new client, generating its id when empty
+	CreateOAuthClient(ctx context.Context, client *models.OAuthClient) (*models.OAuthClient, error)
+	// GetOAuthClientByClientID returns the client with the given client_id
+	GetOAuthClientByClientID(ctx context.Context, clientID string) (*models.OAuthClient, error)
+	// UpdateOAuthClient persists the changes made to an existing client
+	UpdateOAuthClient(ctx context.Context, client *models.OAuthClient) (*models.OAuthClient, error)
+}
+
+// VerificationRequestRepository is the storage-agnostic contract for the
+// single use tokens sent by email. Lookups return models.ErrNotFound when
+// no request matches.
+type VerificationRequestRepository interface {
+	// CreateVerificationRequest stores a new request, generating its id when empty
+	CreateVerificationRequest(ctx context.Context, req *models.VerificationRequest) (*models.VerificationRequest, error)
+	// GetVerificationRequestByToken returns the request with the given hashed token
+	GetVerificationRequestByToken(ctx context.Context, token string) (*models.VerificationRequest, error)
+	// DeleteVerificationRequest removes the request with the given id
+	DeleteVerificationRequest(ctx context.Context, id string) error
+	// DeleteVerificationRequestsByEmail removes every request of the given
+	// identifier for the email
+	DeleteVerificationRequestsByEmail(ctx context.Context, email, identifier string) error
+}
+
+// OIDCProviderRepository is the storage-agnostic contract for upstream
+// OpenID Connect providers. Lookups return models.ErrNotFound when no
+// provider matches and writes return models.ErrDuplicate when the name is
+// already taken.
+type OIDCProviderRepository interface {
+	// CreateOIDCProvider stores a new provider, generating its id when empty
+	CreateOIDCProvider(ctx context.Context, provider *models.OIDCProvider) (*models.OIDCProvider, error)
+	// GetOIDCProviderByName returns the provider with the given name
+	GetOIDCProviderByName(ctx context.Context, name string) (*models.OIDCProvider, error)
+	// ListOIDCProviders returns all the providers
+	ListOIDCProviders(ctx context.Context) ([]*models.OIDCProvider, error)
+	// UpdateOIDCProvider persists the changes made to an existing provider
+	UpdateOIDCProvider(ctx context.Context, provider *models.OIDCProvider) (*models.OIDCProvider, error)
+	// DeleteOIDCProvider removes the provider with the given id
+	DeleteOIDCProvider(ctx context.Context, id string) error
+}
+
+// Repository groups every repository the API layer depends on
+type Repository interface {
+	UserRepository
+	OAuthClientRepository
+	VerificationRequestRepository
+	OIDCProviderRepository
+}
+
+// Migrator applies the versioned schema migrations of a backend and tracks
+// them in the schema_migrations table / collection
+type Migrator interface {
+	// Up applies every pending migration in order and returns the applied ones
+	Up(ctx context.Context) ([]models.MigrationStatus, error)
+	// Down rolls back the most recently applied migration, returning nil when
+	// there is nothing to roll back
+	Down(ctx context.Context) (*models.MigrationStatus, error)
+	// Status lists every known migration with its applied state
+	Status(ctx context.Context) ([]models.MigrationStatus, error)
+}
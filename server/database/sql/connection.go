@@ -0,0 +1,52 @@
+package sql
+
+import (
+	"fmt"
+	"server/config"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// NewSQLConnection opens a gorm connection for the configured SQL backend and
+// applies the connection pool limits
+func NewSQLConnection(cfg *config.Config) (*gorm.DB, error) {
+	gormConfig := &gorm.Config{TranslateError: true}
+
+	var (
+		db  *gorm.DB
+		err error
+	)
+	switch cfg.DBType {
+	case "sqlite":
+		db, err = gorm.Open(sqlite.Open(cfg.DBName), gormConfig)
+	case "postgres":
+		db, err = openPostgres(cfg, gormConfig)
+	case "mysql":
+		db, err = openMySQL(cfg, gormConfig)
+	default:
+		return nil, fmt.Errorf("unsupported SQL database type: %s", cfg.DBType)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := configurePool(db, cfg); err != nil {
+		return nil, err
+	}
+	return db, nil
+}
+
+// configurePool applies the max open / idle connections and lifetimes from config
+func configurePool(db *gorm.DB, cfg *config.Config) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+
+	sqlDB.SetMaxOpenConns(cfg.DBMaxOpenConns)
+	sqlDB.SetMaxIdleConns(cfg.DBMaxIdleConns)
+	sqlDB.SetConnMaxLifetime(cfg.DBConnMaxLifetime)
+	sqlDB.SetConnMaxIdleTime(cfg.DBConnMaxIdleTime)
+	return nil
+}
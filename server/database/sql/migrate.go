@@ -0,0 +1,160 @@
+package sql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+
+	"server/database/models"
+)
+
+// migration is a numbered, reversible schema change. Migrations must never be
+// edited once released: add a new one instead.
+type migration struct {
+	version int64
+	name    string
+	up      func(tx *gorm.DB) error
+	down    func(tx *gorm.DB) error
+}
+
+// Migrator is the gorm implementation of database.Migrator
+type Migrator struct {
+	db         *gorm.DB
+	migrations []migration
+}
+
+// NewMigrator returns a migrator running the SQL migrations on db
+func NewMigrator(db *gorm.DB) *Migrator {
+	sorted := make([]migration, len(migrations))
+	copy(sorted, migrations)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].version < sorted[j].version })
+
+	return &Migrator{db: db, migrations: sorted}
+}
+
+// Up applies every pending migration, each one in its own transaction
+func (m *Migrator) Up(ctx context.Context) ([]models.MigrationStatus, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var res []models.MigrationStatus
+	for _, mig := range m.migrations {
+		if _, ok := applied[mig.version]; ok {
+			continue
+		}
+
+		record := models.SchemaMigration{
+			Version:   mig.version,
+			Name:      mig.name,
+			AppliedAt: time.Now().Unix(),
+		}
+		err := m.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			if err := mig.up(tx); err != nil {
+				return err
+			}
+			return tx.Create(&record).Error
+		})
+		if err != nil {
+			return res, fmt.Errorf("migration %d_%s failed: %w", mig.version, mig.name, err)
+		}
+		res = append(res, statusOf(mig, &record))
+	}
+	return res, nil
+}
+
+// Down rolls back the latest applied migration
+func (m *Migrator) Down(ctx context.Context) (*models.MigrationStatus, error) {
+	if err := m.ensureTable(ctx); err != nil {
+		return nil, err
+	}
+
+	var latest models.SchemaMigration
+	err := m.db.WithContext(ctx).Order("version desc").First(&latest).Error
+	if err != nil {
+		if errors.Is(translateError(err), models.ErrNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	mig, ok := m.find(latest.Version)
+	if !ok {
+		return nil, fmt.Errorf("migration %d is applied but unknown to this binary", latest.Version)
+	}
+
+	err = m.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := mig.down(tx); err != nil {
+			return err
+		}
+		return tx.Delete(&models.SchemaMigration{}, latest.Version).Error
+	})
+	if err != nil {
+		return nil, fmt.Errorf("rollback of %d_%s failed: %w", mig.version, mig.name, err)
+	}
+
+	status := statusOf(mig, nil)
+	return &status, nil
+}
+
+// Status lists every known migration with its applied state
+func (m *Migrator) Status(ctx context.Context) ([]models.MigrationStatus, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]models.MigrationStatus, 0, len(m.migrations))
+	for _, mig := range m.migrations {
+		var record *models.SchemaMigration
+		if r, ok := applied[mig.version]; ok {
+			record = &r
+		}
+		res = append(res, statusOf(mig, record))
+	}
+	return res, nil
+}
+
+func (m *Migrator) ensureTable(ctx context.Context) error {
+	return m.db.WithContext(ctx).AutoMigrate(&models.SchemaMigration{})
+}
+
+func (m *Migrator) applied(ctx context.Context) (map[int64]models.SchemaMigration, error) {
+	if err := m.ensureTable(ctx); err != nil {
+		return nil, err
+	}
+
+	var records []models.SchemaMigration
+	if err := m.db.WithContext(ctx).Find(&records).Error; err != nil {
+		return nil, err
+	}
+
+	applied := make(map[int64]models.SchemaMigration, len(records))
+	for _, r := range records {
+		applied[r.Version] = r
+	}
+	return applied, nil
+}
+
+func (m *Migrator) find(version int64) (migration, bool) {
+	for _, mig := range m.migrations {
+		if mig.version == version {
+			return mig, true
+		}
+	}
+	return migration{}, false
+}
+
+func statusOf(mig migration, record *models.SchemaMigration) models.MigrationStatus {
+	status := models.MigrationStatus{Version: mig.version, Name: mig.name}
+	if record != nil {
+		status.Applied = true
+		status.AppliedAt = record.AppliedAt
+	}
+	return status
+}
@@ -0,0 +1,222 @@
+package sql
+
+import (
+	"gorm.io/gorm"
+)
+
+// The structs below are snapshots of the models at the time of each
+// migration, so later changes to the models package never alter history.
+
+type userV1 struct {
+	ID        string `gorm:"primaryKey;type:char(36)"`
+	Name      string `gorm:"type:varchar(256)"`
+	Email     string `gorm:"type:varchar(256);uniqueIndex:idx_users_email"`
+	CreatedAt int64
+	UpdatedAt int64
+}
+
+func (userV1) TableName() string { return "users" }
+
+type userV2 struct {
+	userV1
+	Roles string `gorm:"type:text"`
+}
+
+func (userV2) TableName() string { return "users" }
+
+type userV3 struct {
+	userV2
+	Password string `gorm:"type:text"`
+}
+
+func (userV3) TableName() string { return "users" }
+
+type userV4 struct {
+	userV3
+	EmailVerifiedAt *int64
+}
+
+func (userV4) TableName() string { return "users" }
+
+type userV5 struct {
+	userV4
+	SessionsRevokedAt *int64
+}
+
+func (userV5) TableName() string { return "users" }
+
+type userV6 struct {
+	userV5
+	TOTPSecret     string `gorm:"type:text"`
+	TOTPVerifiedAt *int64
+	RecoveryCodes  string `gorm:"type:text"`
+}
+
+func (userV6) TableName() string { return "users" }
+
+type verificationRequestV1 struct {
+	ID          string `gorm:"primaryKey;type:char(36)"`
+	Token       string `gorm:"type:varchar(64);uniqueIndex:idx_verification_requests_token"`
+	Identifier  string `gorm:"type:varchar(64)"`
+	Email       string `gorm:"type:varchar(256);index:idx_verification_requests_email"`
+	RedirectURI string `gorm:"type:text"`
+	ExpiresAt   int64
+	CreatedAt   int64
+}
+
+func (verificationRequestV1) TableName() string { return "verification_requests" }
+
+type verificationRequestV2 struct {
+	verificationRequestV1
+	Roles string `gorm:"type:text"`
+	Scope string `gorm:"type:text"`
+}
+
+func (verificationRequestV2) TableName() string { return "verification_requests" }
+
+type oauthClientV1 struct {
+	ID           string `gorm:"primaryKey;type:char(36)"`
+	ClientID     string `gorm:"type:varchar(256);uniqueIndex:idx_oauth_clients_client_id"`
+	ClientSecret string `gorm:"type:varchar(256)"`
+	Name         string `gorm:"type:varchar(256)"`
+	RedirectURIs string `gorm:"type:text"`
+	CreatedAt    int64
+	UpdatedAt    int64
+}
+
+func (oauthClientV1) TableName() string { return "oauth_clients" }
+
+type oidcProviderV1 struct {
+	ID                 string `gorm:"primaryKey;type:char(36)"`
+	Name               string `gorm:"type:varchar(64);uniqueIndex:idx_oidc_providers_name"`
+	Issuer             string `gorm:"type:varchar(512)"`
+	ClientID           string `gorm:"type:varchar(256)"`
+	ClientSecret       string `gorm:"type:text"`
+	Scopes             string `gorm:"type:text"`
+	EmailClaim         string `gorm:"type:varchar(256)"`
+	EmailVerifiedClaim string `gorm:"type:varchar(256)"`
+	NameClaim          string `gorm:"type:varchar(256)"`
+	CreatedAt          int64
+	UpdatedAt          int64
+}
+
+func (oidcProviderV1) TableName() string { return "oidc_providers" }
+
+var migrations = []migration{
+	{
+		version: 1,
+		name:    "create_users",
+		up: func(tx *gorm.DB) error {
+			return tx.Migrator().CreateTable(&userV1{})
+		},
+		down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&userV1{})
+		},
+	},
+	{
+		version: 2,
+		name:    "add_user_roles",
+		up: func(tx *gorm.DB) error {
+			return tx.Migrator().AddColumn(&userV2{}, "Roles")
+		},
+		down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropColumn(&userV2{}, "Roles")
+		},
+	},
+	{
+		version: 3,
+		name:    "create_oauth_clients",
+		up: func(tx *gorm.DB) error {
+			return tx.Migrator().CreateTable(&oauthClientV1{})
+		},
+		down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&oauthClientV1{})
+		},
+	},
+	{
+		version: 4,
+		name:    "add_user_password",
+		up: func(tx *gorm.DB) error {
+			return tx.Migrator().AddColumn(&userV3{}, "Password")
+		},
+		down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropColumn(&userV3{}, "Password")
+		},
+	},
+	{
+		version: 5,
+		name:    "add_user_email_verified_at",
+		up: func(tx *gorm.DB) error {
+			return tx.Migrator().AddColumn(&userV4{}, "EmailVerifiedAt")
+		},
+		down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropColumn(&userV4{}, "EmailVerifiedAt")
+		},
+	},
+	{
+		version: 6,
+		name:    "create_verification_requests",
+		up: func(tx *gorm.DB) error {
+			return tx.Migrator().CreateTable(&verificationRequestV1{})
+		},
+		down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&verificationRequestV1{})
+		},
+	},
+	{
+		version: 7,
+		name:    "add_user_sessions_revoked_at",
+		up: func(tx *gorm.DB) error {
+			return tx.Migrator().AddColumn(&userV5{}, "SessionsRevokedAt")
+		},
+		down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropColumn(&userV5{}, "SessionsRevokedAt")
+		},
+	},
+	{
+		version: 8,
+		name:    "add_verification_request_roles_and_scope",
+		up: func(tx *gorm.DB) error {
+			if err := tx.Migrator().AddColumn(&verificationRequestV2{}, "Roles"); err != nil {
+				return err
+			}
+			return tx.Migrator().AddColumn(&verificationRequestV2{}, "Scope")
+		},
+		down: func(tx *gorm.DB) error {
+			if err := tx.Migrator().DropColumn(&verificationRequestV2{}, "Scope"); err != nil {
+				return err
+			}
+			return tx.Migrator().DropColumn(&verificationRequestV2{}, "Roles")
+		},
+	},
+	{
+		version: 9,
+		name:    "create_oidc_providers",
+		up: func(tx *gorm.DB) error {
+			return tx.Migrator().CreateTable(&oidcProviderV1{})
+		},
+		down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&oidcProviderV1{})
+		},
+	},
+	{
+		version: 10,
+		name:    "add_user_totp",
+		up: func(tx *gorm.DB) error {
+			for _, column := range []string{"TOTPSecret", "TOTPVerifiedAt", "RecoveryCodes"} {
+				if err := tx.Migrator().AddColumn(&userV6{}, column); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		down: func(tx *gorm.DB) error {
+			for _, column := range []string{"RecoveryCodes", "TOTPVerifiedAt", "TOTPSecret"} {
+				if err := tx.Migrator().DropColumn(&userV6{}, column); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+}
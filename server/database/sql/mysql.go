@@ -0,0 +1,50 @@
+package sql
+
+import (
+	"net"
+	"server/config"
+
+	driver "github.com/go-sql-driver/mysql"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+)
+
+// mysqlTLSConfigName is the name the custom TLS config is registered under
+const mysqlTLSConfigName = "account-verse"
+
+// MySQLConfig returns the driver config of the configured database,
+// registering its TLS config under mysqlTLSConfigName
+func MySQLConfig(cfg *config.Config) (*driver.Config, error) {
+	dsn := driver.NewConfig()
+	dsn.User = cfg.DBUser
+	dsn.Passwd = cfg.DBPassword
+	dsn.Net = "tcp"
+	dsn.Addr = net.JoinHostPort(cfg.DBHost, cfg.DBPort)
+	dsn.DBName = cfg.DBName
+	dsn.ParseTime = true
+	// report matched rather than changed rows, so updates that change nothing
+	// are not mistaken for missing records
+	dsn.ClientFoundRows = true
+	dsn.Params = map[string]string{"charset": "utf8mb4"}
+
+	tlsConfig, err := NewTLSConfig(cfg, cfg.DBHost)
+	if err != nil {
+		return nil, err
+	}
+	if tlsConfig != nil {
+		if err := driver.RegisterTLSConfig(mysqlTLSConfigName, tlsConfig); err != nil {
+			return nil, err
+		}
+		dsn.TLSConfig = mysqlTLSConfigName
+		dsn.AllowFallbackToPlaintext = allowsPlaintext(cfg.DBSSLMode)
+	}
+	return dsn, nil
+}
+
+func openMySQL(cfg *config.Config, gormConfig *gorm.Config) (*gorm.DB, error) {
+	dsn, err := MySQLConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return gorm.Open(mysql.Open(dsn.FormatDSN()), gormConfig)
+}
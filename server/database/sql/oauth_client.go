@@ -0,0 +1,59 @@
+package sql
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"server/database/models"
+)
+
+// OAuthClientRepository is the gorm implementation of database.OAuthClientRepository
+type OAuthClientRepository struct {
+	db *gorm.DB
+}
+
+// NewOAuthClientRepository returns an OAuth client repository backed by the given gorm connection
+func NewOAuthClientRepository(db *gorm.DB) *OAuthClientRepository {
+	return &OAuthClientRepository{db: db}
+}
+
+// CreateOAuthClient stores a new client
+func (r *OAuthClientRepository) CreateOAuthClient(ctx context.Context, client *models.OAuthClient) (*models.OAuthClient, error) {
+	if client.ID == "" {
+		client.ID = uuid.New().String()
+	}
+	now := time.Now().Unix()
+	client.CreatedAt = now
+	client.UpdatedAt = now
+
+	if err := r.db.WithContext(ctx).Create(client).Error; err != nil {
+		return nil, translateError(err)
+	}
+	return client, nil
+}
+
+// GetOAuthClientByClientID returns the client with the given client_id
+func (r *OAuthClientRepository) GetOAuthClientByClientID(ctx context.Context, clientID string) (*models.OAuthClient, error) {
+	var client models.OAuthClient
+	if err := r.db.WithContext(ctx).Where("client_id = ?", clientID).First(&client).Error; err != nil {
+		return nil, translateError(err)
+	}
+	return &client, nil
+}
+
+// UpdateOAuthClient persists the changes made to an existing client
+func (r *OAuthClientRepository) UpdateOAuthClient(ctx context.Context, client *models.OAuthClient) (*models.OAuthClient, error) {
+	client.UpdatedAt = time.Now().Unix()
+
+	res := r.db.WithContext(ctx).Model(&models.OAuthClient{}).Where("id = ?", client.ID).Select("*").Omit("id", "created_at").Updates(client)
+	if res.Error != nil {
+		return nil, translateError(res.Error)
+	}
+	if res.RowsAffected == 0 {
+		return nil, models.ErrNotFound
+	}
+	return client, nil
+}
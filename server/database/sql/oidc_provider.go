@@ -0,0 +1,81 @@
+package sql
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"server/database/models"
+)
+
+// OIDCProviderRepository is the gorm implementation of database.OIDCProviderRepository
+type OIDCProviderRepository struct {
+	db *gorm.DB
+}
+
+// NewOIDCProviderRepository returns an OIDC provider repository backed by the given gorm connection
+func NewOIDCProviderRepository(db *gorm.DB) *OIDCProviderRepository {
+	return &OIDCProviderRepository{db: db}
+}
+
+// CreateOIDCProvider stores a new provider
+func (r *OIDCProviderRepository) CreateOIDCProvider(ctx context.Context, provider *models.OIDCProvider) (*models.OIDCProvider, error) {
+	if provider.ID == "" {
+		provider.ID = uuid.New().String()
+	}
+	now := time.Now().Unix()
+	provider.CreatedAt = now
+	provider.UpdatedAt = now
+
+	if err := r.db.WithContext(ctx).Create(provider).Error; err != nil {
+		return nil, translateError(err)
+	}
+	return provider, nil
+}
+
+// GetOIDCProviderByName returns the provider with the given name
+func (r *OIDCProviderRepository) GetOIDCProviderByName(ctx context.Context, name string) (*models.OIDCProvider, error) {
+	var provider models.OIDCProvider
+	if err := r.db.WithContext(ctx).Where("name = ?", name).First(&provider).Error; err != nil {
+		return nil, translateError(err)
+	}
+	return &provider, nil
+}
+
+// ListOIDCProviders returns all the providers ordered by creation time
+func (r *OIDCProviderRepository) ListOIDCProviders(ctx context.Context) ([]*models.OIDCProvider, error) {
+	var providers []*models.OIDCProvider
+	if err := r.db.WithContext(ctx).Order(clause.OrderByColumn{Column: clause.Column{Name: "created_at"}}).Find(&providers).Error; err != nil {
+		return nil, translateError(err)
+	}
+	return providers, nil
+}
+
+// UpdateOIDCProvider persists the changes made to an existing provider
+func (r *OIDCProviderRepository) UpdateOIDCProvider(ctx context.Context, provider *models.OIDCProvider) (*models.OIDCProvider, error) {
+	provider.UpdatedAt = time.Now().Unix()
+
+	res := r.db.WithContext(ctx).Model(&models.OIDCProvider{}).Where("id = ?", provider.ID).Select("*").Omit("id", "created_at").Updates(provider)
+	if res.Error != nil {
+		return nil, translateError(res.Error)
+	}
+	if res.RowsAffected == 0 {
+		return nil, models.ErrNotFound
+	}
+	return provider, nil
+}
+
+// DeleteOIDCProvider removes the provider with the given id
+func (r *OIDCProviderRepository) DeleteOIDCProvider(ctx context.Context, id string) error {
+	res := r.db.WithContext(ctx).Where("id = ?", id).Delete(&models.OIDCProvider{})
+	if res.Error != nil {
+		return translateError(res.Error)
+	}
+	if res.RowsAffected == 0 {
+		return models.ErrNotFound
+	}
+	return nil
+}
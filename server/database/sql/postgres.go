@@ -0,0 +1,66 @@
+package sql
+
+import (
+	"net"
+	"net/url"
+	"server/config"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/stdlib"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// postgresDSN builds a postgres:// URL so credentials containing special
+// characters are escaped properly
+func postgresDSN(cfg *config.Config) string {
+	u := url.URL{
+		Scheme: "postgres",
+		User:   url.UserPassword(cfg.DBUser, cfg.DBPassword),
+		Host:   net.JoinHostPort(cfg.DBHost, cfg.DBPort),
+		Path:   "/" + cfg.DBName,
+	}
+	q := u.Query()
+	q.Set("sslmode", cfg.DBSSLMode)
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+// PostgresConfig returns the pgx connection config of the configured
+// database. The plaintext and TLS attempts pgx makes for the SSL mode are
+// kept, the TLS ones use the configured certificates.
+func PostgresConfig(cfg *config.Config) (*pgx.ConnConfig, error) {
+	pgxConfig, err := pgx.ParseConfig(postgresDSN(cfg))
+	if err != nil {
+		return nil, err
+	}
+
+	// pgx only understands certificate file paths, so certificates provided
+	// through DATABASE_CERT / DATABASE_CERT_KEY / DATABASE_CA_CERT are loaded
+	// into a TLS config of our own
+	if hasCertificates(cfg) {
+		tlsConfig, err := NewTLSConfig(cfg, cfg.DBHost)
+		if err != nil {
+			return nil, err
+		}
+		if pgxConfig.TLSConfig != nil {
+			pgxConfig.TLSConfig = tlsConfig
+		}
+		for _, fallback := range pgxConfig.Fallbacks {
+			if fallback.TLSConfig != nil {
+				fallback.TLSConfig = tlsConfig
+			}
+		}
+	}
+	return pgxConfig, nil
+}
+
+func openPostgres(cfg *config.Config, gormConfig *gorm.Config) (*gorm.DB, error) {
+	pgxConfig, err := PostgresConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return gorm.Open(postgres.New(postgres.Config{
+		Conn: stdlib.OpenDB(*pgxConfig),
+	}), gormConfig)
+}
@@ -0,0 +1,134 @@
+package sql
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"server/config"
+	"strings"
+)
+
+// SSL modes understood for every SQL backend, named after the postgres ones
+const (
+	sslModeDisable    = "disable"
+	sslModeAllow      = "allow"
+	sslModePrefer     = "prefer"
+	sslModeRequire    = "require"
+	sslModeVerifyCA   = "verify-ca"
+	sslModeVerifyFull = "verify-full"
+)
+
+func hasCertificates(cfg *config.Config) bool {
+	return cfg.DBCert != "" || cfg.DBCertKey != "" || cfg.DBCACert != ""
+}
+
+// allowsPlaintext reports whether the SSL mode falls back to an unencrypted
+// connection when the server does not support TLS
+func allowsPlaintext(mode string) bool {
+	return mode == sslModeAllow || mode == sslModePrefer
+}
+
+// NewTLSConfig builds the TLS config matching cfg.DBSSLMode. It returns nil
+// when TLS is disabled.
+func NewTLSConfig(cfg *config.Config, serverName string) (*tls.Config, error) {
+	mode := cfg.DBSSLMode
+	switch mode {
+	case "", sslModeDisable:
+		return nil, nil
+	case sslModeAllow, sslModePrefer, sslModeRequire, sslModeVerifyCA, sslModeVerifyFull:
+	default:
+		return nil, fmt.Errorf("unsupported SSL mode: %s", mode)
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ServerName: serverName,
+	}
+
+	if cfg.DBCert != "" || cfg.DBCertKey != "" {
+		if cfg.DBCert == "" || cfg.DBCertKey == "" {
+			return nil, errors.New("both DATABASE_CERT and DATABASE_CERT_KEY are required for client certificates")
+		}
+		certPEM, err := readPEM(cfg.DBCert)
+		if err != nil {
+			return nil, err
+		}
+		keyPEM, err := readPEM(cfg.DBCertKey)
+		if err != nil {
+			return nil, err
+		}
+		cert, err := tls.X509KeyPair(certPEM, keyPEM)
+		if err != nil {
+			return nil, fmt.Errorf("invalid database client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	if cfg.DBCACert != "" {
+		caPEM, err := readPEM(cfg.DBCACert)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caPEM) {
+			return nil, errors.New("invalid DATABASE_CA_CERT")
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	switch mode {
+	case sslModeVerifyFull:
+		// default verification: chain and hostname
+	case sslModeVerifyCA:
+		// verify the chain against the CA but not the hostname
+		tlsConfig.InsecureSkipVerify = true
+		tlsConfig.VerifyPeerCertificate = verifyChain(tlsConfig.RootCAs)
+	default:
+		// encrypt only, as postgres does for allow / prefer / require
+		tlsConfig.InsecureSkipVerify = true
+	}
+
+	return tlsConfig, nil
+}
+
+// verifyChain returns a verifier checking the presented chain against roots
+// without checking the server name
+func verifyChain(roots *x509.CertPool) func([][]byte, [][]*x509.Certificate) error {
+	return func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+		if len(rawCerts) == 0 {
+			return errors.New("database server presented no certificate")
+		}
+		certs := make([]*x509.Certificate, 0, len(rawCerts))
+		for _, raw := range rawCerts {
+			cert, err := x509.ParseCertificate(raw)
+			if err != nil {
+				return err
+			}
+			certs = append(certs, cert)
+		}
+
+		intermediates := x509.NewCertPool()
+		for _, cert := range certs[1:] {
+			intermediates.AddCert(cert)
+		}
+		_, err := certs[0].Verify(x509.VerifyOptions{
+			Roots:         roots,
+			Intermediates: intermediates,
+		})
+		return err
+	}
+}
+
+// readPEM accepts either inline PEM content or a path to a PEM file
+func readPEM(value string) ([]byte, error) {
+	if strings.HasPrefix(strings.TrimSpace(value), "-----BEGIN") {
+		return []byte(value), nil
+	}
+	data, err := os.ReadFile(value)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read certificate %s: %w", value, err)
+	}
+	return data, nil
+}
@@ -0,0 +1,103 @@
+package sql
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"server/database/models"
+)
+
+// UserRepository is the gorm implementation of database.UserRepository
+type UserRepository struct {
+	db *gorm.DB
+}
+
+// NewUserRepository returns a user repository backed by the given gorm connection
+func NewUserRepository(db *gorm.DB) *UserRepository {
+	return &UserRepository{db: db}
+}
+
+// CreateUser stores a new user
+func (r *UserRepository) CreateUser(ctx context.Context, user *models.User) (*models.User, error) {
+	if user.ID == "" {
+		user.ID = uuid.New().String()
+	}
+	now := time.Now().Unix()
+	user.CreatedAt = now
+	user.UpdatedAt = now
+
+	if err := r.db.WithContext(ctx).Create(user).Error; err != nil {
+		return nil, translateError(err)
+	}
+	return user, nil
+}
+
+// GetUserByID returns the user with the given id
+func (r *UserRepository) GetUserByID(ctx context.Context, id string) (*models.User, error) {
+	var user models.User
+	if err := r.db.WithContext(ctx).Where("id = ?", id).First(&user).Error; err != nil {
+		return nil, translateError(err)
+	}
+	return &user, nil
+}
+
+// GetUserByEmail returns the user with the given email
+func (r *UserRepository) GetUserByEmail(ctx context.Context, email string) (*models.User, error) {
+	var user models.User
+	if err := r.db.WithContext(ctx).Where("email = ?", email).First(&user).Error; err != nil {
+		return nil, translateError(err)
+	}
+	return &user, nil
+}
+
+// ListUsers returns all the users ordered by creation time
+func (r *UserRepository) ListUsers(ctx context.Context) ([]*models.User, error) {
+	var users []*models.User
+	if err := r.db.WithContext(ctx).Order(clause.OrderByColumn{Column: clause.Column{Name: "created_at"}}).Find(&users).Error; err != nil {
+		return nil, translateError(err)
+	}
+	return users, nil
+}
+
+// UpdateUser persists the changes made to an existing user
+func (r *UserRepository) UpdateUser(ctx context.Context, user *models.User) (*models.User, error) {
+	user.UpdatedAt = time.Now().Unix()
+
+	res := r.db.WithContext(ctx).Model(&models.User{}).Where("id = ?", user.ID).Select("*").Omit("id", "created_at").Updates(user)
+	if res.Error != nil {
+		return nil, translateError(res.Error)
+	}
+	if res.RowsAffected == 0 {
+		return nil, models.ErrNotFound
+	}
+	return user, nil
+}
+
+// DeleteUser removes the user with the given id
+func (r *UserRepository) DeleteUser(ctx context.Context, id string) error {
+	res := r.db.WithContext(ctx).Where("id = ?", id).Delete(&models.User{})
+	if res.Error != nil {
+		return translateError(res.Error)
+	}
+	if res.RowsAffected == 0 {
+		return models.ErrNotFound
+	}
+	return nil
+}
+
+// translateError maps gorm errors to the storage-agnostic errors of the models package
+func translateError(err error) error {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return models.ErrNotFound
+	case errors.Is(err, gorm.ErrDuplicatedKey):
+		return models.ErrDuplicate
+	default:
+		return err
+	}
+}
@@ -0,0 +1,62 @@
+package sql
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"server/database/models"
+)
+
+// VerificationRequestRepository is the gorm implementation of database.VerificationRequestRepository
+type VerificationRequestRepository struct {
+	db *gorm.DB
+}
+
+// NewVerificationRequestRepository returns a verification request repository backed by the given gorm connection
+func NewVerificationRequestRepository(db *gorm.DB) *VerificationRequestRepository {
+	return &VerificationRequestRepository{db: db}
+}
+
+// CreateVerificationRequest stores a new request
+func (r *VerificationRequestRepository) CreateVerificationRequest(ctx context.Context, req *models.VerificationRequest) (*models.VerificationRequest, error) {
+	if req.ID == "" {
+		req.ID = uuid.New().String()
+	}
+	req.CreatedAt = time.Now().Unix()
+
+	if err := r.db.WithContext(ctx).Create(req).Error; err != nil {
+		return nil, translateError(err)
+	}
+	return req, nil
+}
+
+// GetVerificationRequestByToken returns the request with the given hashed token
+func (r *VerificationRequestRepository) GetVerificationRequestByToken(ctx context.Context, token string) (*models.VerificationRequest, error) {
+	var req models.VerificationRequest
+	if err := r.db.WithContext(ctx).Where("token = ?", token).First(&req).Error; err != nil {
+		return nil, translateError(err)
+	}
+	return &req, nil
+}
+
+// DeleteVerificationRequest removes the request with the given id
+func (r *VerificationRequestRepository) DeleteVerificationRequest(ctx context.Context, id string) error {
+	res := r.db.WithContext(ctx).Where("id = ?", id).Delete(&models.VerificationRequest{})
+	if res.Error != nil {
+		return translateError(res.Error)
+	}
+	if res.RowsAffected == 0 {
+		return models.ErrNotFound
+	}
+	return nil
+}
+
+// DeleteVerificationRequestsByEmail removes every request of the given identifier for the email
+func (r *VerificationRequestRepository) DeleteVerificationRequestsByEmail(ctx context.Context, email, identifier string) error {
+	return translateError(r.db.WithContext(ctx).
+		Where("email = ? AND identifier = ?", email, identifier).
+		Delete(&models.VerificationRequest{}).Error)
+}
@@ -0,0 +1,39 @@
+package email
+
+import (
+	"context"
+	"errors"
+
+	"server/config"
+)
+
+// ErrDisabled is returned by the sender used when IS_EMAIL_SERVICE_ENABLED is off
+var ErrDisabled = errors.New("email service is disabled")
+
+// Message is an email to deliver
+type Message struct {
+	To      []string
+	Subject string
+	// HTML is the body of the email
+	HTML string
+}
+
+// Sender delivers emails
+type Sender interface {
+	Send(ctx context.Context, msg *Message) error
+}
+
+// New returns the SMTP sender when the email service is enabled, otherwise a
+// sender rejecting every message with ErrDisabled
+func New(cfg *config.Config) Sender {
+	if !cfg.IsEmailServiceEnabled {
+		return disabledSender{}
+	}
+	return NewSMTPSender(cfg)
+}
+
+type disabledSender struct{}
+
+func (disabledSender) Send(context.Context, *Message) error {
+	return ErrDisabled
+}
@@ -0,0 +1,91 @@
+package email
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"time"
+)
+
+// TemplateData is available to every email template
+type TemplateData struct {
+	Name string
+	// Link is the action URL of the email
+	Link string
+	// ExpiresIn is the lifetime of the link
+	ExpiresIn time.Duration
+}
+
+var funcs = template.FuncMap{"duration": formatDuration}
+
+var verifyEmailTemplate = template.Must(template.New("verify_email").Funcs(funcs).Parse(`<!DOCTYPE html>
+<html>
+  <body style="font-family: sans-serif">
+    <p>Hi {{.Name}},</p>
+    <p>Please confirm your email address by clicking the link below.</p>
+    <p><a href="{{.Link}}">Verify email</a></p>
+    <p>This link expires in {{duration .ExpiresIn}}. If you did not sign up, you can ignore this email.</p>
+  </body>
+</html>`))
+
+// VerifyEmail builds the email sent to confirm an email address
+func VerifyEmail(to string, data TemplateData) (*Message, error) {
+	return render(to, "Verify your email address", verifyEmailTemplate, data)
+}
+
+var resetPasswordTemplate = template.Must(template.New("reset_password").Funcs(funcs).Parse(`<!DOCTYPE html>
+<html>
+  <body style="font-family: sans-serif">
+    <p>Hi {{.Name}},</p>
+    <p>We received a request to reset your password. Click the link below to choose a new one.</p>
+    <p><a href="{{.Link}}">Reset password</a></p>
+    <p>This link expires in {{duration .ExpiresIn}}. If you did not request a password reset, you can ignore this email.</p>
+  </body>
+</html>`))
+
+// ResetPassword builds the email sent to reset a forgotten password
+func ResetPassword(to string, data TemplateData) (*Message, error) {
+	return render(to, "Reset your password", resetPasswordTemplate, data)
+}
+
+var magicLinkTemplate = template.Must(template.New("magic_link_login").Funcs(funcs).Parse(`<!DOCTYPE html>
+<html>
+  <body style="font-family: sans-serif">
+    <p>Hi{{if .Name}} {{.Name}}{{end}},</p>
+    <p>Click the link below to log in.</p>
+    <p><a href="{{.Link}}">Log in</a></p>
+    <p>This link expires in {{duration .ExpiresIn}} and can only be used once. If you did not try to log in, you can ignore this email.</p>
+  </body>
+</html>`))
+
+// MagicLinkLogin builds the email carrying a passwordless login link
+func MagicLinkLogin(to string, data TemplateData) (*Message, error) {
+	return render(to, "Your login link", magicLinkTemplate, data)
+}
+
+func render(to, subject string, tmpl *template.Template, data TemplateData) (*Message, error) {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return nil, err
+	}
+	return &Message{To: []string{to}, Subject: subject, HTML: buf.String()}, nil
+}
+
+// formatDuration renders d in the largest whole unit, e.g. "24 hours"
+func formatDuration(d time.Duration) string {
+	unit := func(n int64, name string) string {
+		if n == 1 {
+			return fmt.Sprintf("1 %s", name)
+		}
+		return fmt.Sprintf("%d %ss", n, name)
+	}
+
+	switch {
+	case d >= time.Hour && d%time.Hour == 0:
+		return unit(int64(d/time.Hour), "hour")
+	case d >= time.Minute:
+		return unit(int64(d/time.Minute), "minute")
+	default:
+		return unit(int64(d/time.Second), "second")
+	}
+}
@@ -0,0 +1,51 @@
+package graph
+
+import (
+	"context"
+	"time"
+
+	"server/cookie"
+	"server/database/models"
+	"server/middlewares"
+)
+
+// adminSessionTTL is the lifetime of the admin cookie set by adminLogin
+const adminSessionTTL = 24 * time.Hour
+
+// setAdminCookie stores the admin session token in the admin cookie
+func (r *Resolver) setAdminCookie(ctx context.Context, adminToken string) {
+	if gc, err := middlewares.GinContextFromContext(ctx); err == nil {
+		cookie.SetAdmin(gc, r.Config, adminToken, adminSessionTTL)
+	}
+}
+
+// adminCookie returns the admin session token sent by the browser, if any
+func adminCookie(ctx context.Context) string {
+	gc, err := middlewares.GinContextFromContext(ctx)
+	if err != nil {
+		return ""
+	}
+	return cookie.GetAdmin(gc)
+}
+
+// deleteAdminCookie expires the admin cookie of the browser
+func (r *Resolver) deleteAdminCookie(ctx context.Context) {
+	if gc, err := middlewares.GinContextFromContext(ctx); err == nil {
+		cookie.DeleteAdmin(gc, r.Config)
+	}
+}
+
+// deleteVerificationRequests discards the pending tokens sent to email, so
+// they cannot be used by a later account with the same address
+func (r *Resolver) deleteVerificationRequests(ctx context.Context, email string) error {
+	for _, identifier := range []string{
+		models.VerificationTypeVerifyEmail,
+		models.VerificationTypeForgotPassword,
+		models.VerificationTypeMagicLinkLogin,
+	} {
+		if err := r.DB.DeleteVerificationRequestsByEmail(ctx, email, identifier); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+package graph
+
+import (
+	"context"
+
+	"github.com/vektah/gqlparser/v2/gqlerror"
+
+	"server/database/models"
+	"server/graph/model"
+	"server/refs"
+	"server/token"
+	"server/validators"
+)
+
+// defaultScope is granted when a login does not request any scope
+var defaultScope = []string{"openid", "email", "profile"}
+
+// newAuthResponse starts a session limited to the given roles, or all the
+// roles of the user when nil, and wraps its tokens in an AuthResponse. amr is
+// the authentication method the user just used. Users who need a TOTP code
+// get an mfa token instead, see newMFAResponse.
+func (r *Resolver) newAuthResponse(ctx context.Context, user *models.User, roles []string, amr, message string) (*model.AuthResponse, error) {
+	if r.isMFARequired(user) {
+		return r.newMFAResponse(ctx, user, roles, amr)
+	}
+	tokens, err := r.startSession(ctx, user, token.Grant{Roles: roles}, authenticatedBy(amr))
+	if err != nil {
+		return nil, internalError(ctx, err)
+	}
+	r.setSessionCookie(ctx, tokens)
+	return authResponse(user, tokens, message), nil
+}
+
+// authResponse wraps issued tokens in an AuthResponse
+func authResponse(user *models.User, tokens *token.AuthTokens, message string) *model.AuthResponse {
+	expiresIn := int(tokens.ExpiresIn)
+	return &model.AuthResponse{
+		Message:      message,
+		AccessToken:  refs.NewStringRef(tokens.AccessToken),
+		RefreshToken: refs.NewStringRef(tokens.RefreshToken),
+		ExpiresIn:    &expiresIn,
+		User:         user.AsAPIUser(),
+	}
+}
+
+// validatePassword checks a new password against the password policy
+func (r *Resolver) validatePassword(ctx context.Context, password, email, name string) *gqlerror.Error {
+	violations := validators.NewPasswordPolicy(r.Config).Validate(password, email, name)
+	if len(violations) > 0 {
+		return passwordPolicyError(ctx, violations)
+	}
+	return nil
+}
@@ -0,0 +1,264 @@
+package graph
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+
+	"server/crypto"
+	"server/database/models"
+	"server/sessionstore"
+	"server/token"
+)
+
+// authorizationCodeTTL is the lifetime of the codes issued by /authorize
+const authorizationCodeTTL = 10 * time.Minute
+
+// PKCE code challenge methods
+const (
+	CodeChallengeMethodS256  = "S256"
+	CodeChallengeMethodPlain = "plain"
+)
+
+var (
+	// ErrInvalidClient is returned for unknown clients and wrong secrets
+	ErrInvalidClient = errors.New("invalid client")
+	// ErrInvalidGrant is returned for authorization codes that are unknown,
+	// expired, already used or do not match the token request
+	ErrInvalidGrant = errors.New("invalid authorization code")
+	// ErrInvalidScope is returned for the openid scope when the server cannot
+	// issue ID tokens
+	ErrInvalidScope = errors.New("the openid scope requires an RS or ES JWT_TYPE")
+)
+
+// AuthorizationCode is what an authorization code stands for, kept in the
+// session store until the client exchanges it
+type AuthorizationCode struct {
+	ClientID    string `json:"client_id"`
+	RedirectURI string `json:"redirect_uri"`
+	// RedirectURIProvided records whether the authorization request named the
+	// redirect URI, the token request must then repeat it
+	RedirectURIProvided bool     `json:"redirect_uri_provided"`
+	CodeChallenge       string   `json:"code_challenge"`
+	CodeChallengeMethod string   `json:"code_challenge_method"`
+	Scope               []string `json:"scope"`
+	Nonce               string   `json:"nonce"`
+	UserID              string   `json:"user_id"`
+	// SessionID is the browser session the code was issued from
+	SessionID string   `json:"session_id"`
+	Roles     []string `json:"roles"`
+}
+
+// CodeExchange is a token request with grant_type=authorization_code
+type CodeExchange struct {
+	Code         string
+	ClientID     string
+	ClientSecret string
+	RedirectURI  string
+	CodeVerifier string
+}
+
+// RequestedScope returns the space separated scope of an OAuth request, the
+// default scope when it is empty. The openid scope asks for ID tokens, so it
+// is left out of the default scope and refused with ErrInvalidScope when the
+// tokens are signed with an HMAC secret.
+func (r *Resolver) RequestedScope(scope string) ([]string, error) {
+	if fields := strings.Fields(scope); len(fields) > 0 {
+		if slices.Contains(fields, "openid") && !r.Tokens.IssuesIDTokens() {
+			return nil, ErrInvalidScope
+		}
+		return fields, nil
+	}
+	if !r.Tokens.IssuesIDTokens() {
+		return slices.DeleteFunc(slices.Clone(defaultScope), func(s string) bool { return s == "openid" }), nil
+	}
+	return append([]string{}, defaultScope...), nil
+}
+
+// OAuthClient returns the registered client, ErrInvalidClient when unknown
+func (r *Resolver) OAuthClient(ctx context.Context, clientID string) (*models.OAuthClient, error) {
+	if clientID == "" {
+		return nil, ErrInvalidClient
+	}
+	client, err := r.DB.GetOAuthClientByClientID(ctx, clientID)
+	if err != nil {
+		if errors.Is(err, models.ErrNotFound) {
+			return nil, ErrInvalidClient
+		}
+		return nil, err
+	}
+	return client, nil
+}
+
+// AuthenticateClient returns the client of a token request, checking the
+// secret of confidential clients. ErrInvalidClient is returned for unknown
+// clients and wrong secrets.
+func (r *Resolver) AuthenticateClient(ctx context.Context, clientID, secret string) (*models.OAuthClient, error) {
+	client, err := r.OAuthClient(ctx, clientID)
+	if err != nil {
+		return nil, err
+	}
+	if !authenticateClient(client, secret) {
+		return nil, ErrInvalidClient
+	}
+	return client, nil
+}
+
+// authenticateClient checks the secret of confidential clients, public
+// clients must not send one
+func authenticateClient(client *models.OAuthClient, secret string) bool {
+	if client.IsPublic() {
+		return secret == ""
+	}
+	return subtle.ConstantTimeCompare([]byte(crypto.HashToken(secret)), []byte(client.ClientSecret)) == 1
+}
+
+// CreateAuthorizationCode issues a single use code for the session of the
+// user. The code carries the roles of the session.
+func (r *Resolver) CreateAuthorizationCode(ctx context.Context, user *models.User, session *sessionstore.Session, code AuthorizationCode) (string, error) {
+	code.UserID = user.ID
+	code.SessionID = session.ID
+	code.Roles = sessionRoles(user, session)
+	value, err := json.Marshal(code)
+	if err != nil {
+		return "", err
+	}
+
+	plain, err := crypto.GenerateToken()
+	if err != nil {
+		return "", err
+	}
+	if err := r.Sessions.SetState(ctx, authorizationCodeKey(plain), string(value), authorizationCodeTTL); err != nil {
+		return "", err
+	}
+	return plain, nil
+}
+
+// IssueSessionAccessToken issues an access token for the implicit flow. Like
+// an exchanged code it opens a session of the client, separate from the
+// browser session, whose refresh token is not handed out. An ID token for the
+// client is added when the scope includes openid.
+func (r *Resolver) IssueSessionAccessToken(ctx context.Context, user *models.User, session *sessionstore.Session, clientID string, scope []string, nonce string) (*token.AuthTokens, error) {
+	auth := authentication{Time: session.AuthenticatedAt(), Methods: session.AMR}
+	tokens, err := r.startClientSession(ctx, user, clientID, token.Grant{Roles: sessionRoles(user, session), Scope: scope}, auth)
+	if err != nil {
+		return nil, err
+	}
+	tokens.RefreshToken = ""
+	if err := r.addIDToken(tokens, user, session, clientID, scope, nonce); err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+// ExchangeAuthorizationCode redeems an authorization code for a new session
+// of the client. Codes are single use and only valid while the browser
+// session they were issued from is.
+func (r *Resolver) ExchangeAuthorizationCode(ctx context.Context, ex CodeExchange) (*models.User, *token.AuthTokens, error) {
+	value, err := r.Sessions.TakeState(ctx, authorizationCodeKey(ex.Code))
+	if err != nil {
+		if errors.Is(err, sessionstore.ErrNotFound) {
+			return nil, nil, ErrInvalidGrant
+		}
+		return nil, nil, err
+	}
+	var code AuthorizationCode
+	if err := json.Unmarshal([]byte(value), &code); err != nil {
+		return nil, nil, err
+	}
+
+	client, err := r.AuthenticateClient(ctx, ex.ClientID, ex.ClientSecret)
+	if err != nil {
+		return nil, nil, err
+	}
+	if code.ClientID != client.ClientID {
+		return nil, nil, ErrInvalidGrant
+	}
+	if code.RedirectURIProvided && ex.RedirectURI != code.RedirectURI {
+		return nil, nil, ErrInvalidGrant
+	}
+	if !verifyCodeChallenge(code.CodeChallenge, code.CodeChallengeMethod, ex.CodeVerifier) {
+		return nil, nil, ErrInvalidGrant
+	}
+
+	session, err := r.Sessions.GetSession(ctx, code.SessionID)
+	if err != nil {
+		if errors.Is(err, sessionstore.ErrNotFound) {
+			return nil, nil, ErrInvalidGrant
+		}
+		return nil, nil, err
+	}
+	user, err := r.DB.GetUserByID(ctx, code.UserID)
+	if err != nil {
+		if errors.Is(err, models.ErrNotFound) {
+			return nil, nil, ErrInvalidGrant
+		}
+		return nil, nil, err
+	}
+	if session.UserID != user.ID || user.IsSessionRevoked(time.Unix(session.CreatedAt, 0)) {
+		return nil, nil, ErrInvalidGrant
+	}
+
+	auth := authentication{Time: session.AuthenticatedAt(), Methods: session.AMR}
+	tokens, err := r.startClientSession(ctx, user, client.ClientID, token.Grant{Roles: code.Roles, Scope: code.Scope}, auth)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := r.addIDToken(tokens, user, session, client.ClientID, code.Scope, code.Nonce); err != nil {
+		return nil, nil, err
+	}
+	return user, tokens, nil
+}
+
+// addIDToken issues an ID token asserting the authentication of the browser
+// session when the openid scope was requested
+func (r *Resolver) addIDToken(tokens *token.AuthTokens, user *models.User, session *sessionstore.Session, clientID string, scope []string, nonce string) error {
+	if !slices.Contains(scope, "openid") {
+		return nil
+	}
+	idToken, err := r.Tokens.CreateIDToken(user, token.IDTokenParams{
+		Audience:  clientID,
+		Nonce:     nonce,
+		SessionID: session.ID,
+		AuthTime:  session.AuthenticatedAt(),
+		AMR:       session.AMR,
+		Scope:     scope,
+	})
+	if err != nil {
+		return err
+	}
+	tokens.IDToken = idToken
+	return nil
+}
+
+// verifyCodeChallenge checks the PKCE code verifier, codes issued without a
+// challenge to confidential clients need none
+func verifyCodeChallenge(challenge, method, verifier string) bool {
+	if challenge == "" {
+		return verifier == ""
+	}
+	if verifier == "" {
+		return false
+	}
+	expected := verifier
+	if method == CodeChallengeMethodS256 {
+		expected = s256(verifier)
+	}
+	return subtle.ConstantTimeCompare([]byte(expected), []byte(challenge)) == 1
+}
+
+// s256 is the S256 PKCE code challenge of the verifier
+func s256(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func authorizationCodeKey(code string) string {
+	return "authorization_code:" + crypto.HashToken(code)
+}
@@ -0,0 +1,78 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"server/auth"
+	"server/constants"
+	"server/cookie"
+	"server/database/models"
+	"server/middlewares"
+	"server/sessionstore"
+	"server/token"
+)
+
+// ErrNoSession is returned by CurrentSession when the request carries no
+// valid session
+var ErrNoSession = errors.New("no valid session")
+
+// CurrentSession returns the user and session of the principal set by the
+// authentication middleware. The session is read again since it may have been
+// revoked by a previous operation of the same request.
+func (r *Resolver) CurrentSession(ctx context.Context) (*models.User, *sessionstore.Session, error) {
+	principal, ok := auth.PrincipalFromContext(ctx)
+	if !ok {
+		return nil, nil, ErrNoSession
+	}
+
+	session, err := r.Sessions.GetSession(ctx, principal.SessionID)
+	if err != nil {
+		if errors.Is(err, sessionstore.ErrNotFound) {
+			return nil, nil, ErrNoSession
+		}
+		return nil, nil, err
+	}
+
+	user, err := r.DB.GetUserByID(ctx, principal.UserID)
+	if err != nil {
+		if errors.Is(err, models.ErrNotFound) {
+			return nil, nil, ErrNoSession
+		}
+		return nil, nil, err
+	}
+	if session.UserID != user.ID || user.IsSessionRevoked(time.Unix(session.CreatedAt, 0)) {
+		return nil, nil, ErrNoSession
+	}
+	return user, session, nil
+}
+
+// sessionError converts a CurrentSession error into a GraphQL error
+func sessionError(ctx context.Context, err error) error {
+	if errors.Is(err, ErrNoSession) {
+		return newError(ctx, constants.ErrCodeUnauthenticated, "unauthenticated")
+	}
+	return internalError(ctx, err)
+}
+
+// sessionRoles returns the roles granted to the session, which are still held
+// by the user
+func sessionRoles(user *models.User, session *sessionstore.Session) []string {
+	return session.GrantedRoles(user.RoleList())
+}
+
+// setSessionCookie stores the refresh token in the session cookie of the
+// browser when the request comes through the HTTP router
+func (r *Resolver) setSessionCookie(ctx context.Context, tokens *token.AuthTokens) {
+	if gc, err := middlewares.GinContextFromContext(ctx); err == nil {
+		cookie.SetSession(gc, r.Config, tokens.RefreshToken)
+	}
+}
+
+// deleteSessionCookie expires the session cookie of the browser
+func (r *Resolver) deleteSessionCookie(ctx context.Context) {
+	if gc, err := middlewares.GinContextFromContext(ctx); err == nil {
+		cookie.DeleteSession(gc, r.Config)
+	}
+}
@@ -0,0 +1,50 @@
+package graph
+
+import (
+	"context"
+
+	"github.com/99designs/gqlgen/graphql"
+
+	"server/auth"
+	"server/constants"
+)
+
+// IsAuthenticated implements @isAuthenticated: the field requires a principal
+func IsAuthenticated(ctx context.Context, obj interface{}, next graphql.Resolver) (interface{}, error) {
+	if _, ok := auth.PrincipalFromContext(ctx); !ok {
+		return nil, newError(ctx, constants.ErrCodeUnauthenticated, "unauthenticated")
+	}
+	return next(ctx)
+}
+
+// HasRole implements @hasRole: the principal must have one of the roles
+func HasRole(ctx context.Context, obj interface{}, next graphql.Resolver, roles []string) (interface{}, error) {
+	principal, ok := auth.PrincipalFromContext(ctx)
+	if !ok {
+		return nil, newError(ctx, constants.ErrCodeUnauthenticated, "unauthenticated")
+	}
+	for _, role := range roles {
+		if principal.HasRole(role) {
+			return next(ctx)
+		}
+	}
+	return nil, newError(ctx, constants.ErrCodeForbidden, "missing required role")
+}
+
+// IsAdmin implements @isAdmin: the request must carry the admin secret or a
+// principal with the admin role
+func IsAdmin(ctx context.Context, obj interface{}, next graphql.Resolver) (interface{}, error) {
+	if auth.IsAdmin(ctx) {
+		return next(ctx)
+	}
+	return HasRole(ctx, obj, next, []string{constants.RoleAdmin})
+}
+
+// IsSuperAdmin implements @isSuperAdmin: the request must carry the admin
+// secret, the admin role of a user is not enough
+func IsSuperAdmin(ctx context.Context, obj interface{}, next graphql.Resolver) (interface{}, error) {
+	if !auth.IsAdmin(ctx) {
+		return nil, newError(ctx, constants.ErrCodeUnauthenticated, "admin authentication required")
+	}
+	return next(ctx)
+}
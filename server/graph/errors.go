@@ -0,0 +1,36 @@
+package graph
+
+import (
+	"context"
+
+	"github.com/99designs/gqlgen/graphql"
+	log "github.com/sirupsen/logrus"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+
+	"server/constants"
+	"server/validators"
+)
+
+// newError builds a GraphQL error carrying the given code in its extensions
+func newError(ctx context.Context, code, message string) *gqlerror.Error {
+	return &gqlerror.Error{
+		Path:       graphql.GetPath(ctx),
+		Message:    message,
+		Extensions: map[string]interface{}{"code": code},
+	}
+}
+
+// internalError logs the underlying error and hides it from the client
+func internalError(ctx context.Context, err error) *gqlerror.Error {
+	log.WithError(err).WithField("path", graphql.GetPath(ctx).String()).Error("resolver failed")
+	return newError(ctx, constants.ErrCodeInternal, "internal server error")
+}
+
+// passwordPolicyError lists every broken password rule in the extensions so
+// clients can render them next to the password field
+func passwordPolicyError(ctx context.Context, violations []validators.PasswordViolation) *gqlerror.Error {
+	err := newError(ctx, constants.ErrCodeBadUserInput, "password does not meet the password policy")
+	err.Extensions["field"] = "password"
+	err.Extensions["rules"] = violations
+	return err
+}
@@ -0,0 +1,415 @@
+package graph
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"image/png"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pquerna/otp"
+	"github.com/pquerna/otp/totp"
+
+	"server/constants"
+	"server/crypto"
+	"server/database/models"
+	"server/graph/model"
+	"server/refs"
+	"server/sessionstore"
+	"server/token"
+)
+
+// MFALoginTTL is the time users have to enter their TOTP code once their
+// password is checked
+const MFALoginTTL = 5 * time.Minute
+
+const (
+	// totpEnrollmentTTL is the time users have to confirm the authenticator
+	// app they enrol with enrollTotp
+	totpEnrollmentTTL = 10 * time.Minute
+	// maxMFAAttempts is the number of codes that can be entered for a login
+	// or a session within mfaLockoutTTL, further codes are refused until it
+	// expires
+	maxMFAAttempts = 5
+	// maxUserMFAAttempts is the number of codes a user can enter within
+	// mfaLockoutTTL across all their logins and sessions
+	maxUserMFAAttempts = 50
+	// mfaLockoutTTL is the window attempts are counted in
+	mfaLockoutTTL = 15 * time.Minute
+	// recoveryCodeCount is the number of recovery codes given at enrolment
+	recoveryCodeCount = 10
+	// totpQRCodeSize is the width and height of the QR code in pixels
+	totpQRCodeSize = 256
+	// recoveryCodeLockTTL bounds how long a failed request can keep the
+	// recovery codes of a user locked
+	recoveryCodeLockTTL = 10 * time.Second
+)
+
+// totpPeriod is the lifetime of a code, the one authenticator apps default to
+const totpPeriod = 30
+
+// mfaLogin is a login waiting for a TOTP code, kept in the session store
+// under the hash of its mfa token
+type mfaLogin struct {
+	UserID string   `json:"user_id"`
+	Roles  []string `json:"roles"`
+	Scope  []string `json:"scope,omitempty"`
+	// Methods are the authentication methods the user already passed
+	Methods []string `json:"methods"`
+	// Secret is the authenticator app being enrolled by users who have none
+	Secret string `json:"secret,omitempty"`
+}
+
+// RedirectLogin is the outcome of a login ending with a redirect, through a
+// link or an external provider: the tokens of the new session, or the mfa
+// token to complete it with verifyTotp when a TOTP code is required
+type RedirectLogin struct {
+	Tokens   *token.AuthTokens
+	MFAToken string
+	// TOTPEnrollment is the authenticator app to enrol with the first code,
+	// for users who have none
+	TOTPEnrollment *model.TOTPEnrollment
+}
+
+// isMFAEnabled reports whether logins can ask for TOTP codes
+func (r *Resolver) isMFAEnabled() bool {
+	return !r.Config.DisableMultiFactorAuthentication && !r.Config.DisableTOTPLogin
+}
+
+// isMFARequired reports whether logins of the user, whatever the first
+// factor, must be completed with a TOTP code
+func (r *Resolver) isMFARequired(user *models.User) bool {
+	return r.isMFAEnabled() && (r.Config.EnforceMultiFactorAuthentication || user.IsTOTPEnabled())
+}
+
+// newMFAResponse holds the login back until verifyTotp is called with a code
+// of the authenticator app of the user, see startMFALogin. amr is the
+// authentication method the user just used.
+func (r *Resolver) newMFAResponse(ctx context.Context, user *models.User, roles []string, amr string) (*model.AuthResponse, error) {
+	mfaToken, enrollment, err := r.startMFALogin(ctx, user, token.Grant{Roles: roles}, amr)
+	if err != nil {
+		return nil, internalError(ctx, err)
+	}
+	res := &model.AuthResponse{
+		Message:        "enter the code of your authenticator app",
+		MfaRequired:    true,
+		MfaToken:       refs.NewStringRef(mfaToken),
+		TotpEnrollment: enrollment,
+	}
+	if enrollment != nil {
+		res.Message = "add the secret to your authenticator app and enter its code"
+	}
+	return res, nil
+}
+
+// startRedirectLogin starts the session of a login ending with a redirect,
+// or holds it back like newMFAResponse when the user needs a TOTP code
+func (r *Resolver) startRedirectLogin(ctx context.Context, user *models.User, grant token.Grant, amr string) (*RedirectLogin, error) {
+	if r.isMFARequired(user) {
+		mfaToken, enrollment, err := r.startMFALogin(ctx, user, grant, amr)
+		if err != nil {
+			return nil, err
+		}
+		return &RedirectLogin{MFAToken: mfaToken, TOTPEnrollment: enrollment}, nil
+	}
+	tokens, err := r.startSession(ctx, user, grant, authenticatedBy(amr))
+	if err != nil {
+		return nil, err
+	}
+	return &RedirectLogin{Tokens: tokens}, nil
+}
+
+// startMFALogin stores the login until a code is given and returns its mfa
+// token. Users who have no authenticator app get a new secret to enrol,
+// which the first code confirms.
+func (r *Resolver) startMFALogin(ctx context.Context, user *models.User, grant token.Grant, amr string) (string, *model.TOTPEnrollment, error) {
+	login := &mfaLogin{
+		UserID:  user.ID,
+		Roles:   grant.Roles,
+		Scope:   grant.Scope,
+		Methods: []string{amr},
+	}
+	var enrollment *model.TOTPEnrollment
+	if !user.IsTOTPEnabled() {
+		secret, e, err := r.generateTOTP(user)
+		if err != nil {
+			return "", nil, err
+		}
+		login.Secret, enrollment = secret, e
+	}
+
+	mfaToken, err := crypto.GenerateToken()
+	if err != nil {
+		return "", nil, err
+	}
+	value, err := json.Marshal(login)
+	if err != nil {
+		return "", nil, err
+	}
+	if err := r.Sessions.SetState(ctx, mfaLoginKey(mfaToken), string(value), MFALoginTTL); err != nil {
+		return "", nil, err
+	}
+	return mfaToken, enrollment, nil
+}
+
+// completeMFALogin checks the code given for the pending login and starts the
+// session. Codes of an authenticator app being enrolled enable it and the
+// recovery codes are returned along with the tokens.
+func (r *Resolver) completeMFALogin(ctx context.Context, mfaToken, code string) (*model.AuthResponse, error) {
+	login, err := r.getMFALogin(ctx, mfaToken)
+	if err != nil {
+		if errors.Is(err, sessionstore.ErrNotFound) {
+			return nil, newError(ctx, constants.ErrCodeUnauthenticated, "invalid or expired mfa token")
+		}
+		return nil, internalError(ctx, err)
+	}
+	user, err := r.DB.GetUserByID(ctx, login.UserID)
+	if err != nil {
+		if errors.Is(err, models.ErrNotFound) {
+			return nil, newError(ctx, constants.ErrCodeUnauthenticated, "invalid or expired mfa token")
+		}
+		return nil, internalError(ctx, err)
+	}
+	if login.Secret == "" && !user.IsTOTPEnabled() {
+		// the authenticator app was removed since the first factor was checked
+		if err := r.Sessions.DeleteState(ctx, mfaLoginKey(mfaToken)); err != nil {
+			return nil, internalError(ctx, err)
+		}
+		return nil, newError(ctx, constants.ErrCodeUnauthenticated, "invalid or expired mfa token")
+	}
+
+	if allowed, err := r.countMFAAttempt(ctx, user.ID, mfaLoginKey(mfaToken)); err != nil {
+		return nil, internalError(ctx, err)
+	} else if !allowed {
+		return nil, newError(ctx, constants.ErrCodeUnauthenticated, "too many invalid codes, please try again later")
+	}
+	var ok bool
+	if login.Secret != "" {
+		ok, err = r.validateTOTP(ctx, user.ID, login.Secret, code)
+	} else {
+		ok, err = r.verifySecondFactor(ctx, user, code)
+	}
+	if err != nil {
+		return nil, internalError(ctx, err)
+	}
+	if !ok {
+		return nil, newError(ctx, constants.ErrCodeUnauthenticated, "invalid code")
+	}
+
+	// concurrent requests with a valid code only complete the login once
+	if _, err := r.Sessions.TakeState(ctx, mfaLoginKey(mfaToken)); err != nil {
+		if errors.Is(err, sessionstore.ErrNotFound) {
+			return nil, newError(ctx, constants.ErrCodeUnauthenticated, "invalid or expired mfa token")
+		}
+		return nil, internalError(ctx, err)
+	}
+	if err := r.resetMFAAttempts(ctx, user.ID, mfaLoginKey(mfaToken)); err != nil {
+		return nil, internalError(ctx, err)
+	}
+	var recoveryCodes []string
+	if login.Secret != "" {
+		if recoveryCodes, err = r.enableTOTP(ctx, user, login.Secret); err != nil {
+			return nil, internalError(ctx, err)
+		}
+	}
+
+	grant := token.Grant{Roles: login.Roles, Scope: login.Scope}
+	tokens, err := r.startSession(ctx, user, grant, authenticatedBy(append(login.Methods, amrOTP)...))
+	if err != nil {
+		return nil, internalError(ctx, err)
+	}
+	r.setSessionCookie(ctx, tokens)
+	res := authResponse(user, tokens, "logged in successfully")
+	res.RecoveryCodes = recoveryCodes
+	return res, nil
+}
+
+// countMFAAttempt counts a code entered by the user, before it is checked so
+// concurrent guesses count too. Attempts are counted per login or session,
+// named by attempt, so that guesses of someone who knows the password do not
+// lock the user out of their own logins. A much higher limit per user still
+// bounds the guesses made by starting new logins.
+func (r *Resolver) countMFAAttempt(ctx context.Context, userID, attempt string) (bool, error) {
+	n, err := r.Sessions.IncrState(ctx, mfaAttemptsKey(userID, attempt), mfaLockoutTTL)
+	if err != nil {
+		return false, err
+	}
+	total, err := r.Sessions.IncrState(ctx, mfaAttemptsKey(userID, ""), mfaLockoutTTL)
+	if err != nil {
+		return false, err
+	}
+	return n <= maxMFAAttempts && total <= maxUserMFAAttempts, nil
+}
+
+// resetMFAAttempts forgets the attempts of the user once a code was valid
+func (r *Resolver) resetMFAAttempts(ctx context.Context, userID, attempt string) error {
+	if err := r.Sessions.DeleteState(ctx, mfaAttemptsKey(userID, attempt)); err != nil {
+		return err
+	}
+	return r.Sessions.DeleteState(ctx, mfaAttemptsKey(userID, ""))
+}
+
+// getMFALogin returns sessionstore.ErrNotFound for unknown or expired tokens
+func (r *Resolver) getMFALogin(ctx context.Context, mfaToken string) (*mfaLogin, error) {
+	value, err := r.Sessions.GetState(ctx, mfaLoginKey(mfaToken))
+	if err != nil {
+		return nil, err
+	}
+	var login mfaLogin
+	if err := json.Unmarshal([]byte(value), &login); err != nil {
+		return nil, err
+	}
+	return &login, nil
+}
+
+// generateTOTP returns a new secret for the user and how to add it to an
+// authenticator app
+func (r *Resolver) generateTOTP(user *models.User) (string, *model.TOTPEnrollment, error) {
+	key, err := totp.Generate(totp.GenerateOpts{
+		Issuer:      r.Config.OrganizationName,
+		AccountName: user.Email,
+		Period:      totpPeriod,
+		Digits:      otp.DigitsSix,
+		Algorithm:   otp.AlgorithmSHA1,
+	})
+	if err != nil {
+		return "", nil, err
+	}
+	img, err := key.Image(totpQRCodeSize, totpQRCodeSize)
+	if err != nil {
+		return "", nil, err
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return "", nil, err
+	}
+	return key.Secret(), &model.TOTPEnrollment{
+		Secret: key.Secret(),
+		URI:    key.URL(),
+		QRCode: base64.StdEncoding.EncodeToString(buf.Bytes()),
+	}, nil
+}
+
+// enableTOTP stores the confirmed secret of the user and returns new
+// recovery codes, replacing any previous ones
+func (r *Resolver) enableTOTP(ctx context.Context, user *models.User, secret string) ([]string, error) {
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		code, err := crypto.GenerateRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+		codes[i], hashes[i] = code, hashRecoveryCode(code)
+	}
+	user.EnableTOTP(secret, hashes)
+	if _, err := r.DB.UpdateUser(ctx, user); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// verifySecondFactor checks a code of the enrolled authenticator app of the
+// user, or one of their recovery codes which is then used up. Recovery codes
+// of a user are used one at a time on a freshly loaded user, so concurrent
+// requests cannot use a code twice or bring back a used one.
+func (r *Resolver) verifySecondFactor(ctx context.Context, user *models.User, code string) (bool, error) {
+	ok, err := r.validateTOTP(ctx, user.ID, user.TOTPSecret, code)
+	if err != nil || ok {
+		return ok, err
+	}
+	hash := hashRecoveryCode(code)
+	if !user.HasRecoveryCode(hash) {
+		return false, nil
+	}
+
+	lock := recoveryCodeLockKey(user.ID)
+	n, err := r.Sessions.IncrState(ctx, lock, recoveryCodeLockTTL)
+	if err != nil {
+		return false, err
+	}
+	if n > 1 {
+		// another recovery code of the user is being used
+		return false, nil
+	}
+	defer func() {
+		_ = r.Sessions.DeleteState(context.WithoutCancel(ctx), lock)
+	}()
+	fresh, err := r.DB.GetUserByID(ctx, user.ID)
+	if err != nil {
+		return false, err
+	}
+	if !fresh.UseRecoveryCode(hash) {
+		return false, nil
+	}
+	if _, err := r.DB.UpdateUser(ctx, fresh); err != nil {
+		return false, err
+	}
+	*user = *fresh
+	return true, nil
+}
+
+// validateTOTP checks the code against the secret, accepting the previous and
+// next periods for clock drift. Each period is claimed once per user so
+// codes cannot be replayed, even by concurrent requests.
+func (r *Resolver) validateTOTP(ctx context.Context, userID, secret, code string) (bool, error) {
+	code = strings.TrimSpace(code)
+	now := time.Now().Unix() / totpPeriod
+	for _, counter := range []int64{now - 1, now, now + 1} {
+		expected, err := totp.GenerateCodeCustom(secret, time.Unix(counter*totpPeriod, 0), totp.ValidateOpts{
+			Period:    totpPeriod,
+			Digits:    otp.DigitsSix,
+			Algorithm: otp.AlgorithmSHA1,
+		})
+		if err != nil {
+			return false, err
+		}
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) != 1 {
+			continue
+		}
+
+		// a period is accepted for at most three periods, the claim outlives it
+		n, err := r.Sessions.IncrState(ctx, totpCounterKey(userID, counter), 4*totpPeriod*time.Second)
+		if err != nil {
+			return false, err
+		}
+		return n == 1, nil
+	}
+	return false, nil
+}
+
+// hashRecoveryCode ignores the case and dashes users may get wrong
+func hashRecoveryCode(code string) string {
+	code = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+	return crypto.HashToken(code)
+}
+
+func mfaLoginKey(mfaToken string) string {
+	return "mfa_login:" + crypto.HashToken(mfaToken)
+}
+
+// mfaAttemptsKey names the attempts of a login or session of the user, or
+// all the attempts of the user when attempt is empty
+func mfaAttemptsKey(userID, attempt string) string {
+	if attempt == "" {
+		return "mfa_attempts:" + userID
+	}
+	return "mfa_attempts:" + userID + ":" + attempt
+}
+
+func totpCounterKey(userID string, counter int64) string {
+	return "totp_counter:" + userID + ":" + strconv.FormatInt(counter, 10)
+}
+
+func recoveryCodeLockKey(userID string) string {
+	return "recovery_codes_lock:" + userID
+}
+
+func totpEnrollmentKey(userID string) string {
+	return "totp_enrollment:" + userID
+}
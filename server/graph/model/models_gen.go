@@ -2,6 +2,27 @@
 
 package model
 
+type AddOIDCProviderInput struct {
+	Name         string                 `json:"name"`
+	Issuer       string                 `json:"issuer"`
+	ClientID     string                 `json:"clientId"`
+	ClientSecret string                 `json:"clientSecret"`
+	Scopes       []string               `json:"scopes,omitempty"`
+	ClaimMapping *OIDCClaimMappingInput `json:"claimMapping,omitempty"`
+}
+
+type AuthResponse struct {
+	Message        string          `json:"message"`
+	AccessToken    *string         `json:"accessToken,omitempty"`
+	RefreshToken   *string         `json:"refreshToken,omitempty"`
+	ExpiresIn      *int            `json:"expiresIn,omitempty"`
+	User           *User           `json:"user,omitempty"`
+	MfaRequired    bool            `json:"mfaRequired"`
+	MfaToken       *string         `json:"mfaToken,omitempty"`
+	TotpEnrollment *TOTPEnrollment `json:"totpEnrollment,omitempty"`
+	RecoveryCodes  []string        `json:"recoveryCodes,omitempty"`
+}
+
 type CreateUserInput struct {
 	Name  string `json:"name"`
 	Email string `json:"email"`
@@ -10,11 +31,87 @@ type CreateUserInput struct {
 type Mutation struct {
 }
 
+type OIDCClaimMapping struct {
+	Email         string `json:"email"`
+	EmailVerified string `json:"emailVerified"`
+	Name          string `json:"name"`
+}
+
+type OIDCClaimMappingInput struct {
+	Email         *string `json:"email,omitempty"`
+	EmailVerified *string `json:"emailVerified,omitempty"`
+	Name          *string `json:"name,omitempty"`
+}
+
+type OIDCProvider struct {
+	ID           string            `json:"id"`
+	Name         string            `json:"name"`
+	Issuer       string            `json:"issuer"`
+	ClientID     string            `json:"clientId"`
+	Scopes       []string          `json:"scopes"`
+	ClaimMapping *OIDCClaimMapping `json:"claimMapping"`
+	CreatedAt    int               `json:"createdAt"`
+	UpdatedAt    int               `json:"updatedAt"`
+}
+
 type Query struct {
 }
 
+type RecoveryCodesResponse struct {
+	Message       string   `json:"message"`
+	RecoveryCodes []string `json:"recoveryCodes"`
+}
+
+type Response struct {
+	Message string `json:"message"`
+}
+
+type Session struct {
+	ID        string   `json:"id"`
+	Roles     []string `json:"roles"`
+	Scope     []string `json:"scope"`
+	CreatedAt int      `json:"createdAt"`
+	ExpiresAt int      `json:"expiresAt"`
+	User      *User    `json:"user"`
+}
+
+type SignUpInput struct {
+	Name            string   `json:"name"`
+	Email           string   `json:"email"`
+	Password        string   `json:"password"`
+	ConfirmPassword string   `json:"confirmPassword"`
+	Roles           []string `json:"roles,omitempty"`
+}
+
+type TOTPEnrollment struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"`
+	QRCode string `json:"qrCode"`
+}
+
+type UpdateOIDCProviderInput struct {
+	Name         string                 `json:"name"`
+	Issuer       *string                `json:"issuer,omitempty"`
+	ClientID     *string                `json:"clientId,omitempty"`
+	ClientSecret *string                `json:"clientSecret,omitempty"`
+	Scopes       []string               `json:"scopes,omitempty"`
+	ClaimMapping *OIDCClaimMappingInput `json:"claimMapping,omitempty"`
+}
+
+type UpdateUserInput struct {
+	ID            string   `json:"id"`
+	Name          *string  `json:"name,omitempty"`
+	Email         *string  `json:"email,omitempty"`
+	EmailVerified *bool    `json:"emailVerified,omitempty"`
+	Roles         []string `json:"roles,omitempty"`
+	MfaEnabled    *bool    `json:"mfaEnabled,omitempty"`
+}
+
 type User struct {
-	ID    string `json:"id"`
-	Name  string `json:"name"`
-	Email string `json:"email"`
+	ID            string   `json:"id"`
+	Name          string   `json:"name"`
+	Email         string   `json:"email"`
+	EmailVerified bool     `json:"emailVerified"`
+	Roles         []string `json:"roles"`
+	MfaEnabled    bool     `json:"mfaEnabled"`
 }
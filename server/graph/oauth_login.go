@@ -0,0 +1,259 @@
+package graph
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"server/crypto"
+	"server/database/models"
+	"server/providers"
+	"server/refs"
+	"server/sessionstore"
+	"server/token"
+	"server/validators"
+)
+
+// OAuthStateTTL is the time users have to log in at an external provider
+const OAuthStateTTL = 10 * time.Minute
+
+// Errors returned by the logins through external providers
+var (
+	// ErrUnknownOAuthProvider is returned for providers that are not enabled
+	ErrUnknownOAuthProvider = errors.New("unknown login provider")
+	// ErrInvalidOAuthRequest is returned for logins requesting an invalid
+	// redirect URI or roles
+	ErrInvalidOAuthRequest = errors.New("invalid login request")
+	// ErrInvalidOAuthState is returned when the state is unknown, expired,
+	// already used or was issued to another browser
+	ErrInvalidOAuthState = errors.New("invalid or expired login state")
+	// ErrOAuthProviderFailed is returned when the provider could not be
+	// reached, refused the code or described the user with a profile that
+	// cannot be trusted
+	ErrOAuthProviderFailed = errors.New("the login provider could not authenticate the user")
+	// ErrUnverifiedProviderEmail is returned when the provider did not share
+	// an email address it verified, which accounts are matched by
+	ErrUnverifiedProviderEmail = errors.New("the login provider did not share a verified email address")
+	// ErrSignUpDisabled is returned for new users when DISABLE_SIGN_UP is set
+	ErrSignUpDisabled = errors.New("sign up is disabled")
+)
+
+// OAuthLoginRequest is a login through an external provider
+type OAuthLoginRequest struct {
+	// RedirectURI is where the user is sent back to, APP_URL when empty
+	RedirectURI string
+	Roles       []string
+	Scope       []string
+}
+
+// oauthLoginState is what the state of a login through an external provider
+// stands for, kept in the session store until the user comes back
+type oauthLoginState struct {
+	Provider     string   `json:"provider"`
+	Nonce        string   `json:"nonce"`
+	CodeVerifier string   `json:"code_verifier"`
+	RedirectURI  string   `json:"redirect_uri"`
+	Roles        []string `json:"roles"`
+	Scope        []string `json:"scope"`
+}
+
+// OAuthProvider returns the enabled provider or the upstream OpenID Connect
+// provider registered under name, ErrUnknownOAuthProvider otherwise
+func (r *Resolver) OAuthProvider(ctx context.Context, name string) (providers.OAuthProvider, error) {
+	if provider, ok := r.OAuthProviders[name]; ok {
+		return provider, nil
+	}
+	registered, err := r.DB.GetOIDCProviderByName(ctx, name)
+	if err != nil {
+		if errors.Is(err, models.ErrNotFound) {
+			return nil, ErrUnknownOAuthProvider
+		}
+		return nil, err
+	}
+	provider, err := providers.NewOIDC(ctx, oidcConfig(registered))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrOAuthProviderFailed, err)
+	}
+	return provider, nil
+}
+
+// OAuthCallbackURL is the redirect URI registered with the provider
+func (r *Resolver) OAuthCallbackURL(name string) string {
+	return strings.TrimSuffix(r.Config.AuthorizerURL, "/") + "/oauth_callback/" + name
+}
+
+// StartOAuthLogin returns the provider page the user is sent to and the
+// state the browser must present when coming back
+func (r *Resolver) StartOAuthLogin(ctx context.Context, name string, req OAuthLoginRequest) (string, string, error) {
+	provider, err := r.OAuthProvider(ctx, name)
+	if err != nil {
+		return "", "", err
+	}
+	if req.RedirectURI == "" {
+		req.RedirectURI = r.Config.AppURL
+	}
+	if !validators.IsAllowedRedirectURI(r.Config, req.RedirectURI) {
+		return "", "", fmt.Errorf("%w: invalid redirect URI", ErrInvalidOAuthRequest)
+	}
+	for _, role := range req.Roles {
+		if !slices.Contains(r.Config.Roles, role) {
+			return "", "", fmt.Errorf("%w: invalid role: %s", ErrInvalidOAuthRequest, role)
+		}
+	}
+	if len(req.Scope) == 0 {
+		req.Scope = defaultScope
+	}
+
+	var secrets [3]string
+	for i := range secrets {
+		if secrets[i], err = crypto.GenerateToken(); err != nil {
+			return "", "", err
+		}
+	}
+	state, nonce, verifier := secrets[0], secrets[1], secrets[2]
+	value, err := json.Marshal(oauthLoginState{
+		Provider:     name,
+		Nonce:        nonce,
+		CodeVerifier: verifier,
+		RedirectURI:  req.RedirectURI,
+		Roles:        req.Roles,
+		Scope:        req.Scope,
+	})
+	if err != nil {
+		return "", "", err
+	}
+	if err := r.Sessions.SetState(ctx, oauthStateKey(state), string(value), OAuthStateTTL); err != nil {
+		return "", "", err
+	}
+	return provider.AuthCodeURL(r.OAuthCallbackURL(name), state, nonce, s256(verifier)), state, nil
+}
+
+// CompleteOAuthLogin exchanges the code the provider sent back for the
+// profile of the user and logs them in, creating their account if needed.
+// browserState is the state bound to the browser by StartOAuthLogin. The URI
+// the user should be redirected to is returned once the state is valid.
+func (r *Resolver) CompleteOAuthLogin(ctx context.Context, name, state, browserState, code string) (string, *RedirectLogin, error) {
+	login, err := r.consumeOAuthState(ctx, name, state, browserState)
+	if err != nil {
+		return "", nil, err
+	}
+	provider, err := r.OAuthProvider(ctx, name)
+	if err != nil {
+		return login.RedirectURI, nil, err
+	}
+
+	tok, err := provider.Exchange(ctx, r.OAuthCallbackURL(name), code, login.CodeVerifier)
+	if err != nil {
+		return login.RedirectURI, nil, fmt.Errorf("%w: %v", ErrOAuthProviderFailed, err)
+	}
+	profile, err := provider.FetchProfile(ctx, tok, login.Nonce)
+	if err != nil {
+		return login.RedirectURI, nil, fmt.Errorf("%w: %v", ErrOAuthProviderFailed, err)
+	}
+
+	user, err := r.oauthUser(ctx, profile, login.Roles)
+	if err != nil {
+		return login.RedirectURI, nil, err
+	}
+	grant := token.Grant{Scope: login.Scope}
+	if len(login.Roles) > 0 {
+		for _, role := range login.Roles {
+			if !slices.Contains(user.RoleList(), role) {
+				return login.RedirectURI, nil, ErrRolesNotGranted
+			}
+		}
+		grant.Roles = login.Roles
+	}
+
+	res, err := r.startRedirectLogin(ctx, user, grant, amrFederated)
+	if err != nil {
+		return login.RedirectURI, nil, err
+	}
+	return login.RedirectURI, res, nil
+}
+
+// AbortOAuthLogin consumes the state of a login the provider reported as
+// failed, e.g. because the user denied access, and returns the URI the user
+// should be redirected to
+func (r *Resolver) AbortOAuthLogin(ctx context.Context, name, state, browserState string) (string, error) {
+	login, err := r.consumeOAuthState(ctx, name, state, browserState)
+	if err != nil {
+		return "", err
+	}
+	return login.RedirectURI, nil
+}
+
+// consumeOAuthState takes the state out of the store so it can only be used
+// once, by the browser it was issued to
+func (r *Resolver) consumeOAuthState(ctx context.Context, name, state, browserState string) (*oauthLoginState, error) {
+	if state == "" || state != browserState {
+		return nil, ErrInvalidOAuthState
+	}
+	value, err := r.Sessions.TakeState(ctx, oauthStateKey(state))
+	if err != nil {
+		if errors.Is(err, sessionstore.ErrNotFound) {
+			return nil, ErrInvalidOAuthState
+		}
+		return nil, err
+	}
+	var login oauthLoginState
+	if err := json.Unmarshal([]byte(value), &login); err != nil {
+		return nil, err
+	}
+	if login.Provider != name {
+		return nil, ErrInvalidOAuthState
+	}
+	return &login, nil
+}
+
+// oauthUser returns the account matching the verified email of the profile,
+// signing the user up when there is none
+func (r *Resolver) oauthUser(ctx context.Context, profile *providers.Profile, roles []string) (*models.User, error) {
+	email := validators.NormalizeEmail(profile.Email)
+	if !profile.EmailVerified || !validators.IsValidEmail(email) {
+		return nil, ErrUnverifiedProviderEmail
+	}
+
+	user, err := r.DB.GetUserByEmail(ctx, email)
+	if errors.Is(err, models.ErrNotFound) {
+		if r.Config.DisableSignUp {
+			return nil, ErrSignUpDisabled
+		}
+		// protected roles are not assigned, requesting them fails the login
+		user = &models.User{Name: profile.Name, Email: email, EmailVerifiedAt: refs.NewInt64Ref(time.Now().Unix())}
+		user.SetRoles(r.initialRoles(slices.DeleteFunc(slices.Clone(roles), r.isProtectedRole)))
+		user, err = r.DB.CreateUser(ctx, user)
+		if errors.Is(err, models.ErrDuplicate) {
+			// created by a concurrent request
+			user, err = r.DB.GetUserByEmail(ctx, email)
+		}
+		return user, err
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if !user.IsEmailVerified() {
+		// whoever signed up never proved they own the email, their password
+		// and sessions are dropped so they cannot share the account with
+		// its owner
+		user.EmailVerifiedAt = refs.NewInt64Ref(time.Now().Unix())
+		user.Password = ""
+		user.RevokeSessions()
+		if _, err := r.DB.UpdateUser(ctx, user); err != nil {
+			return nil, err
+		}
+		if err := r.Sessions.DeleteUserSessions(ctx, user.ID); err != nil {
+			return nil, err
+		}
+	}
+	return user, nil
+}
+
+func oauthStateKey(state string) string {
+	return "oauth_state:" + crypto.HashToken(state)
+}
@@ -0,0 +1,88 @@
+package graph
+
+import (
+	"context"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/vektah/gqlparser/v2/gqlerror"
+
+	"server/constants"
+	"server/database/models"
+	"server/graph/model"
+	"server/providers"
+)
+
+// oidcProviderName is the format of the names of registered providers, which
+// appear in the login routes
+var oidcProviderName = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,63}$`)
+
+// oidcConfig describes the registered provider to the providers package
+func oidcConfig(p *models.OIDCProvider) providers.OIDCConfig {
+	return providers.OIDCConfig{
+		Name:         p.Name,
+		Issuer:       p.Issuer,
+		ClientID:     p.ClientID,
+		ClientSecret: p.ClientSecret,
+		Scopes:       p.ScopeList(),
+		Claims: providers.ClaimMapping{
+			Email:         p.EmailClaim,
+			EmailVerified: p.EmailVerifiedClaim,
+			Name:          p.NameClaim,
+		},
+	}
+}
+
+// applyClaimMapping sets the claims given in input, the claims left empty
+// default to the standard ones
+func applyClaimMapping(p *models.OIDCProvider, input *model.OIDCClaimMappingInput) {
+	if input != nil {
+		if input.Email != nil {
+			p.EmailClaim = strings.TrimSpace(*input.Email)
+		}
+		if input.EmailVerified != nil {
+			p.EmailVerifiedClaim = strings.TrimSpace(*input.EmailVerified)
+		}
+		if input.Name != nil {
+			p.NameClaim = strings.TrimSpace(*input.Name)
+		}
+	}
+	if p.EmailClaim == "" {
+		p.EmailClaim = "email"
+	}
+	if p.EmailVerifiedClaim == "" {
+		p.EmailVerifiedClaim = "email_verified"
+	}
+	if p.NameClaim == "" {
+		p.NameClaim = "name"
+	}
+}
+
+// setOIDCScopes replaces the requested scopes, the default ones when empty
+func setOIDCScopes(p *models.OIDCProvider, scopes []string) {
+	var res []string
+	for _, scope := range scopes {
+		res = append(res, strings.Fields(scope)...)
+	}
+	if len(res) == 0 {
+		res = providers.DefaultOIDCScopes
+	}
+	p.SetScopes(res)
+}
+
+// validateOIDCProvider checks the provider can be used, which includes
+// fetching the discovery document of its issuer
+func (r *Resolver) validateOIDCProvider(ctx context.Context, p *models.OIDCProvider) *gqlerror.Error {
+	u, err := url.Parse(p.Issuer)
+	if err != nil || providers.CheckEndpoint(p.Issuer) != nil || u.RawQuery != "" || u.Fragment != "" {
+		return newError(ctx, constants.ErrCodeBadUserInput, "issuer must be an https URL, or http on a loopback host, without query or fragment")
+	}
+	if p.ClientID == "" {
+		return newError(ctx, constants.ErrCodeBadUserInput, "client id is required")
+	}
+	if _, err := providers.Discover(ctx, p.Issuer); err != nil {
+		return newError(ctx, constants.ErrCodeBadUserInput, "issuer discovery failed: "+err.Error())
+	}
+	return nil
+}
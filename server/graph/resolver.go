@@ -1,18 +1,34 @@
 package graph
 
 import (
+	"server/config"
 	"server/database"
+	"server/email"
+	"server/providers"
+	"server/sessionstore"
+	"server/token"
 )
 
 // This file will not be regenerated automatically.
 // It serves as dependency injection for your app, add any dependencies you require here.
 
 type Resolver struct {
-	DB *database.Database
+	Config   *config.Config
+	DB       database.Repository
+	Tokens   *token.Manager
+	Mailer   email.Sender
+	Sessions sessionstore.SessionStore
+	// OAuthProviders are the external login providers, keyed by name
+	OAuthProviders map[string]providers.OAuthProvider
 }
 
-func NewResolver(db *database.Database) *Resolver {
+func NewResolver(cfg *config.Config, db database.Repository, tokens *token.Manager, mailer email.Sender, sessions sessionstore.SessionStore) *Resolver {
 	return &Resolver{
-		DB: db,
+		Config:         cfg,
+		DB:             db,
+		Tokens:         tokens,
+		Mailer:         mailer,
+		Sessions:       sessions,
+		OAuthProviders: providers.FromConfig(cfg),
 	}
 }
@@ -0,0 +1,48 @@
+package graph
+
+import (
+	"context"
+	"slices"
+
+	"github.com/vektah/gqlparser/v2/gqlerror"
+
+	"server/constants"
+	"server/validators"
+)
+
+// validateRoles checks that every role is one of ROLES
+func (r *Resolver) validateRoles(ctx context.Context, roles []string) *gqlerror.Error {
+	if role := validators.UnknownRole(r.Config, roles); role != "" {
+		return newError(ctx, constants.ErrCodeBadUserInput, "invalid role: "+role)
+	}
+	return nil
+}
+
+// validateRequestedRoles checks roles requested by users for themselves:
+// they must be valid and PROTECTED_ROLES can only be assigned by admins
+func (r *Resolver) validateRequestedRoles(ctx context.Context, roles []string) *gqlerror.Error {
+	if err := r.validateRoles(ctx, roles); err != nil {
+		return err
+	}
+	for _, role := range roles {
+		if r.isProtectedRole(role) {
+			return newError(ctx, constants.ErrCodeForbidden, "role cannot be requested: "+role)
+		}
+	}
+	return nil
+}
+
+// isProtectedRole reports whether the role is in PROTECTED_ROLES or is the
+// admin role, which is protected whatever the configuration
+func (r *Resolver) isProtectedRole(role string) bool {
+	return role == constants.RoleAdmin || slices.Contains(r.Config.ProtectedRoles, role)
+}
+
+// initialRoles returns the roles of a new user: the requested roles, or
+// DEFAULT_ROLES when none are requested
+func (r *Resolver) initialRoles(requested []string) []string {
+	if len(requested) == 0 {
+		return r.Config.DefaultRoles
+	}
+	return requested
+}
@@ -6,24 +6,870 @@ package graph
 
 import (
 	"context"
-	"fmt"
+	"errors"
+	"server/auth"
+	"server/constants"
+	"server/cookie"
+	"server/crypto"
+	"server/database/models"
 	"server/graph/generated"
 	"server/graph/model"
+	"server/middlewares"
+	"server/providers"
+	"server/refs"
+	"server/sessionstore"
+	"server/validators"
+	"slices"
+	"strings"
+	"time"
 )
 
-// CreateUser is the resolver for the createUser field.
-func (r *mutationResolver) CreateUser(ctx context.Context, input model.CreateUserInput) (*model.User, error) {
-	panic(fmt.Errorf("not implemented: CreateUser - createUser"))
+// Signup is the resolver for the signup field.
+func (r *mutationResolver) Signup(ctx context.Context, input model.SignUpInput) (*model.AuthResponse, error) {
+	if r.Config.DisableBasicAuthentication {
+		return nil, newError(ctx, constants.ErrCodeForbidden, "basic authentication is disabled")
+	}
+	if r.Config.DisableSignUp {
+		return nil, newError(ctx, constants.ErrCodeForbidden, "sign up is disabled")
+	}
+
+	name := strings.TrimSpace(input.Name)
+	if name == "" {
+		return nil, newError(ctx, constants.ErrCodeBadUserInput, "name is required")
+	}
+	email := validators.NormalizeEmail(input.Email)
+	if !validators.IsValidEmail(email) {
+		return nil, newError(ctx, constants.ErrCodeBadUserInput, "invalid email address")
+	}
+	if input.Password != input.ConfirmPassword {
+		return nil, newError(ctx, constants.ErrCodeBadUserInput, "password and confirm password do not match")
+	}
+	if err := r.validatePassword(ctx, input.Password, email, name); err != nil {
+		return nil, err
+	}
+	if err := r.validateRequestedRoles(ctx, input.Roles); err != nil {
+		return nil, err
+	}
+
+	if _, err := r.DB.GetUserByEmail(ctx, email); err == nil {
+		return nil, newError(ctx, constants.ErrCodeConflict, "user with this email already exists")
+	} else if !errors.Is(err, models.ErrNotFound) {
+		return nil, internalError(ctx, err)
+	}
+
+	hash, err := crypto.HashPassword(input.Password)
+	if err != nil {
+		return nil, internalError(ctx, err)
+	}
+
+	user := &models.User{
+		Name:     name,
+		Email:    email,
+		Password: hash,
+	}
+	user.SetRoles(r.initialRoles(input.Roles))
+	verificationRequired := r.isEmailVerificationRequired()
+	if !verificationRequired {
+		user.EmailVerifiedAt = refs.NewInt64Ref(time.Now().Unix())
+	}
+	if _, err := r.DB.CreateUser(ctx, user); err != nil {
+		if errors.Is(err, models.ErrDuplicate) {
+			return nil, newError(ctx, constants.ErrCodeConflict, "user with this email already exists")
+		}
+		return nil, internalError(ctx, err)
+	}
+
+	if verificationRequired {
+		if err := r.sendVerificationEmail(ctx, user); err != nil {
+			return nil, internalError(ctx, err)
+		}
+		return &model.AuthResponse{
+			Message: "verification email has been sent, please check your inbox",
+			User:    user.AsAPIUser(),
+		}, nil
+	}
+
+	return r.newAuthResponse(ctx, user, nil, amrPassword, "signed up successfully")
+}
+
+// Login is the resolver for the login field.
+func (r *mutationResolver) Login(ctx context.Context, email string, password string, roles []string) (*model.AuthResponse, error) {
+	if r.Config.DisableBasicAuthentication {
+		return nil, newError(ctx, constants.ErrCodeForbidden, "basic authentication is disabled")
+	}
+
+	user, err := r.DB.GetUserByEmail(ctx, validators.NormalizeEmail(email))
+	if err != nil {
+		if !errors.Is(err, models.ErrNotFound) {
+			return nil, internalError(ctx, err)
+		}
+		crypto.SimulatePasswordCheck(password)
+		return nil, newError(ctx, constants.ErrCodeUnauthenticated, "invalid email or password")
+	}
+	if !crypto.VerifyPassword(user.Password, password) {
+		return nil, newError(ctx, constants.ErrCodeUnauthenticated, "invalid email or password")
+	}
+	if r.isEmailVerificationRequired() && !user.IsEmailVerified() {
+		return nil, newError(ctx, constants.ErrCodeEmailNotVerified, "email is not verified")
+	}
+	if err := r.validateRoles(ctx, roles); err != nil {
+		return nil, err
+	}
+	for _, role := range roles {
+		if !slices.Contains(user.RoleList(), role) {
+			return nil, newError(ctx, constants.ErrCodeForbidden, "user does not have role: "+role)
+		}
+	}
+
+	return r.newAuthResponse(ctx, user, roles, amrPassword, "logged in successfully")
+}
+
+// VerifyEmail is the resolver for the verifyEmail field.
+func (r *mutationResolver) VerifyEmail(ctx context.Context, token string) (*model.AuthResponse, error) {
+	req, err := r.consumeVerificationToken(ctx, token, models.VerificationTypeVerifyEmail)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrNotFound):
+			return nil, newError(ctx, constants.ErrCodeBadUserInput, "invalid token")
+		case errors.Is(err, errTokenExpired):
+			return nil, newError(ctx, constants.ErrCodeBadUserInput, "token has expired")
+		default:
+			return nil, internalError(ctx, err)
+		}
+	}
+
+	user, err := r.DB.GetUserByEmail(ctx, req.Email)
+	if err != nil {
+		if errors.Is(err, models.ErrNotFound) {
+			return nil, newError(ctx, constants.ErrCodeBadUserInput, "invalid token")
+		}
+		return nil, internalError(ctx, err)
+	}
+	if !user.IsEmailVerified() {
+		user.EmailVerifiedAt = refs.NewInt64Ref(time.Now().Unix())
+		if _, err := r.DB.UpdateUser(ctx, user); err != nil {
+			return nil, internalError(ctx, err)
+		}
+	}
+
+	return r.newAuthResponse(ctx, user, nil, amrEmailLink, "email verified successfully")
+}
+
+// ResendVerifyEmail is the resolver for the resendVerifyEmail field.
+func (r *mutationResolver) ResendVerifyEmail(ctx context.Context, email string) (*model.Response, error) {
+	if !r.isEmailVerificationRequired() {
+		return nil, newError(ctx, constants.ErrCodeForbidden, "email verification is disabled")
+	}
+
+	// the response is the same whether the email is registered or not, so it
+	// cannot be used to find out who has an account
+	res := &model.Response{Message: "if the email is registered and not verified yet, a verification email has been sent"}
+
+	user, err := r.DB.GetUserByEmail(ctx, validators.NormalizeEmail(email))
+	if err != nil {
+		if errors.Is(err, models.ErrNotFound) {
+			return res, nil
+		}
+		return nil, internalError(ctx, err)
+	}
+	if user.IsEmailVerified() {
+		return res, nil
+	}
+	if err := r.sendVerificationEmail(ctx, user); err != nil {
+		return nil, internalError(ctx, err)
+	}
+	return res, nil
+}
+
+// ForgotPassword is the resolver for the forgotPassword field.
+func (r *mutationResolver) ForgotPassword(ctx context.Context, email string) (*model.Response, error) {
+	if r.Config.DisableBasicAuthentication {
+		return nil, newError(ctx, constants.ErrCodeForbidden, "basic authentication is disabled")
+	}
+	if !r.Config.IsEmailServiceEnabled {
+		return nil, newError(ctx, constants.ErrCodeForbidden, "email service is disabled")
+	}
+
+	// as for resendVerifyEmail, unknown emails get the same response
+	res := &model.Response{Message: "if the email is registered, a password reset email has been sent"}
+
+	user, err := r.DB.GetUserByEmail(ctx, validators.NormalizeEmail(email))
+	if err != nil {
+		if errors.Is(err, models.ErrNotFound) {
+			return res, nil
+		}
+		return nil, internalError(ctx, err)
+	}
+	if err := r.sendResetPasswordEmail(ctx, user); err != nil {
+		return nil, internalError(ctx, err)
+	}
+	return res, nil
+}
+
+// ResetPassword is the resolver for the resetPassword field.
+func (r *mutationResolver) ResetPassword(ctx context.Context, token string, password string, confirmPassword string) (*model.Response, error) {
+	if r.Config.DisableBasicAuthentication {
+		return nil, newError(ctx, constants.ErrCodeForbidden, "basic authentication is disabled")
+	}
+	if password != confirmPassword {
+		return nil, newError(ctx, constants.ErrCodeBadUserInput, "password and confirm password do not match")
+	}
+
+	// the policy is checked before consuming the token so that a rejected
+	// password does not force the user to request a new email
+	req, err := r.DB.GetVerificationRequestByToken(ctx, crypto.HashToken(token))
+	if err != nil {
+		if errors.Is(err, models.ErrNotFound) {
+			return nil, newError(ctx, constants.ErrCodeBadUserInput, "invalid token")
+		}
+		return nil, internalError(ctx, err)
+	}
+	// verification and magic link tokens do not reset passwords
+	if req.Identifier != models.VerificationTypeForgotPassword {
+		return nil, newError(ctx, constants.ErrCodeBadUserInput, "invalid token")
+	}
+	user, err := r.DB.GetUserByEmail(ctx, req.Email)
+	if err != nil {
+		if errors.Is(err, models.ErrNotFound) {
+			return nil, newError(ctx, constants.ErrCodeBadUserInput, "invalid token")
+		}
+		return nil, internalError(ctx, err)
+	}
+	if err := r.validatePassword(ctx, password, user.Email, user.Name); err != nil {
+		return nil, err
+	}
+
+	if _, err := r.consumeVerificationToken(ctx, token, models.VerificationTypeForgotPassword); err != nil {
+		switch {
+		case errors.Is(err, models.ErrNotFound):
+			return nil, newError(ctx, constants.ErrCodeBadUserInput, "invalid token")
+		case errors.Is(err, errTokenExpired):
+			return nil, newError(ctx, constants.ErrCodeBadUserInput, "token has expired")
+		default:
+			return nil, internalError(ctx, err)
+		}
+	}
+
+	hash, err := crypto.HashPassword(password)
+	if err != nil {
+		return nil, internalError(ctx, err)
+	}
+	user.Password = hash
+	// the reset link was delivered to the inbox, which proves the address
+	if !user.IsEmailVerified() {
+		user.EmailVerifiedAt = refs.NewInt64Ref(time.Now().Unix())
+	}
+	user.RevokeSessions()
+	if _, err := r.DB.UpdateUser(ctx, user); err != nil {
+		return nil, internalError(ctx, err)
+	}
+	if err := r.Sessions.DeleteUserSessions(ctx, user.ID); err != nil {
+		return nil, internalError(ctx, err)
+	}
+
+	return &model.Response{Message: "password has been reset, please log in with the new password"}, nil
+}
+
+// RefreshToken is the resolver for the refreshToken field.
+func (r *mutationResolver) RefreshToken(ctx context.Context, refreshToken *string) (*model.AuthResponse, error) {
+	fromCookie := refreshToken == nil || *refreshToken == ""
+	if fromCookie {
+		gc, err := middlewares.GinContextFromContext(ctx)
+		if err != nil {
+			return nil, internalError(ctx, err)
+		}
+		refreshToken = refs.NewStringRef(cookie.GetSession(gc))
+	}
+
+	user, tokens, err := r.RefreshSession(ctx, *refreshToken, "")
+	if err != nil {
+		if errors.Is(err, ErrInvalidRefreshToken) {
+			if fromCookie {
+				r.deleteSessionCookie(ctx)
+			}
+			return nil, newError(ctx, constants.ErrCodeUnauthenticated, "invalid refresh token")
+		}
+		return nil, internalError(ctx, err)
+	}
+	r.setSessionCookie(ctx, tokens)
+	return authResponse(user, tokens, "token refreshed successfully"), nil
+}
+
+// VerifyTotp is the resolver for the verifyTotp field.
+func (r *mutationResolver) VerifyTotp(ctx context.Context, mfaToken string, code string) (*model.AuthResponse, error) {
+	if !r.isMFAEnabled() {
+		return nil, newError(ctx, constants.ErrCodeForbidden, "multi factor authentication is disabled")
+	}
+	return r.completeMFALogin(ctx, mfaToken, code)
+}
+
+// MagicLinkLogin is the resolver for the magicLinkLogin field.
+func (r *mutationResolver) MagicLinkLogin(ctx context.Context, email string, roles []string, scope []string, redirectURI *string) (*model.Response, error) {
+	if r.Config.DisableMagicLinkLogin {
+		return nil, newError(ctx, constants.ErrCodeForbidden, "magic link login is disabled")
+	}
+	if !r.Config.IsEmailServiceEnabled {
+		return nil, newError(ctx, constants.ErrCodeForbidden, "email service is disabled")
+	}
+
+	email = validators.NormalizeEmail(email)
+	if !validators.IsValidEmail(email) {
+		return nil, newError(ctx, constants.ErrCodeBadUserInput, "invalid email address")
+	}
+	if err := r.validateRoles(ctx, roles); err != nil {
+		return nil, err
+	}
+	redirect := ""
+	if redirectURI != nil && *redirectURI != "" {
+		if !validators.IsAllowedRedirectURI(r.Config, *redirectURI) {
+			return nil, newError(ctx, constants.ErrCodeBadUserInput, "invalid redirect URI")
+		}
+		redirect = *redirectURI
+	}
+	if len(scope) == 0 {
+		scope = defaultScope
+	}
+
+	// the response does not tell whether an account exists or was created
+	res := &model.Response{Message: "a login link has been sent to your email, please check your inbox"}
+
+	user, err := r.DB.GetUserByEmail(ctx, email)
+	if errors.Is(err, models.ErrNotFound) {
+		if r.Config.DisableSignUp {
+			return res, nil
+		}
+		// the email is verified once the link is used. Protected roles are
+		// not assigned, so requesting them fails when the link is used
+		// without revealing whether the account existed.
+		user = &models.User{Email: email}
+		user.SetRoles(r.initialRoles(slices.DeleteFunc(slices.Clone(roles), r.isProtectedRole)))
+		user, err = r.DB.CreateUser(ctx, user)
+		if errors.Is(err, models.ErrDuplicate) {
+			// created by a concurrent request
+			user, err = r.DB.GetUserByEmail(ctx, email)
+		}
+	}
+	if err != nil {
+		return nil, internalError(ctx, err)
+	}
+
+	if err := r.sendMagicLinkEmail(ctx, user, roles, scope, redirect); err != nil {
+		return nil, internalError(ctx, err)
+	}
+	return res, nil
+}
+
+// Logout is the resolver for the logout field.
+func (r *mutationResolver) Logout(ctx context.Context) (*model.Response, error) {
+	_, session, err := r.CurrentSession(ctx)
+	if err != nil {
+		return nil, sessionError(ctx, err)
+	}
+	if err := r.Sessions.DeleteSession(ctx, session.ID); err != nil {
+		return nil, internalError(ctx, err)
+	}
+	r.deleteSessionCookie(ctx)
+	return &model.Response{Message: "logged out successfully"}, nil
+}
+
+// RevokeAllSessions is the resolver for the revokeAllSessions field.
+func (r *mutationResolver) RevokeAllSessions(ctx context.Context) (*model.Response, error) {
+	user, _, err := r.CurrentSession(ctx)
+	if err != nil {
+		return nil, sessionError(ctx, err)
+	}
+	if err := r.Sessions.DeleteUserSessions(ctx, user.ID); err != nil {
+		return nil, internalError(ctx, err)
+	}
+	r.deleteSessionCookie(ctx)
+	return &model.Response{Message: "logged out of all sessions"}, nil
+}
+
+// UpdatePassword is the resolver for the updatePassword field.
+func (r *mutationResolver) UpdatePassword(ctx context.Context, oldPassword *string, newPassword string, confirmNewPassword string) (*model.Response, error) {
+	if r.Config.DisableBasicAuthentication {
+		return nil, newError(ctx, constants.ErrCodeForbidden, "basic authentication is disabled")
+	}
+	user, _, err := r.CurrentSession(ctx)
+	if err != nil {
+		return nil, sessionError(ctx, err)
+	}
+	if newPassword != confirmNewPassword {
+		return nil, newError(ctx, constants.ErrCodeBadUserInput, "password and confirm password do not match")
+	}
+	// passwordless users set their first password with the session alone
+	if user.Password != "" && (oldPassword == nil || !crypto.VerifyPassword(user.Password, *oldPassword)) {
+		return nil, newError(ctx, constants.ErrCodeBadUserInput, "invalid old password")
+	}
+	if err := r.validatePassword(ctx, newPassword, user.Email, user.Name); err != nil {
+		return nil, err
+	}
+
+	hash, err := crypto.HashPassword(newPassword)
+	if err != nil {
+		return nil, internalError(ctx, err)
+	}
+	user.Password = hash
+	user.RevokeSessions()
+	if _, err := r.DB.UpdateUser(ctx, user); err != nil {
+		return nil, internalError(ctx, err)
+	}
+	if err := r.Sessions.DeleteUserSessions(ctx, user.ID); err != nil {
+		return nil, internalError(ctx, err)
+	}
+	r.deleteSessionCookie(ctx)
+	return &model.Response{Message: "password has been updated, please log in with the new password"}, nil
+}
+
+// EnrollTotp is the resolver for the enrollTotp field.
+func (r *mutationResolver) EnrollTotp(ctx context.Context) (*model.TOTPEnrollment, error) {
+	if !r.isMFAEnabled() {
+		return nil, newError(ctx, constants.ErrCodeForbidden, "multi factor authentication is disabled")
+	}
+	user, _, err := r.CurrentSession(ctx)
+	if err != nil {
+		return nil, sessionError(ctx, err)
+	}
+	if user.IsTOTPEnabled() {
+		return nil, newError(ctx, constants.ErrCodeConflict, "an authenticator app is already enabled")
+	}
+
+	secret, enrollment, err := r.generateTOTP(user)
+	if err != nil {
+		return nil, internalError(ctx, err)
+	}
+	if err := r.Sessions.SetState(ctx, totpEnrollmentKey(user.ID), secret, totpEnrollmentTTL); err != nil {
+		return nil, internalError(ctx, err)
+	}
+	return enrollment, nil
 }
 
-// Users is the resolver for the users field.
+// ConfirmTotp is the resolver for the confirmTotp field.
+func (r *mutationResolver) ConfirmTotp(ctx context.Context, code string) (*model.RecoveryCodesResponse, error) {
+	if !r.isMFAEnabled() {
+		return nil, newError(ctx, constants.ErrCodeForbidden, "multi factor authentication is disabled")
+	}
+	user, _, err := r.CurrentSession(ctx)
+	if err != nil {
+		return nil, sessionError(ctx, err)
+	}
+	if user.IsTOTPEnabled() {
+		return nil, newError(ctx, constants.ErrCodeConflict, "an authenticator app is already enabled")
+	}
+
+	secret, err := r.Sessions.GetState(ctx, totpEnrollmentKey(user.ID))
+	if err != nil {
+		if errors.Is(err, sessionstore.ErrNotFound) {
+			return nil, newError(ctx, constants.ErrCodeBadUserInput, "no authenticator app is being enrolled, call enrollTotp first")
+		}
+		return nil, internalError(ctx, err)
+	}
+	ok, err := r.validateTOTP(ctx, user.ID, secret, code)
+	if err != nil {
+		return nil, internalError(ctx, err)
+	}
+	if !ok {
+		return nil, newError(ctx, constants.ErrCodeBadUserInput, "invalid code")
+	}
+
+	recoveryCodes, err := r.enableTOTP(ctx, user, secret)
+	if err != nil {
+		return nil, internalError(ctx, err)
+	}
+	if err := r.Sessions.DeleteState(ctx, totpEnrollmentKey(user.ID)); err != nil {
+		return nil, internalError(ctx, err)
+	}
+	return &model.RecoveryCodesResponse{Message: "authenticator app enabled successfully", RecoveryCodes: recoveryCodes}, nil
+}
+
+// DisableTotp is the resolver for the disableTotp field.
+func (r *mutationResolver) DisableTotp(ctx context.Context, code string) (*model.Response, error) {
+	if !r.isMFAEnabled() {
+		return nil, newError(ctx, constants.ErrCodeForbidden, "multi factor authentication is disabled")
+	}
+	if r.Config.EnforceMultiFactorAuthentication {
+		return nil, newError(ctx, constants.ErrCodeForbidden, "multi factor authentication is enforced")
+	}
+	user, session, err := r.CurrentSession(ctx)
+	if err != nil {
+		return nil, sessionError(ctx, err)
+	}
+	if !user.IsTOTPEnabled() {
+		return nil, newError(ctx, constants.ErrCodeBadUserInput, "no authenticator app is enabled")
+	}
+
+	if allowed, err := r.countMFAAttempt(ctx, user.ID, session.ID); err != nil {
+		return nil, internalError(ctx, err)
+	} else if !allowed {
+		return nil, newError(ctx, constants.ErrCodeForbidden, "too many invalid codes, please try again later")
+	}
+	ok, err := r.verifySecondFactor(ctx, user, code)
+	if err != nil {
+		return nil, internalError(ctx, err)
+	}
+	if !ok {
+		return nil, newError(ctx, constants.ErrCodeBadUserInput, "invalid code")
+	}
+	if err := r.resetMFAAttempts(ctx, user.ID, session.ID); err != nil {
+		return nil, internalError(ctx, err)
+	}
+	user.DisableTOTP()
+	if _, err := r.DB.UpdateUser(ctx, user); err != nil {
+		return nil, internalError(ctx, err)
+	}
+	return &model.Response{Message: "authenticator app disabled successfully"}, nil
+}
+
+// RevokeUserSessions is the resolver for the revokeUserSessions field.
+func (r *mutationResolver) RevokeUserSessions(ctx context.Context, userID string) (*model.Response, error) {
+	// the caller may be authenticated with the admin secret only
+	caller, isUser := auth.PrincipalFromContext(ctx)
+
+	if _, err := r.DB.GetUserByID(ctx, userID); err != nil {
+		if errors.Is(err, models.ErrNotFound) {
+			return nil, newError(ctx, constants.ErrCodeNotFound, "user not found")
+		}
+		return nil, internalError(ctx, err)
+	}
+	if err := r.Sessions.DeleteUserSessions(ctx, userID); err != nil {
+		return nil, internalError(ctx, err)
+	}
+	if isUser && userID == caller.UserID {
+		r.deleteSessionCookie(ctx)
+	}
+	return &model.Response{Message: "sessions of the user have been revoked"}, nil
+}
+
+// AssignRoles is the resolver for the assignRoles field.
+func (r *mutationResolver) AssignRoles(ctx context.Context, userID string, roles []string) (*model.User, error) {
+	if err := r.validateRoles(ctx, roles); err != nil {
+		return nil, err
+	}
+	user, err := r.DB.GetUserByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, models.ErrNotFound) {
+			return nil, newError(ctx, constants.ErrCodeNotFound, "user not found")
+		}
+		return nil, internalError(ctx, err)
+	}
+
+	user.SetRoles(append(user.RoleList(), roles...))
+	if _, err := r.DB.UpdateUser(ctx, user); err != nil {
+		return nil, internalError(ctx, err)
+	}
+	return user.AsAPIUser(), nil
+}
+
+// RemoveRoles is the resolver for the removeRoles field.
+func (r *mutationResolver) RemoveRoles(ctx context.Context, userID string, roles []string) (*model.User, error) {
+	if err := r.validateRoles(ctx, roles); err != nil {
+		return nil, err
+	}
+	user, err := r.DB.GetUserByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, models.ErrNotFound) {
+			return nil, newError(ctx, constants.ErrCodeNotFound, "user not found")
+		}
+		return nil, internalError(ctx, err)
+	}
+
+	// the API checks the roles of the stored session, so the removed roles
+	// stop working at once. Issued access tokens keep listing them for
+	// resource servers until they expire, the next refresh drops them.
+	user.SetRoles(slices.DeleteFunc(user.RoleList(), func(role string) bool {
+		return slices.Contains(roles, role)
+	}))
+	if _, err := r.DB.UpdateUser(ctx, user); err != nil {
+		return nil, internalError(ctx, err)
+	}
+	return user.AsAPIUser(), nil
+}
+
+// AdminLogin is the resolver for the adminLogin field.
+func (r *mutationResolver) AdminLogin(ctx context.Context, secret string) (*model.Response, error) {
+	if r.Config.AdminSecret == "" {
+		return nil, newError(ctx, constants.ErrCodeForbidden, "admin API is disabled")
+	}
+	if !auth.IsAdminSecret(r.Config.AdminSecret, secret) {
+		return nil, newError(ctx, constants.ErrCodeUnauthenticated, "invalid admin secret")
+	}
+
+	adminToken, err := crypto.GenerateToken()
+	if err != nil {
+		return nil, internalError(ctx, err)
+	}
+	if err := r.Sessions.SetState(ctx, auth.AdminSessionKey(adminToken), "admin", adminSessionTTL); err != nil {
+		return nil, internalError(ctx, err)
+	}
+	r.setAdminCookie(ctx, adminToken)
+	return &model.Response{Message: "admin logged in successfully"}, nil
+}
+
+// AdminLogout is the resolver for the adminLogout field.
+func (r *mutationResolver) AdminLogout(ctx context.Context) (*model.Response, error) {
+	if adminToken := adminCookie(ctx); adminToken != "" {
+		if err := r.Sessions.DeleteState(ctx, auth.AdminSessionKey(adminToken)); err != nil {
+			return nil, internalError(ctx, err)
+		}
+	}
+	r.deleteAdminCookie(ctx)
+	return &model.Response{Message: "admin logged out successfully"}, nil
+}
+
+// CreateUser is the resolver for the _createUser field.
+func (r *mutationResolver) CreateUser(ctx context.Context, params model.CreateUserInput) (*model.User, error) {
+	name := strings.TrimSpace(params.Name)
+	if name == "" {
+		return nil, newError(ctx, constants.ErrCodeBadUserInput, "name is required")
+	}
+	email := validators.NormalizeEmail(params.Email)
+	if !validators.IsValidEmail(email) {
+		return nil, newError(ctx, constants.ErrCodeBadUserInput, "invalid email address")
+	}
+
+	if _, err := r.DB.GetUserByEmail(ctx, email); err == nil {
+		return nil, newError(ctx, constants.ErrCodeConflict, "user with this email already exists")
+	} else if !errors.Is(err, models.ErrNotFound) {
+		return nil, internalError(ctx, err)
+	}
+
+	user := &models.User{
+		Name:  name,
+		Email: email,
+	}
+	user.SetRoles(r.Config.DefaultRoles)
+	user, err := r.DB.CreateUser(ctx, user)
+	if err != nil {
+		// the unique index still protects against concurrent sign ups
+		if errors.Is(err, models.ErrDuplicate) {
+			return nil, newError(ctx, constants.ErrCodeConflict, "user with this email already exists")
+		}
+		return nil, internalError(ctx, err)
+	}
+
+	return user.AsAPIUser(), nil
+}
+
+// UpdateUser is the resolver for the _updateUser field.
+func (r *mutationResolver) UpdateUser(ctx context.Context, params model.UpdateUserInput) (*model.User, error) {
+	user, err := r.DB.GetUserByID(ctx, params.ID)
+	if err != nil {
+		if errors.Is(err, models.ErrNotFound) {
+			return nil, newError(ctx, constants.ErrCodeNotFound, "user not found")
+		}
+		return nil, internalError(ctx, err)
+	}
+
+	if params.Name != nil {
+		name := strings.TrimSpace(*params.Name)
+		if name == "" {
+			return nil, newError(ctx, constants.ErrCodeBadUserInput, "name is required")
+		}
+		user.Name = name
+	}
+	previousEmail := user.Email
+	if params.Email != nil {
+		email := validators.NormalizeEmail(*params.Email)
+		if !validators.IsValidEmail(email) {
+			return nil, newError(ctx, constants.ErrCodeBadUserInput, "invalid email address")
+		}
+		if email != user.Email {
+			if _, err := r.DB.GetUserByEmail(ctx, email); err == nil {
+				return nil, newError(ctx, constants.ErrCodeConflict, "user with this email already exists")
+			} else if !errors.Is(err, models.ErrNotFound) {
+				return nil, internalError(ctx, err)
+			}
+			user.Email = email
+			user.EmailVerifiedAt = nil
+		}
+	}
+	if params.EmailVerified != nil {
+		if !*params.EmailVerified {
+			user.EmailVerifiedAt = nil
+		} else if !user.IsEmailVerified() {
+			user.EmailVerifiedAt = refs.NewInt64Ref(time.Now().Unix())
+		}
+	}
+	if params.Roles != nil {
+		if err := r.validateRoles(ctx, params.Roles); err != nil {
+			return nil, err
+		}
+		user.SetRoles(params.Roles)
+	}
+	if params.MfaEnabled != nil && !*params.MfaEnabled {
+		user.DisableTOTP()
+	}
+
+	if _, err := r.DB.UpdateUser(ctx, user); err != nil {
+		if errors.Is(err, models.ErrDuplicate) {
+			return nil, newError(ctx, constants.ErrCodeConflict, "user with this email already exists")
+		}
+		return nil, internalError(ctx, err)
+	}
+	if user.Email != previousEmail {
+		if err := r.deleteVerificationRequests(ctx, previousEmail); err != nil {
+			return nil, internalError(ctx, err)
+		}
+	}
+	return user.AsAPIUser(), nil
+}
+
+// DeleteUser is the resolver for the _deleteUser field.
+func (r *mutationResolver) DeleteUser(ctx context.Context, id string) (*model.Response, error) {
+	user, err := r.DB.GetUserByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, models.ErrNotFound) {
+			return nil, newError(ctx, constants.ErrCodeNotFound, "user not found")
+		}
+		return nil, internalError(ctx, err)
+	}
+
+	if err := r.Sessions.DeleteUserSessions(ctx, user.ID); err != nil {
+		return nil, internalError(ctx, err)
+	}
+	if err := r.deleteVerificationRequests(ctx, user.Email); err != nil {
+		return nil, internalError(ctx, err)
+	}
+	if err := r.DB.DeleteUser(ctx, user.ID); err != nil {
+		return nil, internalError(ctx, err)
+	}
+	return &model.Response{Message: "user deleted successfully"}, nil
+}
+
+// AddOIDCProvider is the resolver for the _addOIDCProvider field.
+func (r *mutationResolver) AddOIDCProvider(ctx context.Context, params model.AddOIDCProviderInput) (*model.OIDCProvider, error) {
+	name := strings.TrimSpace(params.Name)
+	if !oidcProviderName.MatchString(name) || providers.IsBuiltIn(name) {
+		return nil, newError(ctx, constants.ErrCodeBadUserInput, "invalid provider name: "+name)
+	}
+	provider := &models.OIDCProvider{
+		Name:         name,
+		Issuer:       strings.TrimSpace(params.Issuer),
+		ClientID:     strings.TrimSpace(params.ClientID),
+		ClientSecret: params.ClientSecret,
+	}
+	setOIDCScopes(provider, params.Scopes)
+	applyClaimMapping(provider, params.ClaimMapping)
+	if err := r.validateOIDCProvider(ctx, provider); err != nil {
+		return nil, err
+	}
+
+	provider, err := r.DB.CreateOIDCProvider(ctx, provider)
+	if err != nil {
+		if errors.Is(err, models.ErrDuplicate) {
+			return nil, newError(ctx, constants.ErrCodeConflict, "provider with this name already exists")
+		}
+		return nil, internalError(ctx, err)
+	}
+	return provider.AsAPIOIDCProvider(), nil
+}
+
+// UpdateOIDCProvider is the resolver for the _updateOIDCProvider field.
+func (r *mutationResolver) UpdateOIDCProvider(ctx context.Context, params model.UpdateOIDCProviderInput) (*model.OIDCProvider, error) {
+	provider, err := r.DB.GetOIDCProviderByName(ctx, params.Name)
+	if err != nil {
+		if errors.Is(err, models.ErrNotFound) {
+			return nil, newError(ctx, constants.ErrCodeNotFound, "provider not found")
+		}
+		return nil, internalError(ctx, err)
+	}
+
+	// the discovery document is fetched again, the provider may have changed
+	providers.ForgetDiscovery(provider.Issuer)
+	if params.Issuer != nil {
+		provider.Issuer = strings.TrimSpace(*params.Issuer)
+		providers.ForgetDiscovery(provider.Issuer)
+	}
+	if params.ClientID != nil {
+		provider.ClientID = strings.TrimSpace(*params.ClientID)
+	}
+	if params.ClientSecret != nil {
+		provider.ClientSecret = *params.ClientSecret
+	}
+	if params.Scopes != nil {
+		setOIDCScopes(provider, params.Scopes)
+	}
+	applyClaimMapping(provider, params.ClaimMapping)
+	if err := r.validateOIDCProvider(ctx, provider); err != nil {
+		return nil, err
+	}
+
+	if _, err := r.DB.UpdateOIDCProvider(ctx, provider); err != nil {
+		return nil, internalError(ctx, err)
+	}
+	return provider.AsAPIOIDCProvider(), nil
+}
+
+// DeleteOIDCProvider is the resolver for the _deleteOIDCProvider field.
+func (r *mutationResolver) DeleteOIDCProvider(ctx context.Context, name string) (*model.Response, error) {
+	provider, err := r.DB.GetOIDCProviderByName(ctx, name)
+	if err != nil {
+		if errors.Is(err, models.ErrNotFound) {
+			return nil, newError(ctx, constants.ErrCodeNotFound, "provider not found")
+		}
+		return nil, internalError(ctx, err)
+	}
+	if err := r.DB.DeleteOIDCProvider(ctx, provider.ID); err != nil {
+		return nil, internalError(ctx, err)
+	}
+	providers.ForgetDiscovery(provider.Issuer)
+	return &model.Response{Message: "provider deleted successfully"}, nil
+}
+
+// Session is the resolver for the session field.
+func (r *queryResolver) Session(ctx context.Context) (*model.Session, error) {
+	user, session, err := r.CurrentSession(ctx)
+	if err != nil {
+		return nil, sessionError(ctx, err)
+	}
+	return &model.Session{
+		ID:        session.ID,
+		Roles:     sessionRoles(user, session),
+		Scope:     append([]string{}, session.Scope...),
+		CreatedAt: int(session.CreatedAt),
+		ExpiresAt: int(session.ExpiresAt),
+		User:      user.AsAPIUser(),
+	}, nil
+}
+
+// Users is the resolver for the _users field.
 func (r *queryResolver) Users(ctx context.Context) ([]*model.User, error) {
-	panic(fmt.Errorf("not implemented: Users - users"))
+	users, err := r.DB.ListUsers(ctx)
+	if err != nil {
+		return nil, internalError(ctx, err)
+	}
+
+	res := make([]*model.User, 0, len(users))
+	for _, user := range users {
+		res = append(res, user.AsAPIUser())
+	}
+	return res, nil
 }
 
-// User is the resolver for the user field.
+// User is the resolver for the _user field.
 func (r *queryResolver) User(ctx context.Context, id string) (*model.User, error) {
-	panic(fmt.Errorf("not implemented: User - user"))
+	user, err := r.DB.GetUserByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, models.ErrNotFound) {
+			return nil, nil
+		}
+		return nil, internalError(ctx, err)
+	}
+	return user.AsAPIUser(), nil
+}
+
+// OidcProviders is the resolver for the _oidcProviders field.
+func (r *queryResolver) OidcProviders(ctx context.Context) ([]*model.OIDCProvider, error) {
+	registered, err := r.DB.ListOIDCProviders(ctx)
+	if err != nil {
+		return nil, internalError(ctx, err)
+	}
+
+	res := make([]*model.OIDCProvider, 0, len(registered))
+	for _, provider := range registered {
+		res = append(res, provider.AsAPIOIDCProvider())
+	}
+	return res, nil
 }
 
 // Mutation returns generated.MutationResolver implementation.
@@ -0,0 +1,156 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+
+	"server/crypto"
+	"server/database/models"
+	"server/sessionstore"
+	"server/token"
+)
+
+// ErrInvalidRefreshToken is returned by RefreshSession for refresh tokens that
+// are invalid, expired, revoked or were already used
+var ErrInvalidRefreshToken = errors.New("invalid refresh token")
+
+// Authentication methods reported in the amr claim, see RFC 8176
+const (
+	amrPassword  = "pwd"
+	amrEmailLink = "email"
+	// amrFederated is a login through an external provider
+	amrFederated = "fed"
+	// amrOTP is a TOTP or recovery code entered after the first factor
+	amrOTP = "otp"
+)
+
+// authentication describes when and how the user proved their identity
+type authentication struct {
+	Time    int64
+	Methods []string
+}
+
+// authenticatedBy is an authentication happening now
+func authenticatedBy(methods ...string) authentication {
+	return authentication{Time: time.Now().Unix(), Methods: methods}
+}
+
+// startSession opens a session, i.e. a new refresh token family, for the user
+// and issues its first token pair
+func (r *Resolver) startSession(ctx context.Context, user *models.User, grant token.Grant, auth authentication) (*token.AuthTokens, error) {
+	return r.startClientSession(ctx, user, "", grant, auth)
+}
+
+// startClientSession opens a session issued to the OAuth client, only the
+// client can refresh it
+func (r *Resolver) startClientSession(ctx context.Context, user *models.User, clientID string, grant token.Grant, auth authentication) (*token.AuthTokens, error) {
+	session := &sessionstore.Session{
+		ID:        uuid.New().String(),
+		UserID:    user.ID,
+		Roles:     grant.Roles,
+		Scope:     grant.Scope,
+		ClientID:  clientID,
+		CreatedAt: time.Now().Unix(),
+		AuthTime:  auth.Time,
+		AMR:       auth.Methods,
+	}
+	tokens, session, err := r.issueSessionTokens(user, session)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.Sessions.SetSession(ctx, session); err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+// issueSessionTokens issues a new token pair for the session and returns the
+// session updated to make its refresh token the only usable one of the
+// family. The session lifetime slides with each rotation.
+func (r *Resolver) issueSessionTokens(user *models.User, session *sessionstore.Session) (*token.AuthTokens, *sessionstore.Session, error) {
+	grant := token.Grant{Scope: session.Scope, SessionID: session.ID, ClientID: session.ClientID}
+	// roles removed from the user since the session started are not granted
+	if session.Roles != nil {
+		grant.Roles = sessionRoles(user, session)
+	}
+
+	tokens, err := r.Tokens.CreateAuthTokensWithGrant(user, grant)
+	if err != nil {
+		return nil, nil, err
+	}
+	rotated := *session
+	rotated.RefreshTokenHash = crypto.HashToken(tokens.RefreshToken)
+	rotated.ExpiresAt = time.Now().Add(r.Config.RefreshTokenExpiryTime).Unix()
+	return tokens, &rotated, nil
+}
+
+// RefreshSession exchanges a refresh token for a new token pair. Refresh
+// tokens are single use: presenting one that was already rotated means it
+// leaked, so the whole family is revoked. clientID is the authenticated OAuth
+// client asking, empty for direct logins, and must be the one the session was
+// issued to.
+func (r *Resolver) RefreshSession(ctx context.Context, refreshToken, clientID string) (*models.User, *token.AuthTokens, error) {
+	claims, err := r.Tokens.ParseToken(refreshToken, token.TypeRefreshToken)
+	if err != nil || claims.SessionID == "" {
+		return nil, nil, ErrInvalidRefreshToken
+	}
+
+	session, err := r.Sessions.GetSession(ctx, claims.SessionID)
+	if err != nil {
+		if errors.Is(err, sessionstore.ErrNotFound) {
+			return nil, nil, ErrInvalidRefreshToken
+		}
+		return nil, nil, err
+	}
+	if session.UserID != claims.Subject || session.ClientID != clientID {
+		return nil, nil, ErrInvalidRefreshToken
+	}
+	previousHash := crypto.HashToken(refreshToken)
+	if session.RefreshTokenHash != previousHash {
+		return nil, nil, r.revokeReusedSession(ctx, session.ID)
+	}
+
+	user, err := r.DB.GetUserByID(ctx, session.UserID)
+	if err != nil {
+		if errors.Is(err, models.ErrNotFound) {
+			return nil, nil, ErrInvalidRefreshToken
+		}
+		return nil, nil, err
+	}
+	if user.IsSessionRevoked(claims.IssuedAt.Time) {
+		if err := r.Sessions.DeleteSession(ctx, session.ID); err != nil {
+			return nil, nil, err
+		}
+		return nil, nil, ErrInvalidRefreshToken
+	}
+
+	tokens, rotated, err := r.issueSessionTokens(user, session)
+	if err != nil {
+		return nil, nil, err
+	}
+	// the token may have been exchanged by a concurrent request since the
+	// session was read, which is a reuse as well
+	if err := r.Sessions.RotateSession(ctx, rotated, previousHash); err != nil {
+		switch {
+		case errors.Is(err, sessionstore.ErrConflict):
+			return nil, nil, r.revokeReusedSession(ctx, session.ID)
+		case errors.Is(err, sessionstore.ErrNotFound):
+			return nil, nil, ErrInvalidRefreshToken
+		default:
+			return nil, nil, err
+		}
+	}
+	return user, tokens, nil
+}
+
+// revokeReusedSession drops the session a used refresh token was presented
+// for and returns the error to report
+func (r *Resolver) revokeReusedSession(ctx context.Context, sessionID string) error {
+	if err := r.Sessions.DeleteSession(ctx, sessionID); err != nil {
+		return err
+	}
+	return ErrInvalidRefreshToken
+}
@@ -0,0 +1,209 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"slices"
+	"time"
+
+	"server/crypto"
+	"server/database/models"
+	"server/email"
+	"server/refs"
+	"server/token"
+)
+
+var errTokenExpired = errors.New("token expired")
+
+// Errors returned by LoginWithVerificationLink
+var (
+	// ErrInvalidVerificationLink is returned for unknown, used or expired tokens
+	ErrInvalidVerificationLink = errors.New("invalid or expired link")
+	// ErrRolesNotGranted is returned when the link requested roles the user does not have
+	ErrRolesNotGranted = errors.New("requested roles are not granted to the user")
+)
+
+// isEmailVerificationRequired reports whether new users must verify their
+// email before logging in. Verification needs the email service, so it is
+// skipped when the service is disabled.
+func (r *Resolver) isEmailVerificationRequired() bool {
+	return !r.Config.DisableEmailVerification && r.Config.IsEmailServiceEnabled
+}
+
+// createVerificationToken replaces the pending requests of the same
+// identifier for the email with req and returns the plain token. The token and
+// expiry of req are set here.
+func (r *Resolver) createVerificationToken(ctx context.Context, req *models.VerificationRequest, ttl time.Duration) (string, error) {
+	plain, err := crypto.GenerateToken()
+	if err != nil {
+		return "", err
+	}
+	if err := r.DB.DeleteVerificationRequestsByEmail(ctx, req.Email, req.Identifier); err != nil {
+		return "", err
+	}
+	req.Token = crypto.HashToken(plain)
+	req.ExpiresAt = time.Now().Add(ttl).Unix()
+	if _, err := r.DB.CreateVerificationRequest(ctx, req); err != nil {
+		return "", err
+	}
+	return plain, nil
+}
+
+// consumeVerificationToken looks the token up and deletes it so it can only be
+// used once. It returns models.ErrNotFound for unknown tokens or tokens issued
+// for another identifier and errTokenExpired for expired ones.
+func (r *Resolver) consumeVerificationToken(ctx context.Context, plain string, identifiers ...string) (*models.VerificationRequest, error) {
+	req, err := r.DB.GetVerificationRequestByToken(ctx, crypto.HashToken(plain))
+	if err != nil {
+		return nil, err
+	}
+	if !slices.Contains(identifiers, req.Identifier) {
+		return nil, models.ErrNotFound
+	}
+	if err := r.DB.DeleteVerificationRequest(ctx, req.ID); err != nil {
+		// a concurrent request consumed it first
+		return nil, err
+	}
+	if req.ExpiresAt < time.Now().Unix() {
+		return nil, errTokenExpired
+	}
+	return req, nil
+}
+
+// withToken appends the token query parameter to link
+func withToken(link, token string) (string, error) {
+	u, err := url.Parse(link)
+	if err != nil {
+		return "", err
+	}
+	q := u.Query()
+	q.Set("token", token)
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+// sendVerificationEmail emails a link to VERIFY_EMAIL_URL carrying a new token
+func (r *Resolver) sendVerificationEmail(ctx context.Context, user *models.User) error {
+	ttl := r.Config.VerificationTokenExpiryTime
+	plain, err := r.createVerificationToken(ctx, &models.VerificationRequest{
+		Identifier: models.VerificationTypeVerifyEmail,
+		Email:      user.Email,
+	}, ttl)
+	if err != nil {
+		return err
+	}
+	link, err := withToken(r.Config.VerifyEmailURL, plain)
+	if err != nil {
+		return err
+	}
+
+	msg, err := email.VerifyEmail(user.Email, email.TemplateData{Name: user.Name, Link: link, ExpiresIn: ttl})
+	if err != nil {
+		return err
+	}
+	return r.Mailer.Send(ctx, msg)
+}
+
+// sendResetPasswordEmail emails a link to RESET_PASSWORD_URL carrying a new token
+func (r *Resolver) sendResetPasswordEmail(ctx context.Context, user *models.User) error {
+	ttl := r.Config.ResetPasswordTokenExpiryTime
+	plain, err := r.createVerificationToken(ctx, &models.VerificationRequest{
+		Identifier: models.VerificationTypeForgotPassword,
+		Email:      user.Email,
+	}, ttl)
+	if err != nil {
+		return err
+	}
+	link, err := withToken(r.Config.ResetPasswordURL, plain)
+	if err != nil {
+		return err
+	}
+
+	msg, err := email.ResetPassword(user.Email, email.TemplateData{Name: user.Name, Link: link, ExpiresIn: ttl})
+	if err != nil {
+		return err
+	}
+	return r.Mailer.Send(ctx, msg)
+}
+
+// sendMagicLinkEmail emails a link to the /verify_email endpoint logging the
+// user in with the given roles and scope
+func (r *Resolver) sendMagicLinkEmail(ctx context.Context, user *models.User, roles, scope []string, redirectURI string) error {
+	ttl := r.Config.MagicLinkExpiryTime
+	req := &models.VerificationRequest{
+		Identifier:  models.VerificationTypeMagicLinkLogin,
+		Email:       user.Email,
+		RedirectURI: redirectURI,
+	}
+	req.SetRoles(roles)
+	req.SetScope(scope)
+	plain, err := r.createVerificationToken(ctx, req, ttl)
+	if err != nil {
+		return err
+	}
+	link, err := withToken(r.Config.AuthorizerURL+"/verify_email", plain)
+	if err != nil {
+		return err
+	}
+
+	msg, err := email.MagicLinkLogin(user.Email, email.TemplateData{Name: user.Name, Link: link, ExpiresIn: ttl})
+	if err != nil {
+		return err
+	}
+	return r.Mailer.Send(ctx, msg)
+}
+
+// LoginWithVerificationLink consumes the token of a magic link or of a
+// verification email, marks the email as verified and issues a session, or an
+// mfa token when the user needs a TOTP code. It returns the URI the user
+// should be redirected to, which is known even when ErrRolesNotGranted is
+// returned.
+func (r *Resolver) LoginWithVerificationLink(ctx context.Context, plain string) (string, *RedirectLogin, error) {
+	identifiers := []string{models.VerificationTypeVerifyEmail}
+	if !r.Config.DisableMagicLinkLogin {
+		identifiers = append(identifiers, models.VerificationTypeMagicLinkLogin)
+	}
+	req, err := r.consumeVerificationToken(ctx, plain, identifiers...)
+	if err != nil {
+		if errors.Is(err, models.ErrNotFound) || errors.Is(err, errTokenExpired) {
+			return "", nil, ErrInvalidVerificationLink
+		}
+		return "", nil, err
+	}
+	redirectURI := req.RedirectURI
+	if redirectURI == "" {
+		redirectURI = r.Config.AppURL
+	}
+
+	user, err := r.DB.GetUserByEmail(ctx, req.Email)
+	if err != nil {
+		if errors.Is(err, models.ErrNotFound) {
+			return redirectURI, nil, ErrInvalidVerificationLink
+		}
+		return redirectURI, nil, err
+	}
+
+	grant := token.Grant{Scope: req.ScopeList()}
+	if roles := req.RoleList(); len(roles) > 0 {
+		for _, role := range roles {
+			if !slices.Contains(user.RoleList(), role) {
+				return redirectURI, nil, ErrRolesNotGranted
+			}
+		}
+		grant.Roles = roles
+	}
+
+	if !user.IsEmailVerified() {
+		user.EmailVerifiedAt = refs.NewInt64Ref(time.Now().Unix())
+		if _, err := r.DB.UpdateUser(ctx, user); err != nil {
+			return redirectURI, nil, err
+		}
+	}
+
+	res, err := r.startRedirectLogin(ctx, user, grant, amrEmailLink)
+	if err != nil {
+		return redirectURI, nil, err
+	}
+	return redirectURI, res, nil
+}
@@ -0,0 +1,266 @@
+package handlers
+
+import (
+	"errors"
+	"html/template"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+
+	"server/graph"
+)
+
+// Response modes of the authorization endpoint
+const (
+	responseModeQuery      = "query"
+	responseModeFragment   = "fragment"
+	responseModeFormPost   = "form_post"
+	responseModeWebMessage = "web_message"
+)
+
+// Response types of the authorization endpoint
+const (
+	responseTypeCode  = "code"
+	responseTypeToken = "token"
+)
+
+var (
+	responseModes = []string{responseModeQuery, responseModeFragment, responseModeFormPost, responseModeWebMessage}
+	responseTypes = []string{responseTypeCode, responseTypeToken}
+)
+
+// AuthorizeHandler is the OAuth 2.0 authorization endpoint. It supports the
+// authorization code flow with PKCE and the implicit flow for the user of the
+// browser session, anonymous users are sent to the login page first.
+//
+// Errors are shown to the user until the client and redirect URI are known,
+// then they are sent to the client through the response mode.
+func AuthorizeHandler(resolver *graph.Resolver) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Cache-Control", "no-store")
+		ctx := c.Request.Context()
+
+		client, err := resolver.OAuthClient(ctx, c.Query("client_id"))
+		if err != nil {
+			if errors.Is(err, graph.ErrInvalidClient) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_client", "error_description": "unknown client_id"})
+				return
+			}
+			log.WithError(err).Error("failed to load oauth client")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "server_error", "error_description": "internal server error"})
+			return
+		}
+		redirectURI := c.Query("redirect_uri")
+		if redirectURI == "" && len(client.RedirectURIList()) == 1 {
+			redirectURI = client.RedirectURIList()[0]
+		}
+		if !slices.Contains(client.RedirectURIList(), redirectURI) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request", "error_description": "redirect_uri is not registered for the client"})
+			return
+		}
+
+		responseType := c.DefaultQuery("response_type", resolver.Config.DefaultAuthorizeResponseType)
+		responseMode := c.DefaultQuery("response_mode", resolver.Config.DefaultAuthorizeResponseMode)
+		if responseMode == "" {
+			responseMode = responseModeQuery
+			if responseType == responseTypeToken {
+				responseMode = responseModeFragment
+			}
+		}
+		state := c.Query("state")
+		fail := func(code, description string) {
+			params := url.Values{"error": {code}, "error_description": {description}}
+			if state != "" {
+				params.Set("state", state)
+			}
+			writeAuthorizeResponse(c, responseMode, redirectURI, params)
+		}
+
+		if !slices.Contains(responseModes, responseMode) {
+			// the client cannot be reached through an unknown mode
+			responseMode = responseModeQuery
+			fail("invalid_request", "unsupported response_mode")
+			return
+		}
+		if !slices.Contains(responseTypes, responseType) {
+			fail("unsupported_response_type", "unsupported response_type: "+responseType)
+			return
+		}
+		if responseType == responseTypeToken && responseMode == responseModeQuery {
+			// tokens must not end up in server logs
+			fail("invalid_request", "response_type token cannot use response_mode query")
+			return
+		}
+
+		codeChallenge := c.Query("code_challenge")
+		codeChallengeMethod := c.DefaultQuery("code_challenge_method", graph.CodeChallengeMethodPlain)
+		if responseType == responseTypeCode {
+			if codeChallenge == "" && client.IsPublic() {
+				fail("invalid_request", "code_challenge is required for public clients")
+				return
+			}
+			if codeChallengeMethod != graph.CodeChallengeMethodS256 && codeChallengeMethod != graph.CodeChallengeMethodPlain {
+				fail("invalid_request", "unsupported code_challenge_method")
+				return
+			}
+		}
+
+		user, session, err := resolver.CurrentSession(ctx)
+		if err != nil {
+			if !errors.Is(err, graph.ErrNoSession) {
+				log.WithError(err).Error("failed to load session")
+				fail("server_error", "internal server error")
+				return
+			}
+			if c.Query("prompt") == "none" {
+				fail("login_required", "the user is not logged in")
+				return
+			}
+			c.Redirect(http.StatusFound, loginRedirect(resolver, c))
+			return
+		}
+
+		scope, err := resolver.RequestedScope(c.Query("scope"))
+		if err != nil {
+			fail("invalid_scope", err.Error())
+			return
+		}
+		params := url.Values{}
+		if state != "" {
+			params.Set("state", state)
+		}
+		switch responseType {
+		case responseTypeCode:
+			code, err := resolver.CreateAuthorizationCode(ctx, user, session, graph.AuthorizationCode{
+				ClientID:            client.ClientID,
+				RedirectURI:         redirectURI,
+				RedirectURIProvided: c.Query("redirect_uri") != "",
+				CodeChallenge:       codeChallenge,
+				CodeChallengeMethod: codeChallengeMethod,
+				Scope:               scope,
+				Nonce:               c.Query("nonce"),
+			})
+			if err != nil {
+				log.WithError(err).Error("failed to create authorization code")
+				fail("server_error", "internal server error")
+				return
+			}
+			params.Set("code", code)
+		case responseTypeToken:
+			tokens, err := resolver.IssueSessionAccessToken(ctx, user, session, client.ClientID, scope, c.Query("nonce"))
+			if err != nil {
+				log.WithError(err).Error("failed to issue access token")
+				fail("server_error", "internal server error")
+				return
+			}
+			params.Set("access_token", tokens.AccessToken)
+			params.Set("token_type", "Bearer")
+			params.Set("expires_in", strconv.FormatInt(tokens.ExpiresIn, 10))
+			params.Set("scope", strings.Join(scope, " "))
+			if tokens.IDToken != "" {
+				params.Set("id_token", tokens.IDToken)
+			}
+		}
+		writeAuthorizeResponse(c, responseMode, redirectURI, params)
+	}
+}
+
+// loginRedirect is the login page URL bringing the user back to this
+// authorization request once logged in
+func loginRedirect(resolver *graph.Resolver, c *gin.Context) string {
+	back := strings.TrimSuffix(resolver.Config.AuthorizerURL, "/") + c.Request.URL.RequestURI()
+	u, err := url.Parse(resolver.Config.LoginURL)
+	if err != nil {
+		return resolver.Config.LoginURL
+	}
+	q := u.Query()
+	q.Set("redirect_uri", back)
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+// writeAuthorizeResponse sends the authorization response to the client
+// through the response mode
+func writeAuthorizeResponse(c *gin.Context, mode, redirectURI string, params url.Values) {
+	switch mode {
+	case responseModeFragment:
+		c.Redirect(http.StatusFound, withFragment(redirectURI, params))
+	case responseModeFormPost:
+		renderHTML(c, formPostTemplate, gin.H{"Action": redirectURI, "Params": params})
+	case responseModeWebMessage:
+		response := make(map[string]string, len(params))
+		for key := range params {
+			response[key] = params.Get(key)
+		}
+		renderHTML(c, webMessageTemplate, gin.H{"Origin": origin(redirectURI), "Response": response})
+	default:
+		c.Redirect(http.StatusFound, withQuery(redirectURI, params))
+	}
+}
+
+// withQuery adds the params to the query of uri
+func withQuery(uri string, params url.Values) string {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return uri
+	}
+	q := u.Query()
+	for key, values := range params {
+		q[key] = values
+	}
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+// origin returns the scheme and host of uri, the target of web messages
+func origin(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return ""
+	}
+	return u.Scheme + "://" + u.Host
+}
+
+func renderHTML(c *gin.Context, tmpl *template.Template, data interface{}) {
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	c.Status(http.StatusOK)
+	if err := tmpl.Execute(c.Writer, data); err != nil {
+		log.WithError(err).Error("failed to render authorization response")
+	}
+}
+
+// formPostTemplate posts the response to the redirect URI, see OAuth 2.0
+// Form Post Response Mode
+var formPostTemplate = template.Must(template.New("form_post").Parse(`<!DOCTYPE html>
+<html>
+<head><title>Submit this form</title></head>
+<body onload="document.forms[0].submit()">
+<form method="post" action="{{.Action}}">
+{{range $key, $values := .Params}}{{range $values}}<input type="hidden" name="{{$key}}" value="{{.}}">
+{{end}}{{end}}<noscript><button type="submit">Continue</button></noscript>
+</form>
+</body>
+</html>`))
+
+// webMessageTemplate posts the response to the window that opened the
+// authorization request, a popup opener or the parent of an iframe
+var webMessageTemplate = template.Must(template.New("web_message").Parse(`<!DOCTYPE html>
+<html>
+<head><title>Authorization response</title></head>
+<body>
+<script>
+(function () {
+  var target = window.opener || window.parent;
+  target.postMessage({ type: "authorization_response", response: {{.Response}} }, {{.Origin}});
+  if (window.opener) {
+    window.close();
+  }
+})();
+</script>
+</body>
+</html>`))
@@ -5,13 +5,29 @@ import (
 	"server/graph/generated"
 
 	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/99designs/gqlgen/graphql/handler/extension"
+	"github.com/99designs/gqlgen/graphql/handler/lru"
+	"github.com/99designs/gqlgen/graphql/handler/transport"
 	"github.com/99designs/gqlgen/graphql/playground"
 	"github.com/gin-gonic/gin"
+	"github.com/vektah/gqlparser/v2/ast"
 )
 
-// GraphQL handler
+// GraphQL handler. Only JSON POST requests are served: browsers cannot send
+// them cross-site without a CORS preflight, so the session and admin cookies
+// cannot be used by other sites to run mutations.
 func GraphQLHandler(resolver *graph.Resolver) gin.HandlerFunc {
-	h := handler.NewDefaultServer(generated.NewExecutableSchema(generated.Config{Resolvers: resolver}))
+	cfg := generated.Config{Resolvers: resolver}
+	cfg.Directives.IsAuthenticated = graph.IsAuthenticated
+	cfg.Directives.HasRole = graph.HasRole
+	cfg.Directives.IsAdmin = graph.IsAdmin
+	cfg.Directives.IsSuperAdmin = graph.IsSuperAdmin
+	h := handler.New(generated.NewExecutableSchema(cfg))
+	h.AddTransport(transport.Options{})
+	h.AddTransport(transport.POST{})
+	h.SetQueryCache(lru.New[*ast.QueryDocument](1000))
+	h.Use(extension.Introspection{})
+	h.Use(extension.AutomaticPersistedQuery{Cache: lru.New[string](100)})
 
 	return func(c *gin.Context) {
 		h.ServeHTTP(c.Writer, c.Request)
@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+
+	"server/cookie"
+	"server/graph"
+	"server/token"
+)
+
+// OAuthLoginHandler sends the user to the login page of an external
+// provider. The redirect_uri, roles (comma separated) and scope (space
+// separated) query parameters describe the session to start once they are
+// back, and the state of the login is bound to the browser with a cookie.
+func OAuthLoginHandler(resolver *graph.Resolver) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var roles []string
+		for _, role := range strings.Split(c.Query("roles"), ",") {
+			if role = strings.TrimSpace(role); role != "" {
+				roles = append(roles, role)
+			}
+		}
+
+		authURL, state, err := resolver.StartOAuthLogin(c.Request.Context(), c.Param("provider"), graph.OAuthLoginRequest{
+			RedirectURI: c.Query("redirect_uri"),
+			Roles:       roles,
+			Scope:       strings.Fields(c.Query("scope")),
+		})
+		if err != nil {
+			switch {
+			case errors.Is(err, graph.ErrUnknownOAuthProvider):
+				c.JSON(http.StatusNotFound, gin.H{"error": "invalid_request", "error_description": err.Error()})
+			case errors.Is(err, graph.ErrInvalidOAuthRequest):
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request", "error_description": err.Error()})
+			case errors.Is(err, graph.ErrOAuthProviderFailed):
+				log.WithError(err).Warn("oauth login provider unavailable")
+				c.JSON(http.StatusBadGateway, gin.H{"error": "temporarily_unavailable", "error_description": graph.ErrOAuthProviderFailed.Error()})
+			default:
+				log.WithError(err).Error("failed to start oauth login")
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "server_error", "error_description": "internal server error"})
+			}
+			return
+		}
+
+		c.Header("Cache-Control", "no-store")
+		cookie.SetOAuthState(c, resolver.Config, state, graph.OAuthStateTTL)
+		c.Redirect(http.StatusFound, authURL)
+	}
+}
+
+// OAuthCallbackHandler is where external providers send the user back to,
+// with the parameters in the query or posted as a form. The user is logged
+// in and redirected like with VerifyEmailHandler.
+func OAuthCallbackHandler(resolver *graph.Resolver) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		param := func(key string) string {
+			if value, ok := c.GetPostForm(key); ok {
+				return value
+			}
+			return c.Query(key)
+		}
+		ctx := c.Request.Context()
+		name, state := c.Param("provider"), param("state")
+		browserState := cookie.GetOAuthState(c)
+		cookie.DeleteOAuthState(c, resolver.Config)
+
+		if providerError := param("error"); providerError != "" {
+			redirectURI, err := resolver.AbortOAuthLogin(ctx, name, state, browserState)
+			if err != nil {
+				oauthCallbackError(c, "", err)
+				return
+			}
+			redirectWithError(c, redirectURI, "access_denied", "the login provider answered "+providerError)
+			return
+		}
+
+		redirectURI, login, err := resolver.CompleteOAuthLogin(ctx, name, state, browserState, param("code"))
+		if err != nil {
+			oauthCallbackError(c, redirectURI, err)
+			return
+		}
+		redirectWithLogin(c, resolver, redirectURI, login)
+	}
+}
+
+// oauthCallbackError reports a failed login, to the redirect URI once known
+func oauthCallbackError(c *gin.Context, redirectURI string, err error) {
+	status, code, description := http.StatusInternalServerError, "server_error", "internal server error"
+	switch {
+	case errors.Is(err, graph.ErrUnknownOAuthProvider), errors.Is(err, graph.ErrInvalidOAuthState):
+		status, code, description = http.StatusBadRequest, "invalid_request", err.Error()
+	case errors.Is(err, graph.ErrOAuthProviderFailed):
+		log.WithError(err).Warn("oauth login failed at the provider")
+		status, code, description = http.StatusBadRequest, "access_denied", graph.ErrOAuthProviderFailed.Error()
+	case errors.Is(err, graph.ErrUnverifiedProviderEmail), errors.Is(err, graph.ErrSignUpDisabled), errors.Is(err, graph.ErrRolesNotGranted):
+		status, code, description = http.StatusForbidden, "access_denied", err.Error()
+	default:
+		log.WithError(err).Error("failed to complete oauth login")
+	}
+	if redirectURI == "" {
+		c.JSON(status, gin.H{"error": code, "error_description": description})
+		return
+	}
+	redirectWithError(c, redirectURI, code, description)
+}
+
+// redirectWithLogin sends the outcome of the login to the redirect URI in the
+// fragment. Logins waiting for a TOTP code get mfa_required and the
+// mfa_token to pass to verifyTotp, along with the secret to enrol when the
+// user has no authenticator app.
+func redirectWithLogin(c *gin.Context, resolver *graph.Resolver, redirectURI string, login *graph.RedirectLogin) {
+	if login.Tokens != nil {
+		redirectWithTokens(c, resolver, redirectURI, login.Tokens)
+		return
+	}
+	params := url.Values{
+		"mfa_required": {"true"},
+		"mfa_token":    {login.MFAToken},
+	}
+	if login.TOTPEnrollment != nil {
+		params.Set("totp_secret", login.TOTPEnrollment.Secret)
+		params.Set("totp_uri", login.TOTPEnrollment.URI)
+	}
+	c.Redirect(http.StatusFound, withFragment(redirectURI, params))
+}
+
+// redirectWithTokens starts the browser session and sends the tokens to the
+// redirect URI in the fragment
+func redirectWithTokens(c *gin.Context, resolver *graph.Resolver, redirectURI string, tokens *token.AuthTokens) {
+	cookie.SetSession(c, resolver.Config, tokens.RefreshToken)
+	c.Redirect(http.StatusFound, withFragment(redirectURI, url.Values{
+		"access_token":  {tokens.AccessToken},
+		"token_type":    {"Bearer"},
+		"expires_in":    {strconv.FormatInt(tokens.ExpiresIn, 10)},
+		"refresh_token": {tokens.RefreshToken},
+	}))
+}
+
+// redirectWithError sends the error to the redirect URI in the fragment
+func redirectWithError(c *gin.Context, redirectURI, code, description string) {
+	c.Redirect(http.StatusFound, withFragment(redirectURI, url.Values{
+		"error":             {code},
+		"error_description": {description},
+	}))
+}
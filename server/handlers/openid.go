@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"server/graph"
+)
+
+// openIDConfiguration is the OpenID Connect discovery document
+type openIDConfiguration struct {
+	Issuer                            string   `json:"issuer"`
+	AuthorizationEndpoint             string   `json:"authorization_endpoint"`
+	TokenEndpoint                     string   `json:"token_endpoint"`
+	UserInfoEndpoint                  string   `json:"userinfo_endpoint"`
+	JwksURI                           string   `json:"jwks_uri"`
+	ResponseTypesSupported            []string `json:"response_types_supported"`
+	ResponseModesSupported            []string `json:"response_modes_supported"`
+	GrantTypesSupported               []string `json:"grant_types_supported"`
+	CodeChallengeMethodsSupported     []string `json:"code_challenge_methods_supported"`
+	SubjectTypesSupported             []string `json:"subject_types_supported"`
+	IDTokenSigningAlgValuesSupported  []string `json:"id_token_signing_alg_values_supported,omitempty"`
+	TokenEndpointAuthMethodsSupported []string `json:"token_endpoint_auth_methods_supported"`
+	ScopesSupported                   []string `json:"scopes_supported"`
+	ClaimsSupported                   []string `json:"claims_supported"`
+}
+
+// OpenIDConfigurationHandler serves /.well-known/openid-configuration. ID
+// tokens and the openid scope are only advertised when the tokens are signed
+// with a key the JWKS publishes.
+func OpenIDConfigurationHandler(resolver *graph.Resolver) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		issuer := strings.TrimSuffix(resolver.Config.AuthorizerURL, "/")
+		scopes := []string{"email", "profile"}
+		var idTokenAlgs []string
+		if resolver.Tokens.IssuesIDTokens() {
+			scopes = append([]string{"openid"}, scopes...)
+			idTokenAlgs = []string{resolver.Tokens.Algorithm()}
+		}
+		c.JSON(http.StatusOK, openIDConfiguration{
+			Issuer:                            resolver.Config.AuthorizerURL,
+			AuthorizationEndpoint:             issuer + "/authorize",
+			TokenEndpoint:                     issuer + "/oauth/token",
+			UserInfoEndpoint:                  issuer + "/userinfo",
+			JwksURI:                           issuer + "/.well-known/jwks.json",
+			ResponseTypesSupported:            responseTypes,
+			ResponseModesSupported:            responseModes,
+			GrantTypesSupported:               []string{"authorization_code", "implicit", "refresh_token"},
+			CodeChallengeMethodsSupported:     []string{graph.CodeChallengeMethodS256, graph.CodeChallengeMethodPlain},
+			SubjectTypesSupported:             []string{"public"},
+			IDTokenSigningAlgValuesSupported:  idTokenAlgs,
+			TokenEndpointAuthMethodsSupported: []string{"none", "client_secret_basic", "client_secret_post"},
+			ScopesSupported:                   scopes,
+			ClaimsSupported: []string{
+				"iss", "sub", "aud", "exp", "iat", "nbf", "jti", "auth_time", "nonce", "amr", "sid", "scope",
+				"name", "updated_at", "email", "email_verified", resolver.Config.JwtRoleClaim,
+			},
+		})
+	}
+}
+
+// JWKSHandler serves /.well-known/jwks.json. Clients refetch it when they see
+// an unknown kid, the short cache lets rotated keys show up quickly.
+func JWKSHandler(resolver *graph.Resolver) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Cache-Control", "public, max-age=300")
+		c.JSON(http.StatusOK, resolver.Tokens.JWKS())
+	}
+}
@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+
+	"server/graph"
+	"server/token"
+)
+
+// tokenRequest is the body of a token request, form or JSON encoded
+type tokenRequest struct {
+	GrantType    string `form:"grant_type" json:"grant_type"`
+	RefreshToken string `form:"refresh_token" json:"refresh_token"`
+	Code         string `form:"code" json:"code"`
+	RedirectURI  string `form:"redirect_uri" json:"redirect_uri"`
+	CodeVerifier string `form:"code_verifier" json:"code_verifier"`
+	ClientID     string `form:"client_id" json:"client_id"`
+	ClientSecret string `form:"client_secret" json:"client_secret"`
+}
+
+// TokenHandler is the OAuth 2.0 token endpoint. It supports the
+// authorization_code grant, with PKCE, and the refresh_token grant, answering
+// with the RFC 6749 token response and error codes. Confidential clients
+// authenticate with HTTP Basic or the client_secret parameter. Refresh tokens
+// issued to a client can only be refreshed by that client, those of direct
+// logins without client credentials.
+func TokenHandler(resolver *graph.Resolver) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Cache-Control", "no-store")
+		c.Header("Pragma", "no-cache")
+
+		var req tokenRequest
+		if err := c.ShouldBind(&req); err != nil {
+			tokenError(c, http.StatusBadRequest, "invalid_request", "malformed request body")
+			return
+		}
+
+		if id, secret, ok := c.Request.BasicAuth(); ok {
+			req.ClientID, req.ClientSecret = id, secret
+		}
+
+		switch req.GrantType {
+		case "authorization_code":
+			if req.Code == "" {
+				tokenError(c, http.StatusBadRequest, "invalid_request", "code is required")
+				return
+			}
+			_, tokens, err := resolver.ExchangeAuthorizationCode(c.Request.Context(), graph.CodeExchange{
+				Code:         req.Code,
+				ClientID:     req.ClientID,
+				ClientSecret: req.ClientSecret,
+				RedirectURI:  req.RedirectURI,
+				CodeVerifier: req.CodeVerifier,
+			})
+			if err != nil {
+				switch {
+				case errors.Is(err, graph.ErrInvalidClient):
+					tokenError(c, http.StatusUnauthorized, "invalid_client", err.Error())
+				case errors.Is(err, graph.ErrInvalidGrant):
+					tokenError(c, http.StatusBadRequest, "invalid_grant", err.Error())
+				default:
+					log.WithError(err).Error("failed to exchange authorization code")
+					tokenError(c, http.StatusInternalServerError, "server_error", "internal server error")
+				}
+				return
+			}
+			tokenResponse(c, tokens)
+		case "refresh_token":
+			if req.RefreshToken == "" {
+				tokenError(c, http.StatusBadRequest, "invalid_request", "refresh_token is required")
+				return
+			}
+			if req.ClientID != "" || req.ClientSecret != "" {
+				if _, err := resolver.AuthenticateClient(c.Request.Context(), req.ClientID, req.ClientSecret); err != nil {
+					if errors.Is(err, graph.ErrInvalidClient) {
+						tokenError(c, http.StatusUnauthorized, "invalid_client", err.Error())
+						return
+					}
+					log.WithError(err).Error("failed to authenticate client")
+					tokenError(c, http.StatusInternalServerError, "server_error", "internal server error")
+					return
+				}
+			}
+			_, tokens, err := resolver.RefreshSession(c.Request.Context(), req.RefreshToken, req.ClientID)
+			if err != nil {
+				if errors.Is(err, graph.ErrInvalidRefreshToken) {
+					tokenError(c, http.StatusBadRequest, "invalid_grant", err.Error())
+					return
+				}
+				log.WithError(err).Error("failed to refresh session")
+				tokenError(c, http.StatusInternalServerError, "server_error", "internal server error")
+				return
+			}
+			tokenResponse(c, tokens)
+		case "":
+			tokenError(c, http.StatusBadRequest, "invalid_request", "grant_type is required")
+		default:
+			tokenError(c, http.StatusBadRequest, "unsupported_grant_type", "unsupported grant_type: "+req.GrantType)
+		}
+	}
+}
+
+func tokenResponse(c *gin.Context, tokens *token.AuthTokens) {
+	res := gin.H{
+		"access_token":  tokens.AccessToken,
+		"token_type":    "Bearer",
+		"expires_in":    tokens.ExpiresIn,
+		"refresh_token": tokens.RefreshToken,
+	}
+	if tokens.IDToken != "" {
+		res["id_token"] = tokens.IDToken
+	}
+	c.JSON(http.StatusOK, res)
+}
+
+func tokenError(c *gin.Context, status int, code, description string) {
+	c.JSON(status, gin.H{"error": code, "error_description": description})
+}
@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+
+	"server/auth"
+	"server/graph"
+	"server/token"
+)
+
+// UserInfoHandler is the OpenID Connect userinfo endpoint. It returns the
+// claims about the user the scope of the access token gives access to. The
+// session cookie is not accepted, only Bearer tokens granted openid.
+func UserInfoHandler(resolver *graph.Resolver) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Cache-Control", "no-store")
+		ctx := c.Request.Context()
+
+		principal, ok := auth.PrincipalFromContext(ctx)
+		if !ok || c.GetHeader("Authorization") == "" {
+			bearerError(c, http.StatusUnauthorized, "invalid_token", "a valid access token is required")
+			return
+		}
+		if !principal.HasScope("openid") {
+			bearerError(c, http.StatusForbidden, "insufficient_scope", "the access token was not granted the openid scope")
+			return
+		}
+
+		user, _, err := resolver.CurrentSession(ctx)
+		if err != nil {
+			if errors.Is(err, graph.ErrNoSession) {
+				bearerError(c, http.StatusUnauthorized, "invalid_token", "the session has been revoked")
+				return
+			}
+			log.WithError(err).Error("failed to load session")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "server_error", "error_description": "internal server error"})
+			return
+		}
+		c.JSON(http.StatusOK, token.UserInfoClaims(user, principal.Scopes))
+	}
+}
+
+// bearerError answers with an RFC 6750 error
+func bearerError(c *gin.Context, status int, code, description string) {
+	c.Header("WWW-Authenticate", `Bearer error="`+code+`", error_description="`+description+`"`)
+	c.JSON(status, gin.H{"error": code, "error_description": description})
+}
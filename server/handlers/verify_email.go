@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+
+	"server/graph"
+)
+
+// VerifyEmailHandler exchanges the token of a magic link or verification
+// email for a session and redirects to the redirect URI of the link. Tokens,
+// or the mfa token when a TOTP code is required, are passed in the URL
+// fragment so they are not sent to any server.
+func VerifyEmailHandler(resolver *graph.Resolver) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := c.Query("token")
+		if token == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request", "error_description": "token is required"})
+			return
+		}
+
+		redirectURI, login, err := resolver.LoginWithVerificationLink(c.Request.Context(), token)
+		if err != nil {
+			code, description := "server_error", "internal server error"
+			switch {
+			case errors.Is(err, graph.ErrInvalidVerificationLink):
+				code, description = "invalid_token", err.Error()
+			case errors.Is(err, graph.ErrRolesNotGranted):
+				code, description = "access_denied", err.Error()
+			default:
+				log.WithError(err).Error("failed to verify email link")
+			}
+			if redirectURI == "" {
+				status := http.StatusBadRequest
+				if code == "server_error" {
+					status = http.StatusInternalServerError
+				}
+				c.JSON(status, gin.H{"error": code, "error_description": description})
+				return
+			}
+			redirectWithError(c, redirectURI, code, description)
+			return
+		}
+
+		redirectWithLogin(c, resolver, redirectURI, login)
+	}
+}
+
+// withFragment replaces the fragment of uri with the encoded params
+func withFragment(uri string, params url.Values) string {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return uri
+	}
+	u.Fragment = ""
+	return u.String() + "#" + params.Encode()
+}
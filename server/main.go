@@ -1,15 +1,10 @@
 package main
 
 import (
-	// "context"
-	// "net/http"
-	// "os"
-	// "os/signal"
+	"os"
+	"server/cmd"
 	"server/config"
 	"server/logs"
-	"server/routes"
-	// "syscall"
-	// "time"
 )
 
 func main() {
@@ -19,12 +14,7 @@ func main() {
 	// Initialize logger
 	logger := logs.InitLog("info")
 
-	// Initialize Gin router with the logger
-	r := routes.InitRouter(logger)
-
-	// Start server in a goroutine
-	logger.Printf("Server starting on port %s", cfg.Port)
-	logger.Printf("GraphQL Playground available at http://localhost:%s/", cfg.Port)
-	logger.Fatal(r.Run(":" + cfg.Port))
-
+	if err := cmd.Execute(os.Args[1:], cfg, logger); err != nil {
+		logger.Fatal(err)
+	}
 }
@@ -0,0 +1,135 @@
+package middlewares
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+
+	"server/auth"
+	"server/config"
+	"server/cookie"
+	"server/crypto"
+	"server/database"
+	"server/database/models"
+	"server/sessionstore"
+	"server/token"
+)
+
+// Authentication verifies the Bearer access token, or the session cookie when
+// there is no Authorization header, and stores the resulting principal in the
+// request context. Requests without valid credentials continue anonymously,
+// it is up to the handlers to require a principal.
+//
+// The roles of the principal are those of the stored session still held by
+// the user, so removing a role takes effect on the next request rather than
+// when the token expires.
+//
+// The admin secret header, or the admin cookie set by adminLogin, marks the
+// request as an admin request independently of the user credentials.
+func Authentication(cfg *config.Config, tokens *token.Manager, sessions sessionstore.SessionStore, users database.UserRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if isAdminRequest(c, cfg, sessions) {
+			c.Request = c.Request.WithContext(auth.WithAdmin(c.Request.Context()))
+		}
+
+		var claims *token.Claims
+		var err error
+		refreshToken := ""
+		if header := c.GetHeader("Authorization"); header != "" {
+			bearer, ok := strings.CutPrefix(header, "Bearer ")
+			if !ok {
+				c.Next()
+				return
+			}
+			claims, err = tokens.ParseToken(bearer, token.TypeAccessToken)
+		} else if refreshToken = cookie.GetSession(c); refreshToken != "" {
+			claims, err = tokens.ParseToken(refreshToken, token.TypeRefreshToken)
+		} else {
+			c.Next()
+			return
+		}
+		if err != nil || claims.SessionID == "" {
+			c.Next()
+			return
+		}
+
+		// revoked sessions are removed from the store
+		session, err := sessions.GetSession(c.Request.Context(), claims.SessionID)
+		if err != nil {
+			if !errors.Is(err, sessionstore.ErrNotFound) {
+				log.WithError(err).Error("failed to load session")
+			}
+			c.Next()
+			return
+		}
+		if session.UserID != claims.Subject {
+			c.Next()
+			return
+		}
+		// a cookie holding a rotated refresh token no longer identifies the session
+		if refreshToken != "" && session.RefreshTokenHash != crypto.HashToken(refreshToken) {
+			c.Next()
+			return
+		}
+
+		user, err := users.GetUserByID(c.Request.Context(), session.UserID)
+		if err != nil {
+			if !errors.Is(err, models.ErrNotFound) {
+				log.WithError(err).Error("failed to load user")
+			}
+			c.Next()
+			return
+		}
+		if user.IsSessionRevoked(time.Unix(session.CreatedAt, 0)) {
+			c.Next()
+			return
+		}
+
+		principal := &auth.Principal{
+			UserID:    claims.Subject,
+			Roles:     session.GrantedRoles(user.RoleList()),
+			Scopes:    strings.Fields(claims.Scope),
+			SessionID: claims.SessionID,
+			ClientID:  session.ClientID,
+		}
+		c.Request = c.Request.WithContext(auth.WithPrincipal(c.Request.Context(), principal))
+		c.Next()
+	}
+}
+
+// FirstParty drops the principal of requests authenticated with tokens
+// issued to OAuth clients. It guards the API of the applications of the
+// server itself, so that a client given access to the user info cannot also
+// manage the account or the sessions of the user.
+func FirstParty() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if principal, ok := auth.PrincipalFromContext(c.Request.Context()); ok && principal.ClientID != "" {
+			c.Request = c.Request.WithContext(auth.WithPrincipal(c.Request.Context(), nil))
+		}
+		c.Next()
+	}
+}
+
+// isAdminRequest checks the admin secret header, then the admin cookie
+func isAdminRequest(c *gin.Context, cfg *config.Config, sessions sessionstore.SessionStore) bool {
+	if cfg.AdminSecret == "" {
+		return false
+	}
+	if secret := c.GetHeader(auth.AdminSecretHeader); secret != "" {
+		return auth.IsAdminSecret(cfg.AdminSecret, secret)
+	}
+	adminToken := cookie.GetAdmin(c)
+	if adminToken == "" {
+		return false
+	}
+	if _, err := sessions.GetState(c.Request.Context(), auth.AdminSessionKey(adminToken)); err != nil {
+		if !errors.Is(err, sessionstore.ErrNotFound) {
+			log.WithError(err).Error("failed to load admin session")
+		}
+		return false
+	}
+	return true
+}
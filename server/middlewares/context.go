@@ -0,0 +1,29 @@
+package middlewares
+
+import (
+	"context"
+	"errors"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ginContextKey struct{}
+
+// GinContextToContextMiddleware stores the gin context in the request context
+// so GraphQL resolvers can read headers and set cookies
+func GinContextToContextMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := context.WithValue(c.Request.Context(), ginContextKey{}, c)
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}
+}
+
+// GinContextFromContext returns the gin context stored by GinContextToContextMiddleware
+func GinContextFromContext(ctx context.Context) (*gin.Context, error) {
+	c, ok := ctx.Value(ginContextKey{}).(*gin.Context)
+	if !ok {
+		return nil, errors.New("gin context not found, is GinContextToContextMiddleware installed?")
+	}
+	return c, nil
+}
@@ -0,0 +1,44 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"server/config"
+	"server/constants"
+)
+
+// NewApple returns the Sign in with Apple provider, see
+// https://developer.apple.com/documentation/sign_in_with_apple. The client
+// secret is the JWT signed with the key of the team, Apple has no userinfo
+// endpoint so the profile is read from the ID token.
+func NewApple(creds config.OAuthProviderCredentials) *OAuth2Provider {
+	return &OAuth2Provider{
+		ProviderName: constants.OAuthProviderApple,
+		ClientID:     creds.ClientID,
+		ClientSecret: creds.ClientSecret,
+		AuthURL:      "https://appleid.apple.com/auth/authorize",
+		TokenURL:     "https://appleid.apple.com/auth/token",
+		Scopes:       []string{"name", "email"},
+		// Apple posts the response when the name or email is requested
+		AuthParams: url.Values{"response_mode": {"form_post"}},
+		OpenID:     true,
+		profile:    idTokenProfile,
+	}
+}
+
+// idTokenProfile reads the profile from the claims of the ID token
+func idTokenProfile(_ context.Context, _ *OAuth2Provider, _ *Token, idToken jwt.MapClaims) (*Profile, error) {
+	data, err := json.Marshal(idToken)
+	if err != nil {
+		return nil, err
+	}
+	var claims openIDClaims
+	if err := json.Unmarshal(data, &claims); err != nil {
+		return nil, err
+	}
+	return claims.profile(), nil
+}
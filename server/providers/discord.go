@@ -0,0 +1,43 @@
+package providers
+
+import (
+	"context"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"server/config"
+	"server/constants"
+)
+
+// NewDiscord returns the Discord provider, see
+// https://discord.com/developers/docs/topics/oauth2
+func NewDiscord(creds config.OAuthProviderCredentials) *OAuth2Provider {
+	return &OAuth2Provider{
+		ProviderName: constants.OAuthProviderDiscord,
+		ClientID:     creds.ClientID,
+		ClientSecret: creds.ClientSecret,
+		AuthURL:      "https://discord.com/oauth2/authorize",
+		TokenURL:     "https://discord.com/api/oauth2/token",
+		UserInfoURL:  "https://discord.com/api/users/@me",
+		Scopes:       []string{"identify", "email"},
+		profile:      discordProfile,
+	}
+}
+
+func discordProfile(ctx context.Context, p *OAuth2Provider, tok *Token, _ jwt.MapClaims) (*Profile, error) {
+	var user struct {
+		ID         string `json:"id"`
+		Username   string `json:"username"`
+		GlobalName string `json:"global_name"`
+		Email      string `json:"email"`
+		Verified   bool   `json:"verified"`
+	}
+	if err := p.getJSON(ctx, p.UserInfoURL, tok, &user); err != nil {
+		return nil, err
+	}
+	name := user.GlobalName
+	if name == "" {
+		name = user.Username
+	}
+	return &Profile{ID: user.ID, Email: user.Email, EmailVerified: user.Verified, Name: name}, nil
+}
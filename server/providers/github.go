@@ -0,0 +1,63 @@
+package providers
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"server/config"
+	"server/constants"
+)
+
+// NewGithub returns the GitHub provider, see
+// https://docs.github.com/apps/oauth-apps/building-oauth-apps/authorizing-oauth-apps.
+// The emails of the user are read from UserInfoURL/emails.
+func NewGithub(creds config.OAuthProviderCredentials) *OAuth2Provider {
+	return &OAuth2Provider{
+		ProviderName: constants.OAuthProviderGithub,
+		ClientID:     creds.ClientID,
+		ClientSecret: creds.ClientSecret,
+		AuthURL:      "https://github.com/login/oauth/authorize",
+		TokenURL:     "https://github.com/login/oauth/access_token",
+		UserInfoURL:  "https://api.github.com/user",
+		Scopes:       []string{"read:user", "user:email"},
+		profile:      githubProfile,
+	}
+}
+
+// githubProfile uses the primary email of the user, the public email of the
+// profile may be unverified or missing
+func githubProfile(ctx context.Context, p *OAuth2Provider, tok *Token, _ jwt.MapClaims) (*Profile, error) {
+	var user struct {
+		ID    int64  `json:"id"`
+		Login string `json:"login"`
+		Name  string `json:"name"`
+	}
+	if err := p.getJSON(ctx, p.UserInfoURL, tok, &user); err != nil {
+		return nil, err
+	}
+	var emails []struct {
+		Email    string `json:"email"`
+		Primary  bool   `json:"primary"`
+		Verified bool   `json:"verified"`
+	}
+	if err := p.getJSON(ctx, p.UserInfoURL+"/emails", tok, &emails); err != nil {
+		return nil, err
+	}
+
+	profile := &Profile{Name: user.Name}
+	if user.ID != 0 {
+		profile.ID = strconv.FormatInt(user.ID, 10)
+	}
+	if profile.Name == "" {
+		profile.Name = user.Login
+	}
+	for _, email := range emails {
+		if email.Primary {
+			profile.Email = email.Email
+			profile.EmailVerified = email.Verified
+		}
+	}
+	return profile, nil
+}
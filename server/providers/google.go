@@ -0,0 +1,22 @@
+package providers
+
+import (
+	"server/config"
+	"server/constants"
+)
+
+// NewGoogle returns the Google provider, see
+// https://developers.google.com/identity/openid-connect/openid-connect
+func NewGoogle(creds config.OAuthProviderCredentials) *OAuth2Provider {
+	return &OAuth2Provider{
+		ProviderName: constants.OAuthProviderGoogle,
+		ClientID:     creds.ClientID,
+		ClientSecret: creds.ClientSecret,
+		AuthURL:      "https://accounts.google.com/o/oauth2/v2/auth",
+		TokenURL:     "https://oauth2.googleapis.com/token",
+		UserInfoURL:  "https://openidconnect.googleapis.com/v1/userinfo",
+		Scopes:       []string{"openid", "email", "profile"},
+		OpenID:       true,
+		profile:      userInfoProfile,
+	}
+}
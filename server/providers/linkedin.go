@@ -0,0 +1,22 @@
+package providers
+
+import (
+	"server/config"
+	"server/constants"
+)
+
+// NewLinkedIn returns the LinkedIn provider, see
+// https://learn.microsoft.com/linkedin/consumer/integrations/self-serve/sign-in-with-linkedin-v2
+func NewLinkedIn(creds config.OAuthProviderCredentials) *OAuth2Provider {
+	return &OAuth2Provider{
+		ProviderName: constants.OAuthProviderLinkedIn,
+		ClientID:     creds.ClientID,
+		ClientSecret: creds.ClientSecret,
+		AuthURL:      "https://www.linkedin.com/oauth/v2/authorization",
+		TokenURL:     "https://www.linkedin.com/oauth/v2/accessToken",
+		UserInfoURL:  "https://api.linkedin.com/v2/userinfo",
+		Scopes:       []string{"openid", "email", "profile"},
+		OpenID:       true,
+		profile:      userInfoProfile,
+	}
+}
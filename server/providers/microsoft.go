@@ -0,0 +1,44 @@
+package providers
+
+import (
+	"context"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"server/config"
+	"server/constants"
+)
+
+// consumersTenantID is the tenant of personal Microsoft accounts
+const consumersTenantID = "9188040d-6c67-4c5b-b112-36a304b66dad"
+
+// NewMicrosoft returns the Microsoft identity platform provider for the
+// tenant, see https://learn.microsoft.com/entra/identity-platform/v2-protocols-oidc
+func NewMicrosoft(creds config.OAuthProviderCredentials, tenantID string) *OAuth2Provider {
+	base := "https://login.microsoftonline.com/" + tenantID + "/oauth2/v2.0"
+	return &OAuth2Provider{
+		ProviderName: constants.OAuthProviderMicrosoft,
+		ClientID:     creds.ClientID,
+		ClientSecret: creds.ClientSecret,
+		AuthURL:      base + "/authorize",
+		TokenURL:     base + "/token",
+		UserInfoURL:  "https://graph.microsoft.com/oidc/userinfo",
+		Scopes:       []string{"openid", "email", "profile"},
+		OpenID:       true,
+		profile:      microsoftProfile,
+	}
+}
+
+// microsoftProfile reads the userinfo endpoint. Directory administrators can
+// set the email of their users to any address, so it is only trusted for
+// personal accounts or when the xms_edov claim says the domain is verified.
+func microsoftProfile(ctx context.Context, p *OAuth2Provider, tok *Token, idToken jwt.MapClaims) (*Profile, error) {
+	profile, err := userInfoProfile(ctx, p, tok, idToken)
+	if err != nil {
+		return nil, err
+	}
+	tenantID, _ := idToken["tid"].(string)
+	verifiedDomain, _ := idToken["xms_edov"].(bool)
+	profile.EmailVerified = tenantID == consumersTenantID || verifiedDomain
+	return profile, nil
+}
@@ -0,0 +1,268 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// ErrInvalidIDToken is returned for ID tokens that are missing, expired or
+// were not issued for this client and authorization request
+var ErrInvalidIDToken = errors.New("invalid id token")
+
+// defaultHTTPClient bounds the time spent waiting on providers
+var defaultHTTPClient = &http.Client{Timeout: 10 * time.Second}
+
+// profileFunc loads the profile of the user once the code is exchanged.
+// idToken holds the claims of the ID token of OpenID Connect providers.
+type profileFunc func(ctx context.Context, p *OAuth2Provider, tok *Token, idToken jwt.MapClaims) (*Profile, error)
+
+// OAuth2Provider implements OAuthProvider for providers following the OAuth
+// 2.0 authorization code flow, the differences between them being described
+// by its fields. PKCE is always used.
+type OAuth2Provider struct {
+	ProviderName string
+	ClientID     string
+	ClientSecret string
+	AuthURL      string
+	TokenURL     string
+	// UserInfoURL is the endpoint returning the profile of the user
+	UserInfoURL string
+	Scopes      []string
+	// AuthParams are added to the authorization URL
+	AuthParams url.Values
+	// BasicAuth sends the client credentials to the token endpoint with HTTP
+	// Basic authentication instead of the request body
+	BasicAuth bool
+	// OpenID marks OpenID Connect providers, which get the nonce and must
+	// return an ID token carrying it
+	OpenID bool
+	// Issuer must be the iss claim of the ID tokens when set
+	Issuer     string
+	HTTPClient *http.Client
+
+	profile profileFunc
+}
+
+// Name implements OAuthProvider
+func (p *OAuth2Provider) Name() string {
+	return p.ProviderName
+}
+
+// AuthCodeURL implements OAuthProvider
+func (p *OAuth2Provider) AuthCodeURL(redirectURI, state, nonce, codeChallenge string) string {
+	params := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.ClientID},
+		"redirect_uri":          {redirectURI},
+		"scope":                 {strings.Join(p.Scopes, " ")},
+		"state":                 {state},
+		"code_challenge":        {codeChallenge},
+		"code_challenge_method": {"S256"},
+	}
+	if p.OpenID {
+		params.Set("nonce", nonce)
+	}
+	for key, values := range p.AuthParams {
+		params[key] = values
+	}
+
+	sep := "?"
+	if strings.Contains(p.AuthURL, "?") {
+		sep = "&"
+	}
+	return p.AuthURL + sep + params.Encode()
+}
+
+// Exchange implements OAuthProvider
+func (p *OAuth2Provider) Exchange(ctx context.Context, redirectURI, code, codeVerifier string) (*Token, error) {
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {redirectURI},
+		"code_verifier": {codeVerifier},
+		"client_id":     {p.ClientID},
+	}
+	if !p.BasicAuth {
+		form.Set("client_secret", p.ClientSecret)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if p.BasicAuth {
+		req.SetBasicAuth(url.QueryEscape(p.ClientID), url.QueryEscape(p.ClientSecret))
+	}
+
+	// GitHub answers errors with a 200 status
+	var res struct {
+		Token
+		oauthError
+	}
+	if err := p.do(req, &res); err != nil {
+		return nil, err
+	}
+	if res.Error != "" {
+		return nil, fmt.Errorf("%s token request failed: %s %s", p.ProviderName, res.Error, res.ErrorDescription)
+	}
+	if res.AccessToken == "" {
+		return nil, fmt.Errorf("%s token response has no access token", p.ProviderName)
+	}
+	return &res.Token, nil
+}
+
+// FetchProfile implements OAuthProvider
+func (p *OAuth2Provider) FetchProfile(ctx context.Context, tok *Token, nonce string) (*Profile, error) {
+	var claims jwt.MapClaims
+	if p.OpenID {
+		var err error
+		if claims, err = p.idTokenClaims(tok.IDToken, nonce); err != nil {
+			return nil, err
+		}
+	}
+
+	profile, err := p.profile(ctx, p, tok, claims)
+	if err != nil {
+		return nil, err
+	}
+	if profile.ID == "" {
+		return nil, fmt.Errorf("%s profile has no user id", p.ProviderName)
+	}
+	// the profile must describe the user the ID token was issued for
+	if claims != nil && claims["sub"] != profile.ID {
+		return nil, fmt.Errorf("%w: subject does not match the profile", ErrInvalidIDToken)
+	}
+	return profile, nil
+}
+
+// idTokenClaims checks that the ID token was issued for this client and
+// authorization request. The token was received directly from the token
+// endpoint over TLS, which stands for its signature (OpenID Connect Core
+// 3.1.3.7).
+func (p *OAuth2Provider) idTokenClaims(idToken, nonce string) (jwt.MapClaims, error) {
+	if idToken == "" {
+		return nil, fmt.Errorf("%w: missing from the %s token response", ErrInvalidIDToken, p.ProviderName)
+	}
+	claims := jwt.MapClaims{}
+	if _, _, err := jwt.NewParser().ParseUnverified(idToken, claims); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidIDToken, err)
+	}
+
+	if issuer, _ := claims.GetIssuer(); p.Issuer != "" && issuer != p.Issuer {
+		return nil, fmt.Errorf("%w: issued by %s", ErrInvalidIDToken, issuer)
+	}
+	audience, err := claims.GetAudience()
+	if err != nil || !slices.Contains(audience, p.ClientID) {
+		return nil, fmt.Errorf("%w: issued for another client", ErrInvalidIDToken)
+	}
+	expiresAt, err := claims.GetExpirationTime()
+	if err != nil || expiresAt == nil || expiresAt.Before(time.Now()) {
+		return nil, fmt.Errorf("%w: expired", ErrInvalidIDToken)
+	}
+	if got, _ := claims["nonce"].(string); nonce == "" || got != nonce {
+		return nil, fmt.Errorf("%w: nonce mismatch", ErrInvalidIDToken)
+	}
+	return claims, nil
+}
+
+// getJSON decodes the response of an API request authenticated with the
+// access token
+func (p *OAuth2Provider) getJSON(ctx context.Context, uri string, tok *Token, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, uri, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+tok.AccessToken)
+	return p.do(req, v)
+}
+
+// do sends the request and decodes its JSON response
+func (p *OAuth2Provider) do(req *http.Request, v interface{}) error {
+	req.Header.Set("Accept", "application/json")
+	client := p.HTTPClient
+	if client == nil {
+		client = defaultHTTPClient
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(res.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	if res.StatusCode >= 300 {
+		var e oauthError
+		if json.Unmarshal(body, &e) == nil && e.Error != "" {
+			return fmt.Errorf("%s %s failed: %s %s", p.ProviderName, req.URL.Path, e.Error, e.ErrorDescription)
+		}
+		return fmt.Errorf("%s %s answered %d", p.ProviderName, req.URL.Path, res.StatusCode)
+	}
+	return json.Unmarshal(body, v)
+}
+
+// oauthError is the error response of OAuth 2.0 endpoints
+type oauthError struct {
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// flexBool decodes booleans some providers send as strings, e.g. "true"
+type flexBool bool
+
+func (b *flexBool) UnmarshalJSON(data []byte) error {
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	switch v := value.(type) {
+	case bool:
+		*b = flexBool(v)
+	case string:
+		*b = flexBool(v == "true")
+	default:
+		*b = false
+	}
+	return nil
+}
+
+// openIDClaims are the standard claims of ID tokens and userinfo responses
+type openIDClaims struct {
+	Sub               string   `json:"sub"`
+	Email             string   `json:"email"`
+	EmailVerified     flexBool `json:"email_verified"`
+	Name              string   `json:"name"`
+	PreferredUsername string   `json:"preferred_username"`
+	Nickname          string   `json:"nickname"`
+}
+
+func (c openIDClaims) profile() *Profile {
+	name := c.Name
+	for _, fallback := range []string{c.PreferredUsername, c.Nickname} {
+		if name == "" {
+			name = fallback
+		}
+	}
+	return &Profile{ID: c.Sub, Email: c.Email, EmailVerified: bool(c.EmailVerified), Name: name}
+}
+
+// userInfoProfile reads the profile from the OpenID Connect userinfo endpoint
+func userInfoProfile(ctx context.Context, p *OAuth2Provider, tok *Token, _ jwt.MapClaims) (*Profile, error) {
+	var claims openIDClaims
+	if err := p.getJSON(ctx, p.UserInfoURL, tok, &claims); err != nil {
+		return nil, err
+	}
+	return claims.profile(), nil
+}
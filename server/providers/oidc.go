@@ -0,0 +1,261 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	// discoveryTTL is how long discovery documents are cached
+	discoveryTTL = time.Hour
+	// maxCachedDiscoveries bounds the number of cached discovery documents
+	maxCachedDiscoveries = 256
+)
+
+// DefaultOIDCScopes are requested from upstream OpenID Connect providers
+// registered without scopes
+var DefaultOIDCScopes = []string{"openid", "email", "profile"}
+
+// OIDCConfig describes an upstream OpenID Connect provider
+type OIDCConfig struct {
+	Name         string
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	// Scopes always include openid
+	Scopes []string
+	Claims ClaimMapping
+}
+
+// ClaimMapping names the claims the profile is read from, the standard
+// claims are used when empty. Nested claims are separated by dots, e.g.
+// user.email.
+type ClaimMapping struct {
+	Email         string
+	EmailVerified string
+	Name          string
+}
+
+// DiscoveryDocument is the part of the OpenID Provider Metadata needed to
+// log users in
+type DiscoveryDocument struct {
+	Issuer                            string   `json:"issuer"`
+	AuthorizationEndpoint             string   `json:"authorization_endpoint"`
+	TokenEndpoint                     string   `json:"token_endpoint"`
+	UserInfoEndpoint                  string   `json:"userinfo_endpoint"`
+	TokenEndpointAuthMethodsSupported []string `json:"token_endpoint_auth_methods_supported"`
+}
+
+type cachedDiscovery struct {
+	doc       *DiscoveryDocument
+	expiresAt time.Time
+}
+
+var discoveryCache = struct {
+	sync.Mutex
+	docs map[string]cachedDiscovery
+}{docs: map[string]cachedDiscovery{}}
+
+// Discover fetches the discovery document of the issuer, see OpenID Connect
+// Discovery 1.0. Documents are cached for an hour.
+func Discover(ctx context.Context, issuer string) (*DiscoveryDocument, error) {
+	discoveryCache.Lock()
+	cached, ok := discoveryCache.docs[issuer]
+	discoveryCache.Unlock()
+	if ok && time.Now().Before(cached.expiresAt) {
+		return cached.doc, nil
+	}
+
+	uri := strings.TrimSuffix(issuer, "/") + "/.well-known/openid-configuration"
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, uri, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	res, err := defaultHTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s answered %d", uri, res.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(res.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+
+	var doc DiscoveryDocument
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return nil, fmt.Errorf("invalid discovery document: %w", err)
+	}
+	// the issuer is what ID tokens are checked against
+	if doc.Issuer != issuer {
+		return nil, fmt.Errorf("discovery document is for issuer %s", doc.Issuer)
+	}
+	if doc.AuthorizationEndpoint == "" || doc.TokenEndpoint == "" {
+		return nil, fmt.Errorf("discovery document has no authorization or token endpoint")
+	}
+	endpoints := []string{doc.AuthorizationEndpoint, doc.TokenEndpoint}
+	if doc.UserInfoEndpoint != "" {
+		endpoints = append(endpoints, doc.UserInfoEndpoint)
+	}
+	for _, endpoint := range endpoints {
+		if err := CheckEndpoint(endpoint); err != nil {
+			return nil, err
+		}
+	}
+
+	cacheDiscovery(issuer, &doc)
+	return &doc, nil
+}
+
+// ForgetDiscovery drops the cached discovery document of the issuer, so the
+// next Discover fetches it again
+func ForgetDiscovery(issuer string) {
+	discoveryCache.Lock()
+	delete(discoveryCache.docs, issuer)
+	discoveryCache.Unlock()
+}
+
+// cacheDiscovery stores the document, pruning expired documents and then
+// evicting the one closest to expiry when the cache is full
+func cacheDiscovery(issuer string, doc *DiscoveryDocument) {
+	discoveryCache.Lock()
+	defer discoveryCache.Unlock()
+	now := time.Now()
+	if _, ok := discoveryCache.docs[issuer]; !ok && len(discoveryCache.docs) >= maxCachedDiscoveries {
+		oldest := ""
+		for key, cached := range discoveryCache.docs {
+			if !now.Before(cached.expiresAt) {
+				delete(discoveryCache.docs, key)
+				continue
+			}
+			if oldest == "" || cached.expiresAt.Before(discoveryCache.docs[oldest].expiresAt) {
+				oldest = key
+			}
+		}
+		if len(discoveryCache.docs) >= maxCachedDiscoveries {
+			delete(discoveryCache.docs, oldest)
+		}
+	}
+	discoveryCache.docs[issuer] = cachedDiscovery{doc: doc, expiresAt: now.Add(discoveryTTL)}
+}
+
+// CheckEndpoint accepts absolute https URLs, and http URLs of loopback hosts
+// so that providers can be run locally
+func CheckEndpoint(endpoint string) error {
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Host == "" {
+		return fmt.Errorf("%q is not an absolute URL", endpoint)
+	}
+	switch u.Scheme {
+	case "https":
+		return nil
+	case "http":
+		if isLoopback(u.Hostname()) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%q does not use https", endpoint)
+}
+
+func isLoopback(host string) bool {
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// NewOIDC returns the provider described by the discovery document of the
+// issuer. Client credentials are sent with HTTP Basic authentication unless
+// the provider only accepts them in the request body.
+func NewOIDC(ctx context.Context, cfg OIDCConfig) (*OAuth2Provider, error) {
+	doc, err := Discover(ctx, cfg.Issuer)
+	if err != nil {
+		return nil, err
+	}
+	scopes := cfg.Scopes
+	if len(scopes) == 0 {
+		scopes = DefaultOIDCScopes
+	}
+	if !slices.Contains(scopes, "openid") {
+		scopes = append([]string{"openid"}, scopes...)
+	}
+	methods := doc.TokenEndpointAuthMethodsSupported
+
+	return &OAuth2Provider{
+		ProviderName: cfg.Name,
+		ClientID:     cfg.ClientID,
+		ClientSecret: cfg.ClientSecret,
+		AuthURL:      doc.AuthorizationEndpoint,
+		TokenURL:     doc.TokenEndpoint,
+		UserInfoURL:  doc.UserInfoEndpoint,
+		Scopes:       scopes,
+		BasicAuth:    len(methods) == 0 || slices.Contains(methods, "client_secret_basic"),
+		OpenID:       true,
+		Issuer:       doc.Issuer,
+		profile:      mappedProfile(cfg.Claims),
+	}, nil
+}
+
+// mappedProfile reads the profile from the claims of the ID token and of the
+// userinfo endpoint, when the provider has one
+func mappedProfile(mapping ClaimMapping) profileFunc {
+	return func(ctx context.Context, p *OAuth2Provider, tok *Token, idToken jwt.MapClaims) (*Profile, error) {
+		claims := map[string]interface{}{}
+		for key, value := range idToken {
+			claims[key] = value
+		}
+		if p.UserInfoURL != "" {
+			var userInfo map[string]interface{}
+			if err := p.getJSON(ctx, p.UserInfoURL, tok, &userInfo); err != nil {
+				return nil, err
+			}
+			for key, value := range userInfo {
+				claims[key] = value
+			}
+		}
+
+		profile := &Profile{}
+		profile.ID, _ = claims["sub"].(string)
+		profile.Email, _ = lookupClaim(claims, mapping.Email, "email").(string)
+		profile.Name, _ = lookupClaim(claims, mapping.Name, "name").(string)
+		switch verified := lookupClaim(claims, mapping.EmailVerified, "email_verified").(type) {
+		case bool:
+			profile.EmailVerified = verified
+		case string:
+			profile.EmailVerified = verified == "true"
+		}
+		return profile, nil
+	}
+}
+
+// lookupClaim returns the claim at the dot separated path, or the standard
+// claim when no path is set
+func lookupClaim(claims map[string]interface{}, path, standard string) interface{} {
+	if path == "" {
+		path = standard
+	}
+	var value interface{} = claims
+	for _, key := range strings.Split(path, ".") {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		value = object[key]
+	}
+	return value
+}
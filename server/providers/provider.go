@@ -0,0 +1,89 @@
+package providers
+
+import (
+	"context"
+	"slices"
+
+	"server/config"
+	"server/constants"
+)
+
+// OAuthProvider is an external identity provider users can log in with
+// through the OAuth 2.0 authorization code flow
+type OAuthProvider interface {
+	// Name identifies the provider in the /oauth_login and /oauth_callback routes
+	Name() string
+	// AuthCodeURL is the provider page the user is sent to. The state and
+	// nonce are checked when the user comes back and codeChallenge is the
+	// S256 PKCE challenge of the code verifier given to Exchange.
+	AuthCodeURL(redirectURI, state, nonce, codeChallenge string) string
+	// Exchange redeems the authorization code for the tokens of the user
+	Exchange(ctx context.Context, redirectURI, code, codeVerifier string) (*Token, error)
+	// FetchProfile returns the normalised profile of the user the tokens were
+	// issued for. OpenID Connect providers must return an ID token carrying
+	// the nonce of the authorization request.
+	FetchProfile(ctx context.Context, tok *Token, nonce string) (*Profile, error)
+}
+
+// Token is the token response of a provider
+type Token struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	IDToken     string `json:"id_token"`
+}
+
+// Profile is a user as described by a provider
+type Profile struct {
+	// ID is the stable identifier of the user at the provider
+	ID    string
+	Email string
+	// EmailVerified reports whether the provider asserts the user owns Email
+	EmailVerified bool
+	Name          string
+}
+
+// builtInNames are the providers enabled through the configuration
+var builtInNames = []string{
+	constants.OAuthProviderGoogle,
+	constants.OAuthProviderGithub,
+	constants.OAuthProviderFacebook,
+	constants.OAuthProviderLinkedIn,
+	constants.OAuthProviderApple,
+	constants.OAuthProviderDiscord,
+	constants.OAuthProviderTwitter,
+	constants.OAuthProviderMicrosoft,
+	constants.OAuthProviderTwitch,
+	constants.OAuthProviderRoblox,
+}
+
+// IsBuiltIn reports whether name is reserved for a provider enabled through
+// the configuration
+func IsBuiltIn(name string) bool {
+	return slices.Contains(builtInNames, name)
+}
+
+// FromConfig returns the providers enabled in the configuration, keyed by name
+func FromConfig(cfg *config.Config) map[string]OAuthProvider {
+	constructors := map[string]func(config.OAuthProviderCredentials) *OAuth2Provider{
+		constants.OAuthProviderGoogle:   NewGoogle,
+		constants.OAuthProviderGithub:   NewGithub,
+		constants.OAuthProviderFacebook: NewFacebook,
+		constants.OAuthProviderLinkedIn: NewLinkedIn,
+		constants.OAuthProviderApple:    NewApple,
+		constants.OAuthProviderDiscord:  NewDiscord,
+		constants.OAuthProviderTwitter:  NewTwitter,
+		constants.OAuthProviderMicrosoft: func(creds config.OAuthProviderCredentials) *OAuth2Provider {
+			return NewMicrosoft(creds, cfg.MicrosoftTenantID)
+		},
+		constants.OAuthProviderTwitch: NewTwitch,
+		constants.OAuthProviderRoblox: NewRoblox,
+	}
+
+	res := map[string]OAuthProvider{}
+	for name, creds := range cfg.OAuthProviders {
+		if newProvider, ok := constructors[name]; ok {
+			res[name] = newProvider(creds)
+		}
+	}
+	return res
+}
@@ -0,0 +1,22 @@
+package providers
+
+import (
+	"server/config"
+	"server/constants"
+)
+
+// NewRoblox returns the Roblox provider, see
+// https://create.roblox.com/docs/cloud/reference/oauth2
+func NewRoblox(creds config.OAuthProviderCredentials) *OAuth2Provider {
+	return &OAuth2Provider{
+		ProviderName: constants.OAuthProviderRoblox,
+		ClientID:     creds.ClientID,
+		ClientSecret: creds.ClientSecret,
+		AuthURL:      "https://apis.roblox.com/oauth/v1/authorize",
+		TokenURL:     "https://apis.roblox.com/oauth/v1/token",
+		UserInfoURL:  "https://apis.roblox.com/oauth/v1/userinfo",
+		Scopes:       []string{"openid", "profile", "email"},
+		OpenID:       true,
+		profile:      userInfoProfile,
+	}
+}
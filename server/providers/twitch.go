@@ -0,0 +1,26 @@
+package providers
+
+import (
+	"net/url"
+
+	"server/config"
+	"server/constants"
+)
+
+// NewTwitch returns the Twitch provider, see
+// https://dev.twitch.tv/docs/authentication/getting-tokens-oidc
+func NewTwitch(creds config.OAuthProviderCredentials) *OAuth2Provider {
+	return &OAuth2Provider{
+		ProviderName: constants.OAuthProviderTwitch,
+		ClientID:     creds.ClientID,
+		ClientSecret: creds.ClientSecret,
+		AuthURL:      "https://id.twitch.tv/oauth2/authorize",
+		TokenURL:     "https://id.twitch.tv/oauth2/token",
+		UserInfoURL:  "https://id.twitch.tv/oauth2/userinfo",
+		Scopes:       []string{"openid", "user:read:email"},
+		// the email claims are only returned when requested
+		AuthParams: url.Values{"claims": {`{"userinfo":{"email":null,"email_verified":null,"preferred_username":null}}`}},
+		OpenID:     true,
+		profile:    userInfoProfile,
+	}
+}
@@ -0,0 +1,47 @@
+package providers
+
+import (
+	"context"
+	"net/url"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"server/config"
+	"server/constants"
+)
+
+// NewTwitter returns the X (Twitter) provider, see
+// https://docs.x.com/resources/fundamentals/authentication/oauth-2-0/authorization-code
+func NewTwitter(creds config.OAuthProviderCredentials) *OAuth2Provider {
+	return &OAuth2Provider{
+		ProviderName: constants.OAuthProviderTwitter,
+		ClientID:     creds.ClientID,
+		ClientSecret: creds.ClientSecret,
+		AuthURL:      "https://x.com/i/oauth2/authorize",
+		TokenURL:     "https://api.x.com/2/oauth2/token",
+		UserInfoURL:  "https://api.x.com/2/users/me?" + url.Values{"user.fields": {"confirmed_email"}}.Encode(),
+		Scopes:       []string{"tweet.read", "users.read", "users.email"},
+		BasicAuth:    true,
+		profile:      twitterProfile,
+	}
+}
+
+func twitterProfile(ctx context.Context, p *OAuth2Provider, tok *Token, _ jwt.MapClaims) (*Profile, error) {
+	var res struct {
+		Data struct {
+			ID             string `json:"id"`
+			Name           string `json:"name"`
+			Username       string `json:"username"`
+			ConfirmedEmail string `json:"confirmed_email"`
+		} `json:"data"`
+	}
+	if err := p.getJSON(ctx, p.UserInfoURL, tok, &res); err != nil {
+		return nil, err
+	}
+	user := res.Data
+	name := user.Name
+	if name == "" {
+		name = user.Username
+	}
+	return &Profile{ID: user.ID, Email: user.ConfirmedEmail, EmailVerified: user.ConfirmedEmail != "", Name: name}, nil
+}
@@ -6,32 +6,36 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/sirupsen/logrus"
 
-	"server/config"
-	"server/database"
 	"server/handlers"
 	"server/middlewares"
 )
 
-// InitRouter initializes gin router
-func InitRouter(log *logrus.Logger) *gin.Engine {
+// InitRouter initializes gin router. The dependencies held by the resolver
+// are owned by the caller and must stay open for as long as the router
+// serves requests.
+func InitRouter(log *logrus.Logger, resolver *graph.Resolver) *gin.Engine {
 	gin.SetMode(gin.ReleaseMode)
 	router := gin.New()
 
-	cfg := config.LoadConfig()
-	db := database.NewDatabase(cfg)
-	defer db.Close()
-
-	// Initialize GraphQL resolver
-	resolver := graph.NewResolver(db)
-
 	router.Use(middlewares.Logger(log), gin.Recovery())
-	// router.Use(middlewares.GinContextToContextMiddleware())
+	router.Use(middlewares.GinContextToContextMiddleware())
+	router.Use(middlewares.Authentication(resolver.Config, resolver.Tokens, resolver.Sessions, resolver.DB))
 	router.Use(middlewares.CORSMiddleware())
 
 	router.GET("/", handlers.RootHandler())
 	router.GET("/health", handlers.HealthHandler())
-	router.POST("/query", handlers.GraphQLHandler(resolver))
+	router.POST("/query", middlewares.FirstParty(), handlers.GraphQLHandler(resolver))
 	router.GET("/playground", handlers.PlaygroundHandler())
+	router.GET("/verify_email", handlers.VerifyEmailHandler(resolver))
+	router.GET("/oauth_login/:provider", handlers.OAuthLoginHandler(resolver))
+	router.GET("/oauth_callback/:provider", handlers.OAuthCallbackHandler(resolver))
+	router.POST("/oauth_callback/:provider", handlers.OAuthCallbackHandler(resolver))
+	router.GET("/authorize", handlers.AuthorizeHandler(resolver))
+	router.POST("/oauth/token", handlers.TokenHandler(resolver))
+	router.GET("/userinfo", handlers.UserInfoHandler(resolver))
+	router.POST("/userinfo", handlers.UserInfoHandler(resolver))
+	router.GET("/.well-known/openid-configuration", handlers.OpenIDConfigurationHandler(resolver))
+	router.GET("/.well-known/jwks.json", handlers.JWKSHandler(resolver))
 
 	return router
 }
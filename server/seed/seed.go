@@ -0,0 +1,212 @@
+package seed
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"server/config"
+	"server/crypto"
+	"server/database"
+	"server/database/models"
+	"server/refs"
+	"server/validators"
+)
+
+// Fixtures is the content of a seed file
+type Fixtures struct {
+	Users        []UserFixture        `json:"users" yaml:"users"`
+	OAuthClients []OAuthClientFixture `json:"oauth_clients" yaml:"oauth_clients"`
+}
+
+// UserFixture describes a user to create or update, matched by email
+type UserFixture struct {
+	// ID is optional, it lets tests reference seeded users by a stable id
+	ID    string `json:"id" yaml:"id"`
+	Name  string `json:"name" yaml:"name"`
+	Email string `json:"email" yaml:"email"`
+	// Roles default to DEFAULT_ROLES when omitted
+	Roles []string `json:"roles" yaml:"roles"`
+	// Password is optional, it must follow the password policy and is stored
+	// hashed. Users without one log in by magic link or external provider.
+	Password string `json:"password" yaml:"password"`
+	// EmailVerified is optional, the verification state is kept when omitted
+	EmailVerified *bool `json:"email_verified" yaml:"email_verified"`
+}
+
+// OAuthClientFixture describes an OAuth client to create or update, matched by client_id
+type OAuthClientFixture struct {
+	ClientID string `json:"client_id" yaml:"client_id"`
+	// ClientSecret is optional, clients without secret are public clients
+	ClientSecret string   `json:"client_secret" yaml:"client_secret"`
+	Name         string   `json:"name" yaml:"name"`
+	RedirectURIs []string `json:"redirect_uris" yaml:"redirect_uris"`
+}
+
+// Result counts what a seed run changed
+type Result struct {
+	UsersCreated        int
+	UsersUpdated        int
+	OAuthClientsCreated int
+	OAuthClientsUpdated int
+}
+
+// Load reads fixtures from a .json, .yaml or .yml file
+func Load(path string) (*Fixtures, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var fixtures Fixtures
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		err = json.Unmarshal(data, &fixtures)
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &fixtures)
+	default:
+		return nil, fmt.Errorf("unsupported fixture format %q, expected .json, .yaml or .yml", filepath.Ext(path))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return &fixtures, nil
+}
+
+// Run upserts the fixtures through the repository layer. Roles and passwords
+// are checked against the configuration like at sign up. Running it several
+// times with the same fixtures leaves the database unchanged.
+func Run(ctx context.Context, cfg *config.Config, repo database.Repository, fixtures *Fixtures) (*Result, error) {
+	res := &Result{}
+
+	for _, f := range fixtures.Users {
+		created, updated, err := seedUser(ctx, cfg, repo, f)
+		if err != nil {
+			return res, fmt.Errorf("user %s: %w", f.Email, err)
+		}
+		if created {
+			res.UsersCreated++
+		} else if updated {
+			res.UsersUpdated++
+		}
+	}
+
+	for _, f := range fixtures.OAuthClients {
+		created, updated, err := seedOAuthClient(ctx, repo, f)
+		if err != nil {
+			return res, fmt.Errorf("oauth client %s: %w", f.ClientID, err)
+		}
+		if created {
+			res.OAuthClientsCreated++
+		} else if updated {
+			res.OAuthClientsUpdated++
+		}
+	}
+
+	return res, nil
+}
+
+func seedUser(ctx context.Context, cfg *config.Config, repo database.Repository, f UserFixture) (created, updated bool, err error) {
+	email := validators.NormalizeEmail(f.Email)
+	if !validators.IsValidEmail(email) {
+		return false, false, errors.New("invalid email address")
+	}
+	name := strings.TrimSpace(f.Name)
+	roles := f.Roles
+	if len(roles) == 0 {
+		roles = cfg.DefaultRoles
+	}
+	if role := validators.UnknownRole(cfg, roles); role != "" {
+		return false, false, fmt.Errorf("invalid role: %s", role)
+	}
+	if f.Password != "" {
+		if violations := validators.NewPasswordPolicy(cfg).Validate(f.Password, email, name); len(violations) > 0 {
+			return false, false, fmt.Errorf("invalid password: %s", violations[0].Message)
+		}
+	}
+
+	user, err := repo.GetUserByEmail(ctx, email)
+	if errors.Is(err, models.ErrNotFound) {
+		user = &models.User{ID: f.ID, Name: name, Email: email}
+		user.SetRoles(roles)
+		if err := applyUserFixture(user, f); err != nil {
+			return false, false, err
+		}
+		_, err = repo.CreateUser(ctx, user)
+		return err == nil, false, err
+	}
+	if err != nil {
+		return false, false, err
+	}
+
+	want := *user
+	want.Name = name
+	want.SetRoles(roles)
+	if err := applyUserFixture(&want, f); err != nil {
+		return false, false, err
+	}
+	if want.Name == user.Name && want.Roles == user.Roles && want.Password == user.Password && want.IsEmailVerified() == user.IsEmailVerified() {
+		return false, false, nil
+	}
+	_, err = repo.UpdateUser(ctx, &want)
+	return false, err == nil, err
+}
+
+// applyUserFixture sets the optional password and verification state of the
+// fixture, leaving them unchanged when they already match
+func applyUserFixture(user *models.User, f UserFixture) error {
+	if f.Password != "" && !crypto.VerifyPassword(user.Password, f.Password) {
+		hash, err := crypto.HashPassword(f.Password)
+		if err != nil {
+			return err
+		}
+		user.Password = hash
+	}
+	if f.EmailVerified != nil && *f.EmailVerified != user.IsEmailVerified() {
+		user.EmailVerifiedAt = nil
+		if *f.EmailVerified {
+			user.EmailVerifiedAt = refs.NewInt64Ref(time.Now().Unix())
+		}
+	}
+	return nil
+}
+
+func seedOAuthClient(ctx context.Context, repo database.Repository, f OAuthClientFixture) (created, updated bool, err error) {
+	if f.ClientID == "" {
+		return false, false, errors.New("client_id is required")
+	}
+
+	secret := ""
+	if f.ClientSecret != "" {
+		secret = crypto.HashToken(f.ClientSecret)
+	}
+
+	client, err := repo.GetOAuthClientByClientID(ctx, f.ClientID)
+	if errors.Is(err, models.ErrNotFound) {
+		client = &models.OAuthClient{ClientID: f.ClientID, ClientSecret: secret, Name: f.Name}
+		client.SetRedirectURIs(f.RedirectURIs)
+		_, err = repo.CreateOAuthClient(ctx, client)
+		return err == nil, false, err
+	}
+	if err != nil {
+		return false, false, err
+	}
+
+	want := *client
+	want.ClientSecret = secret
+	want.Name = f.Name
+	want.SetRedirectURIs(f.RedirectURIs)
+	if want.ClientSecret == client.ClientSecret && want.Name == client.Name && slices.Equal(want.RedirectURIList(), client.RedirectURIList()) {
+		return false, false, nil
+	}
+	_, err = repo.UpdateOAuthClient(ctx, &want)
+	return false, err == nil, err
+}
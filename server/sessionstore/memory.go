@@ -0,0 +1,268 @@
+package sessionstore
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// janitorInterval is how often the memory store drops the expired sessions
+// and states that nobody read again
+const janitorInterval = time.Minute
+
+type stateEntry struct {
+	value     string
+	expiresAt time.Time
+}
+
+// MemoryStore keeps everything in process memory. It suits tests and single
+// node deployments, sessions are lost on restart.
+type MemoryStore struct {
+	mu       sync.Mutex
+	sessions map[string]*Session
+	// userSessions indexes session ids by user id
+	userSessions map[string]map[string]struct{}
+	states       map[string]stateEntry
+
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+var _ SessionStore = (*MemoryStore)(nil)
+
+// NewMemoryStore returns an empty in-memory store. It starts a goroutine
+// dropping expired entries, which Close stops.
+func NewMemoryStore() *MemoryStore {
+	m := &MemoryStore{
+		sessions:     map[string]*Session{},
+		userSessions: map[string]map[string]struct{}{},
+		states:       map[string]stateEntry{},
+		done:         make(chan struct{}),
+	}
+	go m.janitor(janitorInterval)
+	return m
+}
+
+// SetSession creates or replaces a session
+func (m *MemoryStore) SetSession(_ context.Context, session *Session) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.setSession(session)
+	return nil
+}
+
+// RotateSession replaces the session while its refresh token is previousHash
+func (m *MemoryStore) RotateSession(_ context.Context, session *Session, previousHash string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, ok := m.session(session.ID)
+	if !ok {
+		return ErrNotFound
+	}
+	if s.RefreshTokenHash != previousHash {
+		return ErrConflict
+	}
+	m.setSession(session)
+	return nil
+}
+
+// GetSession returns a copy of the session
+func (m *MemoryStore) GetSession(_ context.Context, id string) (*Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, ok := m.session(id)
+	if !ok {
+		return nil, ErrNotFound
+	}
+	res := *s
+	return &res, nil
+}
+
+// DeleteSession removes a session
+func (m *MemoryStore) DeleteSession(_ context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.deleteSession(id)
+	return nil
+}
+
+// ListUserSessions returns copies of the live sessions of the user
+func (m *MemoryStore) ListUserSessions(_ context.Context, userID string) ([]*Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	res := []*Session{}
+	for id := range m.userSessions[userID] {
+		if s, ok := m.session(id); ok {
+			c := *s
+			res = append(res, &c)
+		}
+	}
+	return res, nil
+}
+
+// DeleteUserSessions removes every session of the user
+func (m *MemoryStore) DeleteUserSessions(_ context.Context, userID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for id := range m.userSessions[userID] {
+		delete(m.sessions, id)
+	}
+	delete(m.userSessions, userID)
+	return nil
+}
+
+// SetState stores a value under key for ttl
+func (m *MemoryStore) SetState(_ context.Context, key, value string, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.states[key] = stateEntry{value: value, expiresAt: time.Now().Add(ttl)}
+	return nil
+}
+
+// GetState returns the value stored under key
+func (m *MemoryStore) GetState(_ context.Context, key string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	e, ok := m.states[key]
+	if !ok {
+		return "", ErrNotFound
+	}
+	if time.Now().After(e.expiresAt) {
+		delete(m.states, key)
+		return "", ErrNotFound
+	}
+	return e.value, nil
+}
+
+// TakeState returns and removes the value stored under key
+func (m *MemoryStore) TakeState(_ context.Context, key string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	e, ok := m.states[key]
+	if !ok {
+		return "", ErrNotFound
+	}
+	delete(m.states, key)
+	if time.Now().After(e.expiresAt) {
+		return "", ErrNotFound
+	}
+	return e.value, nil
+}
+
+// IncrState increments the counter stored under key
+func (m *MemoryStore) IncrState(_ context.Context, key string, ttl time.Duration) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	e, ok := m.states[key]
+	if !ok || time.Now().After(e.expiresAt) {
+		e = stateEntry{value: "0", expiresAt: time.Now().Add(ttl)}
+	}
+	n, err := strconv.ParseInt(e.value, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	n++
+	e.value = strconv.FormatInt(n, 10)
+	m.states[key] = e
+	return n, nil
+}
+
+// DeleteState removes the value stored under key
+func (m *MemoryStore) DeleteState(_ context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.states, key)
+	return nil
+}
+
+// Close stops dropping expired entries
+func (m *MemoryStore) Close() error {
+	m.closeOnce.Do(func() { close(m.done) })
+	return nil
+}
+
+// DeleteExpired drops the expired sessions and states. It runs every
+// janitorInterval, since expired entries are otherwise only dropped when read.
+func (m *MemoryStore) DeleteExpired() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	for id, s := range m.sessions {
+		if s.ttl() <= 0 {
+			m.deleteSession(id)
+		}
+	}
+	for key, e := range m.states {
+		if now.After(e.expiresAt) {
+			delete(m.states, key)
+		}
+	}
+}
+
+func (m *MemoryStore) janitor(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			m.DeleteExpired()
+		case <-m.done:
+			return
+		}
+	}
+}
+
+// session returns a live session, dropping it when expired. The caller must
+// hold the lock.
+func (m *MemoryStore) session(id string) (*Session, bool) {
+	s, ok := m.sessions[id]
+	if !ok {
+		return nil, false
+	}
+	if s.ttl() <= 0 {
+		m.deleteSession(id)
+		return nil, false
+	}
+	return s, true
+}
+
+// setSession stores a copy of the session and indexes it. The caller must
+// hold the lock.
+func (m *MemoryStore) setSession(session *Session) {
+	s := *session
+	m.sessions[s.ID] = &s
+	if m.userSessions[s.UserID] == nil {
+		m.userSessions[s.UserID] = map[string]struct{}{}
+	}
+	m.userSessions[s.UserID][s.ID] = struct{}{}
+}
+
+// deleteSession removes a session and its index entry. The caller must hold
+// the lock.
+func (m *MemoryStore) deleteSession(id string) {
+	s, ok := m.sessions[id]
+	if !ok {
+		return
+	}
+	delete(m.sessions, id)
+	if ids := m.userSessions[s.UserID]; ids != nil {
+		delete(ids, id)
+		if len(ids) == 0 {
+			delete(m.userSessions, s.UserID)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package sessionstore
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"server/config"
+)
+
+var (
+	// ErrNotFound is returned when a session or state does not exist or expired
+	ErrNotFound = errors.New("not found")
+	// ErrConflict is returned by RotateSession when the refresh token of the
+	// session changed since it was read
+	ErrConflict = errors.New("session was rotated concurrently")
+)
+
+// Session is a login of a user, kept until it expires or is revoked
+type Session struct {
+	ID     string   `json:"id"`
+	UserID string   `json:"user_id"`
+	Roles  []string `json:"roles,omitempty"`
+	Scope  []string `json:"scope,omitempty"`
+	// RefreshTokenHash is the hash of the only refresh token of the family
+	// that can still be used, see crypto.HashToken
+	RefreshTokenHash string `json:"refresh_token_hash"`
+	// ClientID is the OAuth client the session was issued to, empty for
+	// sessions opened by logging in directly
+	ClientID string `json:"client_id,omitempty"`
+	// CreatedAt and ExpiresAt are unix times
+	CreatedAt int64 `json:"created_at"`
+	ExpiresAt int64 `json:"expires_at"`
+	// AuthTime is the unix time the user authenticated and AMR the methods
+	// they used, reported in ID tokens. Sessions opened by an OAuth client
+	// inherit them from the browser session.
+	AuthTime int64    `json:"auth_time,omitempty"`
+	AMR      []string `json:"amr,omitempty"`
+}
+
+// AuthenticatedAt returns the unix time the user authenticated
+func (s *Session) AuthenticatedAt() int64 {
+	if s.AuthTime == 0 {
+		return s.CreatedAt
+	}
+	return s.AuthTime
+}
+
+// GrantedRoles returns the roles granted to the session that are still among
+// held, the current roles of the user. Sessions granted no specific roles get
+// all of them.
+func (s *Session) GrantedRoles(held []string) []string {
+	if s.Roles == nil {
+		return held
+	}
+	return slices.DeleteFunc(slices.Clone(s.Roles), func(role string) bool {
+		return !slices.Contains(held, role)
+	})
+}
+
+// ttl returns the remaining lifetime of the session
+func (s *Session) ttl() time.Duration {
+	return time.Until(time.Unix(s.ExpiresAt, 0))
+}
+
+// SessionStore keeps the sessions of the users and short lived state such as
+// pending MFA logins or OTPs
+type SessionStore interface {
+	// SetSession creates or replaces a session, it is dropped at ExpiresAt
+	SetSession(ctx context.Context, session *Session) error
+	// GetSession returns ErrNotFound for unknown or expired sessions
+	GetSession(ctx context.Context, id string) (*Session, error)
+	// RotateSession replaces the session only while its RefreshTokenHash is
+	// still previousHash, ErrConflict is returned otherwise. It lets a
+	// refresh token be exchanged once even by concurrent requests.
+	RotateSession(ctx context.Context, session *Session, previousHash string) error
+	// DeleteSession is a no-op for unknown sessions
+	DeleteSession(ctx context.Context, id string) error
+	// ListUserSessions returns the live sessions of the user
+	ListUserSessions(ctx context.Context, userID string) ([]*Session, error)
+	// DeleteUserSessions revokes every session of the user
+	DeleteUserSessions(ctx context.Context, userID string) error
+
+	// SetState stores a value under key for ttl
+	SetState(ctx context.Context, key, value string, ttl time.Duration) error
+	// GetState returns ErrNotFound for unknown or expired keys
+	GetState(ctx context.Context, key string) (string, error)
+	// TakeState returns and removes the value stored under key in one step,
+	// so single use values are only handed out once. It returns ErrNotFound
+	// like GetState.
+	TakeState(ctx context.Context, key string) (string, error)
+	// IncrState atomically increments the counter stored under key and
+	// returns its new value. The counter expires ttl after it was created,
+	// later increments do not extend it.
+	IncrState(ctx context.Context, key string, ttl time.Duration) (int64, error)
+	DeleteState(ctx context.Context, key string) error
+
+	Close() error
+}
+
+// New returns the Redis store when REDIS_URL is set and
+// DISABLE_REDIS_FOR_ENV is not, the in-memory store otherwise
+func New(cfg *config.Config) (SessionStore, error) {
+	if cfg.RedisURL == "" || cfg.DisableRedisForEnv {
+		log.Info("Using in-memory session store, sessions are lost on restart and not shared between instances")
+		return NewMemoryStore(), nil
+	}
+	return NewRedisStore(cfg.RedisURL)
+}
@@ -0,0 +1,226 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"server/auth"
+	"server/cookie"
+	"server/database/models"
+)
+
+const testAdminSecret = "admin-secret"
+
+const (
+	adminLoginMutation      = `mutation($secret: String!) { adminLogin(secret: $secret) { message } }`
+	adminLogoutMutation     = `mutation { adminLogout { message } }`
+	adminUsersQuery         = `query { _users { id email } }`
+	adminUserQuery          = `query($id: ID!) { _user(id: $id) { id name email emailVerified roles } }`
+	adminUpdateUserMutation = `mutation($params: UpdateUserInput!) { _updateUser(params: $params) { id name email emailVerified roles } }`
+	adminDeleteUserMutation = `mutation($id: ID!) { _deleteUser(id: $id) { message } }`
+)
+
+// withAdminSecret authenticates the request with the admin secret header
+func withAdminSecret(secret string) func(*http.Request) {
+	return func(req *http.Request) {
+		req.Header.Set(auth.AdminSecretHeader, secret)
+	}
+}
+
+func adminCookie(w *httptest.ResponseRecorder) *http.Cookie {
+	for _, c := range w.Result().Cookies() {
+		if c.Name == cookie.AdminName {
+			return c
+		}
+	}
+	return nil
+}
+
+func TestAdminSecretHeader(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.AdminSecret = testAdminSecret
+	resolver := setupResolver(t, cfg)
+	r := newGraphQLRouter(resolver)
+	admin := createAdmin(t, resolver, r, "admin@example.com")
+
+	for name, prepare := range map[string]func(*http.Request){
+		"anonymous":    nil,
+		"wrong secret": withAdminSecret("wrong"),
+		"admin role":   withBearer(admin.AccessToken),
+	} {
+		res, _ := doGraphQLRequest(t, r, adminUsersQuery, nil, prepare)
+		if len(res.Errors) != 1 || res.Errors[0].Extensions["code"] != "UNAUTHENTICATED" {
+			t.Errorf("%s: expected UNAUTHENTICATED, got %+v", name, res.Errors)
+		}
+	}
+
+	res, _ := doGraphQLRequest(t, r, adminUsersQuery, nil, withAdminSecret(testAdminSecret))
+	var data struct {
+		Users []struct{ ID, Email string } `json:"_users"`
+	}
+	_ = json.Unmarshal(res.Data, &data)
+	if len(res.Errors) > 0 || len(data.Users) != 1 || data.Users[0].Email != "admin@example.com" {
+		t.Fatalf("_users returned %s %+v", res.Data, res.Errors)
+	}
+
+	// the admin secret also satisfies @isAdmin
+	res, _ = doGraphQLRequest(t, r, revokeUserSessionsMutation, map[string]interface{}{"userId": data.Users[0].ID}, withAdminSecret(testAdminSecret))
+	if len(res.Errors) > 0 {
+		t.Fatalf("revokeUserSessions with the admin secret failed: %+v", res.Errors)
+	}
+	res, _ = doGraphQLRequest(t, r, sessionQuery, nil, withBearer(admin.AccessToken))
+	if len(res.Errors) != 1 {
+		t.Error("the sessions of the user must be revoked")
+	}
+}
+
+func TestAdminLogin(t *testing.T) {
+	cfg := testConfig(t)
+	r := newGraphQLRouter(setupResolver(t, cfg))
+
+	res := doGraphQL(t, r, adminLoginMutation, map[string]interface{}{"secret": ""})
+	if len(res.Errors) != 1 || res.Errors[0].Extensions["code"] != "FORBIDDEN" {
+		t.Fatalf("expected FORBIDDEN without ADMIN_SECRET, got %+v", res.Errors)
+	}
+
+	cfg.AdminSecret = testAdminSecret
+	cfg.AdminCookieSecure = false
+	res, w := doGraphQLRequest(t, r, adminLoginMutation, map[string]interface{}{"secret": "wrong"}, nil)
+	if len(res.Errors) != 1 || res.Errors[0].Extensions["code"] != "UNAUTHENTICATED" || adminCookie(w) != nil {
+		t.Fatalf("expected UNAUTHENTICATED for a wrong secret, got %+v", res.Errors)
+	}
+
+	res, w = doGraphQLRequest(t, r, adminLoginMutation, map[string]interface{}{"secret": testAdminSecret}, nil)
+	if len(res.Errors) > 0 {
+		t.Fatalf("adminLogin failed: %+v", res.Errors)
+	}
+	c := adminCookie(w)
+	if c == nil || !c.HttpOnly || c.Secure || c.Value == "" || c.Value == testAdminSecret || c.MaxAge != int((24*time.Hour)/time.Second) {
+		t.Fatalf("expected an HttpOnly admin cookie honouring ADMIN_COOKIE_SECURE, got %+v", c)
+	}
+
+	res, _ = doGraphQLRequest(t, r, adminUsersQuery, nil, withCookie(c))
+	if len(res.Errors) > 0 {
+		t.Fatalf("_users with the admin cookie failed: %+v", res.Errors)
+	}
+
+	res, w = doGraphQLRequest(t, r, adminLogoutMutation, nil, withCookie(c))
+	if len(res.Errors) > 0 {
+		t.Fatalf("adminLogout failed: %+v", res.Errors)
+	}
+	if deleted := adminCookie(w); deleted == nil || deleted.MaxAge >= 0 {
+		t.Errorf("expected the admin cookie to be expired, got %+v", deleted)
+	}
+	res, _ = doGraphQLRequest(t, r, adminUsersQuery, nil, withCookie(c))
+	if len(res.Errors) != 1 || res.Errors[0].Extensions["code"] != "UNAUTHENTICATED" {
+		t.Errorf("the admin cookie must be revoked by adminLogout, got %+v", res.Errors)
+	}
+}
+
+func TestAdminUpdateUser(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.AdminSecret = testAdminSecret
+	resolver := setupResolver(t, cfg)
+	r := newGraphQLRouter(resolver)
+	ctx := context.Background()
+
+	doGraphQL(t, r, signupMutation, signupInput("jane@example.com", "Secret#123", "Secret#123"))
+	doGraphQL(t, r, signupMutation, signupInput("john@example.com", "Secret#123", "Secret#123"))
+	jane, _ := resolver.DB.GetUserByEmail(ctx, "jane@example.com")
+	if _, err := resolver.DB.CreateVerificationRequest(ctx, &models.VerificationRequest{
+		Token:      "pending",
+		Identifier: models.VerificationTypeForgotPassword,
+		Email:      jane.Email,
+		ExpiresAt:  time.Now().Add(time.Hour).Unix(),
+	}); err != nil {
+		t.Fatalf("CreateVerificationRequest failed: %v", err)
+	}
+
+	update := func(params map[string]interface{}) graphQLResponse {
+		res, _ := doGraphQLRequest(t, r, adminUpdateUserMutation, map[string]interface{}{"params": params}, withAdminSecret(testAdminSecret))
+		return res
+	}
+
+	res := update(map[string]interface{}{"id": jane.ID, "name": "Jane Doe", "email": "Jane.Doe@example.com", "roles": []string{"user", "admin"}})
+	if len(res.Errors) > 0 {
+		t.Fatalf("_updateUser failed: %+v", res.Errors)
+	}
+	var data struct {
+		UpdateUser struct {
+			Name, Email   string
+			EmailVerified bool
+			Roles         []string
+		} `json:"_updateUser"`
+	}
+	_ = json.Unmarshal(res.Data, &data)
+	if u := data.UpdateUser; u.Name != "Jane Doe" || u.Email != "jane.doe@example.com" || u.EmailVerified || len(u.Roles) != 2 {
+		t.Errorf("unexpected user %+v", u)
+	}
+	if _, err := resolver.DB.GetVerificationRequestByToken(ctx, "pending"); err == nil {
+		t.Error("tokens sent to the previous email must be discarded")
+	}
+
+	res = update(map[string]interface{}{"id": jane.ID, "emailVerified": true})
+	if len(res.Errors) > 0 {
+		t.Fatalf("_updateUser failed: %+v", res.Errors)
+	}
+	_ = json.Unmarshal(res.Data, &data)
+	if !data.UpdateUser.EmailVerified || data.UpdateUser.Name != "Jane Doe" {
+		t.Errorf("omitted fields must be kept, got %+v", data.UpdateUser)
+	}
+
+	for _, c := range []struct {
+		params map[string]interface{}
+		code   string
+	}{
+		{map[string]interface{}{"id": jane.ID, "email": "john@example.com"}, "CONFLICT"},
+		{map[string]interface{}{"id": jane.ID, "email": "invalid"}, "BAD_USER_INPUT"},
+		{map[string]interface{}{"id": jane.ID, "name": " "}, "BAD_USER_INPUT"},
+		{map[string]interface{}{"id": jane.ID, "roles": []string{"superuser"}}, "BAD_USER_INPUT"},
+		{map[string]interface{}{"id": "unknown", "name": "Nobody"}, "NOT_FOUND"},
+	} {
+		res = update(c.params)
+		if len(res.Errors) != 1 || res.Errors[0].Extensions["code"] != c.code {
+			t.Errorf("%v: expected %s, got %+v", c.params, c.code, res.Errors)
+		}
+	}
+}
+
+func TestAdminDeleteUser(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.AdminSecret = testAdminSecret
+	resolver := setupResolver(t, cfg)
+	r := newGraphQLRouter(resolver)
+
+	doGraphQL(t, r, signupMutation, signupInput("jane@example.com", "Secret#123", "Secret#123"))
+	l := login(t, r, "jane@example.com", "Secret#123")
+	jane, _ := resolver.DB.GetUserByEmail(context.Background(), "jane@example.com")
+
+	res, _ := doGraphQLRequest(t, r, adminDeleteUserMutation, map[string]interface{}{"id": jane.ID}, withBearer(l.AccessToken))
+	if len(res.Errors) != 1 || res.Errors[0].Extensions["code"] != "UNAUTHENTICATED" {
+		t.Fatalf("users must not delete accounts, got %+v", res.Errors)
+	}
+
+	res, _ = doGraphQLRequest(t, r, adminDeleteUserMutation, map[string]interface{}{"id": jane.ID}, withAdminSecret(testAdminSecret))
+	if len(res.Errors) > 0 {
+		t.Fatalf("_deleteUser failed: %+v", res.Errors)
+	}
+
+	res, _ = doGraphQLRequest(t, r, adminUserQuery, map[string]interface{}{"id": jane.ID}, withAdminSecret(testAdminSecret))
+	if len(res.Errors) > 0 || string(res.Data) != `{"_user":null}` {
+		t.Errorf("expected the user to be gone, got %s %+v", res.Data, res.Errors)
+	}
+	res, _ = doGraphQLRequest(t, r, sessionQuery, nil, withBearer(l.AccessToken))
+	if len(res.Errors) != 1 {
+		t.Error("the sessions of a deleted user must be revoked")
+	}
+
+	res, _ = doGraphQLRequest(t, r, adminDeleteUserMutation, map[string]interface{}{"id": jane.ID}, withAdminSecret(testAdminSecret))
+	if len(res.Errors) != 1 || res.Errors[0].Extensions["code"] != "NOT_FOUND" {
+		t.Errorf("expected NOT_FOUND, got %+v", res.Errors)
+	}
+}
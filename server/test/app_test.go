@@ -0,0 +1,68 @@
+package test
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"server/app"
+	"server/logs"
+)
+
+func freePort(t *testing.T) string {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to find a free port: %v", err)
+	}
+	defer l.Close()
+	return fmt.Sprint(l.Addr().(*net.TCPAddr).Port)
+}
+
+func TestAppGracefulShutdown(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.Port = freePort(t)
+	application := app.New(cfg, logs.InitLog("error"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- application.Run(ctx) }()
+
+	url := "http://127.0.0.1:" + cfg.Port + "/health"
+	var res *http.Response
+	var err error
+	for i := 0; i < 50; i++ {
+		if res, err = http.Get(url); err == nil {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatalf("server never became ready: %v", err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", res.StatusCode)
+	}
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Run returned %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not return after cancellation")
+	}
+
+	if application.DB != nil {
+		t.Error("expected the database to be closed on shutdown")
+	}
+	if _, err := http.Get(url); err == nil {
+		t.Error("expected the server to stop accepting connections")
+	}
+}
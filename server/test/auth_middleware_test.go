@@ -0,0 +1,101 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"server/auth"
+	"server/graph"
+	"server/middlewares"
+)
+
+// newPrincipalRouter serves the principal of the request as JSON on /whoami
+func newPrincipalRouter(resolver *graph.Resolver) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(middlewares.Authentication(resolver.Config, resolver.Tokens, resolver.Sessions, resolver.DB))
+	r.GET("/whoami", func(c *gin.Context) {
+		p, ok := auth.PrincipalFromContext(c.Request.Context())
+		if !ok {
+			c.JSON(http.StatusOK, nil)
+			return
+		}
+		c.JSON(http.StatusOK, p)
+	})
+	return r
+}
+
+func whoami(t *testing.T, r http.Handler, prepare func(*http.Request)) *auth.Principal {
+	t.Helper()
+
+	req := httptest.NewRequest(http.MethodGet, "/whoami", nil)
+	if prepare != nil {
+		prepare(req)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	var p *auth.Principal
+	if err := json.Unmarshal(w.Body.Bytes(), &p); err != nil {
+		t.Fatalf("invalid response %q: %v", w.Body.String(), err)
+	}
+	return p
+}
+
+func TestAuthenticationMiddleware(t *testing.T) {
+	resolver := setupResolver(t, testConfig(t))
+	gql := newGraphQLRouter(resolver)
+	r := newPrincipalRouter(resolver)
+
+	doGraphQL(t, gql, signupMutation, signupInput("jane@example.com", "Secret#123", "Secret#123"))
+	l := login(t, gql, "jane@example.com", "Secret#123")
+	user, _ := resolver.DB.GetUserByEmail(context.Background(), "jane@example.com")
+
+	p := whoami(t, r, withBearer(l.AccessToken))
+	if p == nil || p.UserID != user.ID || p.SessionID == "" || !p.HasRole("user") || p.HasRole("admin") {
+		t.Fatalf("unexpected principal %+v", p)
+	}
+	if fromCookie := whoami(t, r, withCookie(l.Cookie)); fromCookie == nil || fromCookie.SessionID != p.SessionID {
+		t.Errorf("expected the cookie to resolve to the same session, got %+v", fromCookie)
+	}
+
+	for name, prepare := range map[string]func(*http.Request){
+		"anonymous":               nil,
+		"invalid token":           withBearer("not-a-token"),
+		"refresh token as bearer": withBearer(l.RefreshToken),
+		"basic auth": func(req *http.Request) {
+			req.SetBasicAuth("jane@example.com", "Secret#123")
+		},
+	} {
+		if p := whoami(t, r, prepare); p != nil {
+			t.Errorf("%s: expected no principal, got %+v", name, p)
+		}
+	}
+
+	if err := resolver.Sessions.DeleteSession(context.Background(), p.SessionID); err != nil {
+		t.Fatalf("DeleteSession failed: %v", err)
+	}
+	if p := whoami(t, r, withBearer(l.AccessToken)); p != nil {
+		t.Errorf("expected revoked sessions to be rejected, got %+v", p)
+	}
+}
+
+func TestPrincipalScopes(t *testing.T) {
+	p := &auth.Principal{Roles: []string{"user"}, Scopes: []string{"openid", "email"}}
+	if !p.HasScope("email") || p.HasScope("profile") || !p.HasRole("user") {
+		t.Errorf("unexpected helpers result for %+v", p)
+	}
+
+	if _, ok := auth.PrincipalFromContext(context.Background()); ok {
+		t.Error("expected no principal in an empty context")
+	}
+	ctx := auth.WithPrincipal(context.Background(), p)
+	if got, ok := auth.PrincipalFromContext(ctx); !ok || got != p {
+		t.Error("expected the principal stored in the context")
+	}
+}
@@ -0,0 +1,363 @@
+package test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+
+	"server/crypto"
+	"server/database/models"
+	"server/graph"
+	"server/routes"
+	"server/token"
+)
+
+const (
+	spaRedirectURI     = "https://app.example.com/callback"
+	backendRedirectURI = "https://backend.example.com/callback"
+	codeVerifier       = "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+)
+
+// setupAuthorize returns a router with a public "spa" client, a confidential
+// "backend" client and a logged in user
+func setupAuthorize(t *testing.T, resolver *graph.Resolver) (*gin.Engine, loginResult) {
+	t.Helper()
+
+	ctx := context.Background()
+	spa := &models.OAuthClient{ClientID: "spa", Name: "SPA"}
+	spa.SetRedirectURIs([]string{spaRedirectURI})
+	backend := &models.OAuthClient{ClientID: "backend", Name: "Backend", ClientSecret: crypto.HashToken("backend-secret")}
+	backend.SetRedirectURIs([]string{backendRedirectURI, "https://backend.example.com/other"})
+	for _, client := range []*models.OAuthClient{spa, backend} {
+		if _, err := resolver.DB.CreateOAuthClient(ctx, client); err != nil {
+			t.Fatalf("CreateOAuthClient failed: %v", err)
+		}
+	}
+
+	r := routes.InitRouter(logrus.New(), resolver)
+	doGraphQL(t, r, signupMutation, signupInput("jane@example.com", "Secret#123", "Secret#123"))
+	return r, login(t, r, "jane@example.com", "Secret#123")
+}
+
+func s256(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func authorize(r http.Handler, params url.Values, c *http.Cookie) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/authorize?"+params.Encode(), nil)
+	if c != nil {
+		req.AddCookie(c)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+// redirectParams returns the query or fragment parameters of a redirect
+func redirectParams(t *testing.T, w *httptest.ResponseRecorder, fragment bool) (*url.URL, url.Values) {
+	t.Helper()
+
+	if w.Code != http.StatusFound {
+		t.Fatalf("expected a redirect, got %d: %s", w.Code, w.Body.String())
+	}
+	u, err := url.Parse(w.Header().Get("Location"))
+	if err != nil {
+		t.Fatalf("invalid Location: %v", err)
+	}
+	if fragment {
+		values, _ := url.ParseQuery(u.Fragment)
+		return u, values
+	}
+	return u, u.Query()
+}
+
+func spaCodeRequest() url.Values {
+	return url.Values{
+		"client_id":             {"spa"},
+		"response_type":         {"code"},
+		"state":                 {"xyz"},
+		"scope":                 {"email profile"},
+		"code_challenge":        {s256(codeVerifier)},
+		"code_challenge_method": {"S256"},
+	}
+}
+
+func TestAuthorizationCodeFlowWithPKCE(t *testing.T) {
+	resolver := setupResolver(t, testConfig(t))
+	r, l := setupAuthorize(t, resolver)
+
+	u, params := redirectParams(t, authorize(r, spaCodeRequest(), l.Cookie), false)
+	if u.Scheme+"://"+u.Host+u.Path != spaRedirectURI || params.Get("state") != "xyz" || params.Get("code") == "" {
+		t.Fatalf("unexpected authorization response %s", u)
+	}
+	code := params.Get("code")
+
+	w, body := postTokenRequest(r, url.Values{"grant_type": {"authorization_code"}, "code": {code}, "client_id": {"spa"}, "code_verifier": {"wrong-verifier"}})
+	if w.Code != http.StatusBadRequest || body["error"] != "invalid_grant" {
+		t.Fatalf("expected invalid_grant for a wrong verifier, got %d %v", w.Code, body)
+	}
+
+	// codes are single use, even after a failed exchange
+	w, body = postTokenRequest(r, url.Values{"grant_type": {"authorization_code"}, "code": {code}, "client_id": {"spa"}, "code_verifier": {codeVerifier}})
+	if w.Code != http.StatusBadRequest || body["error"] != "invalid_grant" {
+		t.Fatalf("expected the code to be consumed, got %d %v", w.Code, body)
+	}
+
+	_, params = redirectParams(t, authorize(r, spaCodeRequest(), l.Cookie), false)
+	code = params.Get("code")
+	w, body = postTokenRequest(r, url.Values{"grant_type": {"authorization_code"}, "code": {code}, "client_id": {"spa"}, "code_verifier": {codeVerifier}})
+	if w.Code != http.StatusOK || w.Header().Get("Cache-Control") != "no-store" {
+		t.Fatalf("code exchange failed: %d %v", w.Code, body)
+	}
+	accessToken, _ := body["access_token"].(string)
+	claims, err := resolver.Tokens.ParseToken(accessToken, token.TypeAccessToken)
+	if err != nil || claims.Subject == "" || claims.Scope != "email profile" {
+		t.Fatalf("unexpected access token claims %+v: %v", claims, err)
+	}
+	if refreshToken, _ := body["refresh_token"].(string); refreshToken == l.RefreshToken || refreshToken == "" {
+		t.Error("the client must get a session of its own")
+	}
+
+	w, body = postTokenRequest(r, url.Values{"grant_type": {"authorization_code"}, "code": {code}, "client_id": {"spa"}, "code_verifier": {codeVerifier}})
+	if w.Code != http.StatusBadRequest || body["error"] != "invalid_grant" {
+		t.Errorf("expected a used code to be rejected, got %d %v", w.Code, body)
+	}
+}
+
+func TestAuthorizeRequiresLogin(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.LoginURL = "https://app.example.com/login"
+	r, _ := setupAuthorize(t, setupResolver(t, cfg))
+
+	w := authorize(r, spaCodeRequest(), nil)
+	u, params := redirectParams(t, w, false)
+	if u.Host != "app.example.com" || u.Path != "/login" || !strings.HasPrefix(params.Get("redirect_uri"), "http://localhost:8080/authorize?") {
+		t.Errorf("expected a redirect to the login page, got %s", u)
+	}
+
+	request := spaCodeRequest()
+	request.Set("prompt", "none")
+	_, params = redirectParams(t, authorize(r, request, nil), false)
+	if params.Get("error") != "login_required" || params.Get("state") != "xyz" {
+		t.Errorf("expected login_required, got %v", params)
+	}
+}
+
+func TestAuthorizeErrors(t *testing.T) {
+	r, l := setupAuthorize(t, setupResolver(t, testConfig(t)))
+
+	for name, c := range map[string]struct{ set, value string }{
+		"unknown client":     {set: "client_id", value: "unknown"},
+		"unregistered redir": {set: "redirect_uri", value: "https://evil.example.com/callback"},
+	} {
+		request := spaCodeRequest()
+		request.Set(c.set, c.value)
+		if w := authorize(r, request, l.Cookie); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: errors must not be redirected before the redirect URI is trusted, got %d", name, w.Code)
+		}
+	}
+
+	for name, c := range map[string]struct {
+		params url.Values
+		code   string
+	}{
+		"missing challenge": {url.Values{"code_challenge": {""}}, "invalid_request"},
+		"unknown method":    {url.Values{"code_challenge_method": {"S512"}}, "invalid_request"},
+		"unknown type":      {url.Values{"response_type": {"device"}}, "unsupported_response_type"},
+		"token in query":    {url.Values{"response_type": {"token"}, "response_mode": {"query"}}, "invalid_request"},
+		"unknown mode":      {url.Values{"response_mode": {"email"}}, "invalid_request"},
+	} {
+		request := spaCodeRequest()
+		for key, values := range c.params {
+			request[key] = values
+		}
+		_, params := redirectParams(t, authorize(r, request, l.Cookie), false)
+		if params.Get("error") != c.code || params.Get("state") != "xyz" || params.Get("code") != "" {
+			t.Errorf("%s: expected %s, got %v", name, c.code, params)
+		}
+	}
+}
+
+func TestAuthorizeResponseModes(t *testing.T) {
+	resolver := setupResolver(t, testConfig(t))
+	r, l := setupAuthorize(t, resolver)
+
+	request := spaCodeRequest()
+	request.Set("response_mode", "fragment")
+	if _, params := redirectParams(t, authorize(r, request, l.Cookie), true); params.Get("code") == "" {
+		t.Errorf("expected the code in the fragment, got %v", params)
+	}
+
+	request.Set("response_mode", "form_post")
+	w := authorize(r, request, l.Cookie)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `action="`+spaRedirectURI+`"`) || !strings.Contains(w.Body.String(), `name="code"`) {
+		t.Errorf("unexpected form_post response %d %s", w.Code, w.Body.String())
+	}
+
+	request.Set("response_mode", "web_message")
+	w = authorize(r, request, l.Cookie)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "postMessage") || !strings.Contains(w.Body.String(), `"https://app.example.com"`) {
+		t.Errorf("unexpected web_message response %d %s", w.Code, w.Body.String())
+	}
+
+	// the implicit flow answers in the fragment with an access token of a
+	// session of the client
+	request = url.Values{"client_id": {"spa"}, "response_type": {"token"}, "state": {"xyz"}}
+	_, params := redirectParams(t, authorize(r, request, l.Cookie), true)
+	accessToken := params.Get("access_token")
+	if params.Get("token_type") != "Bearer" || params.Get("refresh_token") != "" || accessToken == "" {
+		t.Fatalf("unexpected implicit response %v", params)
+	}
+	claims, err := resolver.Tokens.ParseToken(accessToken, token.TypeAccessToken)
+	browser, _ := resolver.Tokens.ParseToken(l.AccessToken, token.TypeAccessToken)
+	if err != nil || claims.ClientID != "spa" || claims.SessionID == browser.SessionID {
+		t.Fatalf("expected a token of a session of the client, got %+v (%v)", claims, err)
+	}
+}
+
+func TestClientTokensCannotManageTheAccount(t *testing.T) {
+	resolver := setupResolver(t, testConfig(t))
+	r, l := setupAuthorize(t, resolver)
+
+	_, params := redirectParams(t, authorize(r, url.Values{"client_id": {"spa"}, "response_type": {"token"}}, l.Cookie), true)
+	implicit := params.Get("access_token")
+	_, params = redirectParams(t, authorize(r, spaCodeRequest(), l.Cookie), false)
+	_, body := postTokenRequest(r, url.Values{"grant_type": {"authorization_code"}, "code": {params.Get("code")}, "client_id": {"spa"}, "code_verifier": {codeVerifier}})
+	exchanged, _ := body["access_token"].(string)
+
+	for name, accessToken := range map[string]string{"implicit": implicit, "exchanged": exchanged} {
+		for _, mutation := range []string{logoutMutation, revokeAllSessionsMutation, enrollTotpMutation} {
+			res, _ := doGraphQLRequest(t, r, mutation, nil, withBearer(accessToken))
+			if len(res.Errors) != 1 || res.Errors[0].Extensions["code"] != "UNAUTHENTICATED" {
+				t.Errorf("%s: expected %q to be refused, got %s %+v", name, mutation, res.Data, res.Errors)
+			}
+		}
+	}
+	res, _ := doGraphQLRequest(t, r, sessionQuery, nil, withBearer(l.AccessToken))
+	if len(res.Errors) > 0 {
+		t.Errorf("expected the browser session to be kept, got %+v", res.Errors)
+	}
+}
+
+func TestAuthorizeDefaultResponseTypeAndMode(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.DefaultAuthorizeResponseType = "token"
+	cfg.DefaultAuthorizeResponseMode = "form_post"
+	r, l := setupAuthorize(t, setupResolver(t, cfg))
+
+	w := authorize(r, url.Values{"client_id": {"spa"}}, l.Cookie)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `name="access_token"`) {
+		t.Errorf("expected a form_post token response, got %d %s", w.Code, w.Body.String())
+	}
+}
+
+func TestAuthorizationCodeConcurrentExchange(t *testing.T) {
+	resolver := setupResolver(t, testConfig(t))
+	r, l := setupAuthorize(t, resolver)
+	_, params := redirectParams(t, authorize(r, spaCodeRequest(), l.Cookie), false)
+
+	const requests = 10
+	var wg sync.WaitGroup
+	var succeeded atomic.Int32
+	for range requests {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _, err := resolver.ExchangeAuthorizationCode(context.Background(), graph.CodeExchange{
+				Code:         params.Get("code"),
+				ClientID:     "spa",
+				CodeVerifier: codeVerifier,
+			})
+			if err == nil {
+				succeeded.Add(1)
+			} else if !errors.Is(err, graph.ErrInvalidGrant) {
+				t.Errorf("ExchangeAuthorizationCode failed: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := succeeded.Load(); n != 1 {
+		t.Errorf("expected the code to be exchanged once, got %d", n)
+	}
+}
+
+func TestAuthorizationCodeConfidentialClient(t *testing.T) {
+	r, l := setupAuthorize(t, setupResolver(t, testConfig(t)))
+
+	request := url.Values{"client_id": {"backend"}, "redirect_uri": {backendRedirectURI}, "response_type": {"code"}}
+	_, params := redirectParams(t, authorize(r, request, l.Cookie), false)
+	code := params.Get("code")
+	if code == "" {
+		t.Fatalf("confidential clients may skip PKCE, got %v", params)
+	}
+
+	exchange := func(code, secret, redirectURI string) (*httptest.ResponseRecorder, string) {
+		form := url.Values{"grant_type": {"authorization_code"}, "code": {code}, "redirect_uri": {redirectURI}}
+		req := httptest.NewRequest(http.MethodPost, "/oauth/token", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.SetBasicAuth("backend", secret)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w, w.Body.String()
+	}
+
+	if w, body := exchange(code, "wrong", backendRedirectURI); w.Code != http.StatusUnauthorized || !strings.Contains(body, "invalid_client") {
+		t.Fatalf("expected invalid_client for a wrong secret, got %d %s", w.Code, body)
+	}
+
+	_, params = redirectParams(t, authorize(r, request, l.Cookie), false)
+	if w, body := exchange(params.Get("code"), "backend-secret", "https://backend.example.com/other"); w.Code != http.StatusBadRequest || !strings.Contains(body, "invalid_grant") {
+		t.Fatalf("expected invalid_grant for another redirect_uri, got %d %s", w.Code, body)
+	}
+
+	_, params = redirectParams(t, authorize(r, request, l.Cookie), false)
+	w, body := exchange(params.Get("code"), "backend-secret", backendRedirectURI)
+	var tokens struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	_ = json.Unmarshal([]byte(body), &tokens)
+	if w.Code != http.StatusOK || tokens.RefreshToken == "" {
+		t.Fatalf("code exchange failed: %d %s", w.Code, body)
+	}
+
+	// the refresh token is bound to the client that exchanged the code
+	refresh := func(clientID, secret string) *httptest.ResponseRecorder {
+		form := url.Values{"grant_type": {"refresh_token"}, "refresh_token": {tokens.RefreshToken}}
+		req := httptest.NewRequest(http.MethodPost, "/oauth/token", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if clientID != "" {
+			req.SetBasicAuth(clientID, secret)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	if w := refresh("", ""); w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "invalid_grant") {
+		t.Errorf("expected client credentials to be required, got %d %s", w.Code, w.Body.String())
+	}
+	if w := refresh("backend", "wrong"); w.Code != http.StatusUnauthorized || !strings.Contains(w.Body.String(), "invalid_client") {
+		t.Errorf("expected invalid_client for a wrong secret, got %d %s", w.Code, w.Body.String())
+	}
+	if w := refresh("spa", ""); w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "invalid_grant") {
+		t.Errorf("expected other clients to be refused, got %d %s", w.Code, w.Body.String())
+	}
+	if _, res := refreshViaGraphQL(t, r, tokens.RefreshToken); len(res.Errors) != 1 {
+		t.Errorf("expected client tokens not to be refreshed by refreshToken, got %+v", res.Errors)
+	}
+	if w := refresh("backend", "backend-secret"); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "refresh_token") {
+		t.Errorf("refresh by the client failed: %d %s", w.Code, w.Body.String())
+	}
+}
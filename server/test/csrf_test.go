@@ -0,0 +1,58 @@
+package test
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestCookiesAreNotSentCrossSite(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.AdminSecret = testAdminSecret
+	r := newGraphQLRouter(setupResolver(t, cfg))
+	doGraphQL(t, r, signupMutation, signupInput("jane@example.com", "Secret#123", "Secret#123"))
+
+	if c := login(t, r, "jane@example.com", "Secret#123").Cookie; c == nil || c.SameSite != http.SameSiteLaxMode || !c.Secure || !c.HttpOnly {
+		t.Errorf("expected a Secure, HttpOnly and SameSite=Lax session cookie, got %+v", c)
+	}
+	_, w := doGraphQLRequest(t, r, adminLoginMutation, map[string]interface{}{"secret": testAdminSecret}, nil)
+	if c := adminCookie(w); c == nil || c.SameSite != http.SameSiteStrictMode {
+		t.Errorf("expected a SameSite=Strict admin cookie, got %+v", c)
+	}
+}
+
+func TestGraphQLRefusesSimpleRequests(t *testing.T) {
+	r := newGraphQLRouter(setupResolver(t, testConfig(t)))
+	query := `mutation { logout { message } }`
+
+	var multipartBody bytes.Buffer
+	mw := multipart.NewWriter(&multipartBody)
+	_ = mw.WriteField("operations", `{"query": "`+query+`"}`)
+	_ = mw.WriteField("map", `{}`)
+	_ = mw.Close()
+
+	// bodies a cross-site form can send without a CORS preflight
+	for name, req := range map[string]*http.Request{
+		"multipart":  httptest.NewRequest(http.MethodPost, "/query", &multipartBody),
+		"urlencoded": httptest.NewRequest(http.MethodPost, "/query", strings.NewReader(url.Values{"query": {query}}.Encode())),
+		"text":       httptest.NewRequest(http.MethodPost, "/query", strings.NewReader(`{"query": "`+query+`"}`)),
+	} {
+		switch name {
+		case "multipart":
+			req.Header.Set("Content-Type", mw.FormDataContentType())
+		case "urlencoded":
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		case "text":
+			req.Header.Set("Content-Type", "text/plain")
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code == http.StatusOK {
+			t.Errorf("%s: expected the request to be refused, got %d %s", name, w.Code, w.Body.String())
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/vektah/gqlparser/v2/gqlerror"
+
+	"server/auth"
+	"server/graph"
+)
+
+func TestDirectives(t *testing.T) {
+	next := func(ctx context.Context) (interface{}, error) { return "ok", nil }
+	anonymous := context.Background()
+	user := auth.WithPrincipal(context.Background(), &auth.Principal{UserID: "u1", Roles: []string{"user"}})
+	admin := auth.WithPrincipal(context.Background(), &auth.Principal{UserID: "u2", Roles: []string{"user", "admin"}})
+
+	cases := []struct {
+		name string
+		call func(ctx context.Context) (interface{}, error)
+		ctx  context.Context
+		code string
+	}{
+		{"isAuthenticated anonymous", func(ctx context.Context) (interface{}, error) { return graph.IsAuthenticated(ctx, nil, next) }, anonymous, "UNAUTHENTICATED"},
+		{"isAuthenticated user", func(ctx context.Context) (interface{}, error) { return graph.IsAuthenticated(ctx, nil, next) }, user, ""},
+		{"hasRole anonymous", func(ctx context.Context) (interface{}, error) {
+			return graph.HasRole(ctx, nil, next, []string{"user"})
+		}, anonymous, "UNAUTHENTICATED"},
+		{"hasRole missing", func(ctx context.Context) (interface{}, error) {
+			return graph.HasRole(ctx, nil, next, []string{"editor", "admin"})
+		}, user, "FORBIDDEN"},
+		{"hasRole any of", func(ctx context.Context) (interface{}, error) {
+			return graph.HasRole(ctx, nil, next, []string{"editor", "user"})
+		}, user, ""},
+		{"isAdmin user", func(ctx context.Context) (interface{}, error) { return graph.IsAdmin(ctx, nil, next) }, user, "FORBIDDEN"},
+		{"isAdmin admin", func(ctx context.Context) (interface{}, error) { return graph.IsAdmin(ctx, nil, next) }, admin, ""},
+	}
+	for _, c := range cases {
+		res, err := c.call(c.ctx)
+		if c.code == "" {
+			if err != nil || res != "ok" {
+				t.Errorf("%s: expected the field to resolve, got %v", c.name, err)
+			}
+			continue
+		}
+		gqlErr, ok := err.(*gqlerror.Error)
+		if !ok || gqlErr.Extensions["code"] != c.code {
+			t.Errorf("%s: expected %s, got %v", c.name, c.code, err)
+		}
+	}
+}
+
+func TestDirectivesInSchema(t *testing.T) {
+	r := newGraphQLRouter(setupResolver(t, testConfig(t)))
+	doGraphQL(t, r, signupMutation, signupInput("jane@example.com", "Secret#123", "Secret#123"))
+	l := login(t, r, "jane@example.com", "Secret#123")
+
+	for _, query := range []string{sessionQuery, logoutMutation, revokeAllSessionsMutation} {
+		res := doGraphQL(t, r, query, nil)
+		if len(res.Errors) != 1 || res.Errors[0].Extensions["code"] != "UNAUTHENTICATED" {
+			t.Errorf("%s: expected UNAUTHENTICATED, got %+v", query, res.Errors)
+		}
+	}
+
+	res, _ := doGraphQLRequest(t, r, revokeUserSessionsMutation, map[string]interface{}{"userId": "any"}, withBearer(l.AccessToken))
+	if len(res.Errors) != 1 || res.Errors[0].Extensions["code"] != "FORBIDDEN" {
+		t.Errorf("expected FORBIDDEN for non admins, got %+v", res.Errors)
+	}
+}
@@ -0,0 +1,124 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"server/email"
+)
+
+const (
+	verifyEmailMutation       = `mutation($token: String!) { verifyEmail(token: $token) { message accessToken user { emailVerified } } }`
+	resendVerifyEmailMutation = `mutation($email: String!) { resendVerifyEmail(email: $email) { message } }`
+)
+
+func TestSMTPSender(t *testing.T) {
+	smtp := startFakeSMTP(t)
+	cfg := testConfig(t)
+	cfg.SMTPHost, cfg.SMTPPort = smtp.Host, smtp.Port
+	cfg.SenderEmail, cfg.SenderName = "no-reply@example.com", "Account-Verse"
+
+	err := email.NewSMTPSender(cfg).Send(context.Background(), &email.Message{
+		To:      []string{"jane@example.com"},
+		Subject: "Héllo",
+		HTML:    "<p>" + strings.Repeat("long body ", 20) + "</p>",
+	})
+	if err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+
+	msg := smtp.waitForMessage(t, 1)
+	if msg.From != "no-reply@example.com" || len(msg.To) != 1 || msg.To[0] != "jane@example.com" {
+		t.Errorf("unexpected envelope %+v", msg)
+	}
+	if msg.Subject != "Héllo" || !strings.Contains(msg.Body, strings.Repeat("long body ", 20)) {
+		t.Errorf("unexpected content %+v", msg)
+	}
+}
+
+func TestEmailVerificationFlow(t *testing.T) {
+	smtp := startFakeSMTP(t)
+	cfg := testConfig(t)
+	cfg.IsEmailServiceEnabled = true
+	cfg.SMTPHost, cfg.SMTPPort = smtp.Host, smtp.Port
+	cfg.SenderEmail = "no-reply@example.com"
+	cfg.VerifyEmailURL = "http://localhost:3000/verify-email"
+	cfg.VerificationTokenExpiryTime = time.Hour
+	r := newGraphQLRouter(setupResolver(t, cfg))
+
+	res := doGraphQL(t, r, signupMutation, signupInput("jane@example.com", "Secret#123", "Secret#123"))
+	if len(res.Errors) > 0 {
+		t.Fatalf("signup failed: %+v", res.Errors)
+	}
+	var signup signupData
+	_ = json.Unmarshal(res.Data, &signup)
+	if signup.Signup.AccessToken != nil {
+		t.Error("no token must be issued before the email is verified")
+	}
+
+	msg := smtp.waitForMessage(t, 1)
+	if !strings.Contains(msg.Body, "http://localhost:3000/verify-email?token=") {
+		t.Errorf("expected a link to VERIFY_EMAIL_URL, got %q", msg.Body)
+	}
+
+	res = doGraphQL(t, r, loginMutation, map[string]interface{}{"email": "jane@example.com", "password": "Secret#123"})
+	if len(res.Errors) != 1 || res.Errors[0].Extensions["code"] != "EMAIL_NOT_VERIFIED" {
+		t.Fatalf("expected EMAIL_NOT_VERIFIED, got %+v", res.Errors)
+	}
+
+	// resending invalidates the first token
+	res = doGraphQL(t, r, resendVerifyEmailMutation, map[string]interface{}{"email": "jane@example.com"})
+	if len(res.Errors) > 0 {
+		t.Fatalf("resend failed: %+v", res.Errors)
+	}
+	first, second := tokenFromEmail(t, msg), tokenFromEmail(t, smtp.waitForMessage(t, 2))
+	res = doGraphQL(t, r, verifyEmailMutation, map[string]interface{}{"token": first})
+	if len(res.Errors) != 1 {
+		t.Errorf("expected the first token to be invalidated, got %s", res.Data)
+	}
+
+	res = doGraphQL(t, r, verifyEmailMutation, map[string]interface{}{"token": second})
+	if len(res.Errors) > 0 || !strings.Contains(string(res.Data), `"emailVerified":true`) || !strings.Contains(string(res.Data), "accessToken\":\"") {
+		t.Fatalf("verifyEmail failed: %s %+v", res.Data, res.Errors)
+	}
+
+	res = doGraphQL(t, r, verifyEmailMutation, map[string]interface{}{"token": second})
+	if len(res.Errors) != 1 {
+		t.Error("tokens must be single use")
+	}
+
+	res = doGraphQL(t, r, loginMutation, map[string]interface{}{"email": "jane@example.com", "password": "Secret#123"})
+	if len(res.Errors) > 0 {
+		t.Errorf("login after verification failed: %+v", res.Errors)
+	}
+
+	// unknown emails get the same answer and no email
+	res = doGraphQL(t, r, resendVerifyEmailMutation, map[string]interface{}{"email": "nobody@example.com"})
+	if len(res.Errors) > 0 || len(smtp.Messages()) != 2 {
+		t.Errorf("unexpected resend result for unknown email: %+v, %d emails", res.Errors, len(smtp.Messages()))
+	}
+}
+
+func TestLoginUnverifiedWhenVerificationDisabled(t *testing.T) {
+	smtp := startFakeSMTP(t)
+	cfg := testConfig(t)
+	cfg.IsEmailServiceEnabled = true
+	cfg.SMTPHost, cfg.SMTPPort = smtp.Host, smtp.Port
+	cfg.VerificationTokenExpiryTime = time.Hour
+	resolver := setupResolver(t, cfg)
+	r := newGraphQLRouter(resolver)
+
+	res := doGraphQL(t, r, signupMutation, signupInput("jane@example.com", "Secret#123", "Secret#123"))
+	if len(res.Errors) > 0 {
+		t.Fatalf("signup failed: %+v", res.Errors)
+	}
+
+	cfg.DisableEmailVerification = true
+	res = doGraphQL(t, r, loginMutation, map[string]interface{}{"email": "jane@example.com", "password": "Secret#123"})
+	if len(res.Errors) > 0 {
+		t.Errorf("unverified users must be able to log in when verification is disabled: %+v", res.Errors)
+	}
+}
@@ -0,0 +1,161 @@
+package test
+
+import (
+	"bufio"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/mail"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// receivedEmail is a message captured by fakeSMTP
+type receivedEmail struct {
+	From    string
+	To      []string
+	Subject string
+	Body    string
+}
+
+// fakeSMTP is a minimal in-process SMTP server recording the messages it receives
+type fakeSMTP struct {
+	Host string
+	Port int
+
+	mu       sync.Mutex
+	messages []receivedEmail
+}
+
+func startFakeSMTP(t *testing.T) *fakeSMTP {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to start fake smtp server: %v", err)
+	}
+	t.Cleanup(func() { l.Close() })
+
+	s := &fakeSMTP{Host: "127.0.0.1", Port: l.Addr().(*net.TCPAddr).Port}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *fakeSMTP) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	reply := func(line string) { fmt.Fprintf(conn, "%s\r\n", line) }
+
+	reply("220 localhost fake smtp")
+	var msg receivedEmail
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		cmd := strings.ToUpper(strings.TrimSpace(line))
+		switch {
+		case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+			reply("250-localhost")
+			reply("250 8BITMIME")
+		case strings.HasPrefix(cmd, "MAIL FROM:"):
+			msg = receivedEmail{From: smtpAddress(line[10:])}
+			reply("250 OK")
+		case strings.HasPrefix(cmd, "RCPT TO:"):
+			msg.To = append(msg.To, smtpAddress(line[8:]))
+			reply("250 OK")
+		case cmd == "DATA":
+			reply("354 End data with <CR><LF>.<CR><LF>")
+			var data strings.Builder
+			for {
+				l, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if l == ".\r\n" {
+					break
+				}
+				data.WriteString(strings.TrimPrefix(l, "."))
+			}
+			s.record(msg, data.String())
+			reply("250 OK")
+		case cmd == "QUIT":
+			reply("221 Bye")
+			return
+		default:
+			reply("250 OK")
+		}
+	}
+}
+
+// smtpAddress extracts the address from a "<addr> PARAMS" command argument
+func smtpAddress(arg string) string {
+	arg = strings.TrimSpace(arg)
+	if i := strings.Index(arg, ">"); i >= 0 {
+		arg = arg[:i]
+	}
+	return strings.TrimPrefix(arg, "<")
+}
+
+func (s *fakeSMTP) record(msg receivedEmail, data string) {
+	parsed, err := mail.ReadMessage(strings.NewReader(data))
+	if err == nil {
+		msg.Subject, _ = new(mime.WordDecoder).DecodeHeader(parsed.Header.Get("Subject"))
+		raw, _ := io.ReadAll(parsed.Body)
+		if parsed.Header.Get("Content-Transfer-Encoding") == "base64" {
+			raw, _ = base64.StdEncoding.DecodeString(strings.NewReplacer("\r", "", "\n", "").Replace(string(raw)))
+		}
+		msg.Body = string(raw)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.messages = append(s.messages, msg)
+}
+
+// Messages returns a copy of the received messages
+func (s *fakeSMTP) Messages() []receivedEmail {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]receivedEmail(nil), s.messages...)
+}
+
+// waitForMessage waits until n messages have been received and returns the last one
+func (s *fakeSMTP) waitForMessage(t *testing.T, n int) receivedEmail {
+	t.Helper()
+
+	for i := 0; i < 100; i++ {
+		if msgs := s.Messages(); len(msgs) >= n {
+			return msgs[n-1]
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("expected %d emails, got %d", n, len(s.Messages()))
+	return receivedEmail{}
+}
+
+var tokenPattern = regexp.MustCompile(`token=([A-Za-z0-9_-]+)`)
+
+// tokenFromEmail extracts the token query parameter of the link in the email
+func tokenFromEmail(t *testing.T, msg receivedEmail) string {
+	t.Helper()
+
+	m := tokenPattern.FindStringSubmatch(msg.Body)
+	if m == nil {
+		t.Fatalf("no token found in email body %q", msg.Body)
+	}
+	return m[1]
+}
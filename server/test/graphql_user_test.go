@@ -0,0 +1,134 @@
+package test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"server/graph"
+	"server/handlers"
+	"server/middlewares"
+)
+
+type graphQLResponse struct {
+	Data   json.RawMessage `json:"data"`
+	Errors []struct {
+		Message    string                 `json:"message"`
+		Extensions map[string]interface{} `json:"extensions"`
+	} `json:"errors"`
+}
+
+func setupGraphQLRouter(t *testing.T) *gin.Engine {
+	t.Helper()
+
+	cfg := testConfig(t)
+	cfg.AdminSecret = testAdminSecret
+	return newGraphQLRouter(setupResolver(t, cfg))
+}
+
+func newGraphQLRouter(resolver *graph.Resolver) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(middlewares.GinContextToContextMiddleware())
+	r.Use(middlewares.Authentication(resolver.Config, resolver.Tokens, resolver.Sessions, resolver.DB))
+	r.POST("/query", middlewares.FirstParty(), handlers.GraphQLHandler(resolver))
+	return r
+}
+
+func doGraphQL(t *testing.T, r http.Handler, query string, variables map[string]interface{}) graphQLResponse {
+	t.Helper()
+
+	res, _ := doGraphQLRequest(t, r, query, variables, nil)
+	return res
+}
+
+// doGraphQLRequest is doGraphQL letting the caller add headers or cookies to
+// the request, it also returns the recorded HTTP response
+func doGraphQLRequest(t *testing.T, r http.Handler, query string, variables map[string]interface{}, prepare func(*http.Request)) (graphQLResponse, *httptest.ResponseRecorder) {
+	t.Helper()
+
+	body, _ := json.Marshal(map[string]interface{}{"query": query, "variables": variables})
+	req, _ := http.NewRequest(http.MethodPost, "/query", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if prepare != nil {
+		prepare(req)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	var res graphQLResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+		t.Fatalf("invalid response %q: %v", w.Body.String(), err)
+	}
+	return res, w
+}
+
+// withBearer authenticates the request with an access token
+func withBearer(accessToken string) func(*http.Request) {
+	return func(req *http.Request) {
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+	}
+}
+
+const createUserMutation = `mutation($params: CreateUserInput!) { _createUser(params: $params) { id name email } }`
+
+func TestCreateUserMutation(t *testing.T) {
+	r := setupGraphQLRouter(t)
+	input := map[string]interface{}{
+		"params": map[string]interface{}{"name": "Jane", "email": "Jane@Example.com"},
+	}
+
+	res := doGraphQL(t, r, createUserMutation, input)
+	if len(res.Errors) != 1 || res.Errors[0].Extensions["code"] != "UNAUTHENTICATED" {
+		t.Fatalf("expected the admin secret to be required, got %+v", res.Errors)
+	}
+
+	res, _ = doGraphQLRequest(t, r, createUserMutation, input, withAdminSecret(testAdminSecret))
+	if len(res.Errors) > 0 {
+		t.Fatalf("unexpected errors: %+v", res.Errors)
+	}
+	var created struct {
+		CreateUser struct{ ID, Name, Email string } `json:"_createUser"`
+	}
+	_ = json.Unmarshal(res.Data, &created)
+	if created.CreateUser.Email != "jane@example.com" {
+		t.Errorf("expected normalized email, got %q", created.CreateUser.Email)
+	}
+
+	res, _ = doGraphQLRequest(t, r, adminUserQuery, map[string]interface{}{"id": created.CreateUser.ID}, withAdminSecret(testAdminSecret))
+	if len(res.Errors) > 0 || !bytes.Contains(res.Data, []byte("jane@example.com")) {
+		t.Errorf("_user query returned %s %+v", res.Data, res.Errors)
+	}
+}
+
+func TestCreateUserMutationValidation(t *testing.T) {
+	r := setupGraphQLRouter(t)
+
+	cases := []struct {
+		name, email, code string
+	}{
+		{"  ", "jane@example.com", "BAD_USER_INPUT"},
+		{"Jane", "not-an-email", "BAD_USER_INPUT"},
+		{"Jane", "jane@example.com", ""},
+		{"Jane Again", "jane@example.com", "CONFLICT"},
+	}
+
+	for _, c := range cases {
+		res, _ := doGraphQLRequest(t, r, createUserMutation, map[string]interface{}{
+			"params": map[string]interface{}{"name": c.name, "email": c.email},
+		}, withAdminSecret(testAdminSecret))
+		if c.code == "" {
+			if len(res.Errors) > 0 {
+				t.Errorf("%q: unexpected errors %+v", c.email, res.Errors)
+			}
+			continue
+		}
+		if len(res.Errors) != 1 || res.Errors[0].Extensions["code"] != c.code {
+			t.Errorf("%q/%q: expected %s, got %+v", c.name, c.email, c.code, res.Errors)
+		}
+	}
+}
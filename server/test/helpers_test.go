@@ -0,0 +1,94 @@
+package test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"server/config"
+	"server/database"
+	"server/database/sql"
+	"server/email"
+	"server/graph"
+	"server/sessionstore"
+	"server/token"
+)
+
+// testConfig returns the configuration LoadConfig would produce with an empty environment
+func testConfig(t *testing.T) *config.Config {
+	t.Helper()
+
+	return &config.Config{
+		Port:                     "8080",
+		ShutdownTimeout:          5 * time.Second,
+		DBType:                   "sqlite",
+		DBName:                   filepath.Join(t.TempDir(), "app.db"),
+		AuthorizerURL:            "http://localhost:8080",
+		JwtType:                  "HS256",
+		JwtSecret:                "test-secret",
+		JwtRoleClaim:             "roles",
+		AccessTokenExpiryTime:    30 * time.Minute,
+		RefreshTokenExpiryTime:   24 * time.Hour,
+		Roles:                    []string{"user", "admin"},
+		DefaultRoles:             []string{"user"},
+		AppCookieSecure:          true,
+		PasswordMinLength:        8,
+		PasswordMaxLength:        72,
+		PasswordRequireLowercase: true,
+		PasswordRequireUppercase: true,
+		PasswordRequireDigit:     true,
+		PasswordRequireSpecial:   true,
+		OrganizationName:         "Account-Verse",
+	}
+}
+
+// setupResolver wires a resolver over a fresh sqlite database
+func setupResolver(t *testing.T, cfg *config.Config) *graph.Resolver {
+	t.Helper()
+
+	tokens, err := token.NewManager(cfg)
+	if err != nil {
+		t.Fatalf("failed to create token manager: %v", err)
+	}
+	return graph.NewResolver(cfg, setupDatabase(t), tokens, email.New(cfg), sessionstore.NewMemoryStore())
+}
+
+// setupSQLite opens a fresh sqlite database with every migration applied
+func setupSQLite(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{TranslateError: true})
+	if err != nil {
+		t.Fatalf("failed to open sqlite: %v", err)
+	}
+	if _, err := sql.NewMigrator(db).Up(context.Background()); err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+
+	return db
+}
+
+// setupDatabase returns a migrated sqlite database wired like database.NewDatabase does
+func setupDatabase(t *testing.T) *database.Database {
+	t.Helper()
+
+	db := setupSQLite(t)
+	return &database.Database{
+		UserRepository:                sql.NewUserRepository(db),
+		OAuthClientRepository:         sql.NewOAuthClientRepository(db),
+		VerificationRequestRepository: sql.NewVerificationRequestRepository(db),
+		OIDCProviderRepository:        sql.NewOIDCProviderRepository(db),
+		Type:                          "sqlite",
+		SQL:                           db,
+		Migrator:                      sql.NewMigrator(db),
+	}
+}
@@ -0,0 +1,46 @@
+package test
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+const loginMutation = `mutation($email: String!, $password: String!) {
+  login(email: $email, password: $password) { message accessToken refreshToken expiresIn user { email } }
+}`
+
+func TestLogin(t *testing.T) {
+	cfg := testConfig(t)
+	r := newGraphQLRouter(setupResolver(t, cfg))
+
+	res := doGraphQL(t, r, signupMutation, signupInput("jane@example.com", "Secret#123", "Secret#123"))
+	if len(res.Errors) > 0 {
+		t.Fatalf("signup failed: %+v", res.Errors)
+	}
+
+	res = doGraphQL(t, r, loginMutation, map[string]interface{}{"email": "Jane@example.com", "password": "Secret#123"})
+	if len(res.Errors) > 0 {
+		t.Fatalf("login failed: %+v", res.Errors)
+	}
+	var data struct {
+		Login struct {
+			AccessToken  *string
+			RefreshToken *string
+			ExpiresIn    *int
+		}
+	}
+	_ = json.Unmarshal(res.Data, &data)
+	if data.Login.AccessToken == nil || data.Login.RefreshToken == nil || data.Login.ExpiresIn == nil || *data.Login.ExpiresIn != 1800 {
+		t.Errorf("unexpected login response %s", res.Data)
+	}
+
+	for _, c := range []struct{ email, password string }{
+		{"jane@example.com", "wrong"},
+		{"nobody@example.com", "Secret#123"},
+	} {
+		res = doGraphQL(t, r, loginMutation, map[string]interface{}{"email": c.email, "password": c.password})
+		if len(res.Errors) != 1 || res.Errors[0].Extensions["code"] != "UNAUTHENTICATED" || res.Errors[0].Message != "invalid email or password" {
+			t.Errorf("%s: expected a generic UNAUTHENTICATED error, got %+v", c.email, res.Errors)
+		}
+	}
+}
@@ -0,0 +1,214 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"server/cookie"
+)
+
+const (
+	sessionQuery               = `query { session { id roles scope createdAt expiresAt user { email } } }`
+	logoutMutation             = `mutation { logout { message } }`
+	revokeAllSessionsMutation  = `mutation { revokeAllSessions { message } }`
+	revokeUserSessionsMutation = `mutation($userId: ID!) { revokeUserSessions(userId: $userId) { message } }`
+)
+
+type loginResult struct {
+	AccessToken  string
+	RefreshToken string
+	Cookie       *http.Cookie
+}
+
+// login logs the user in and returns its tokens and session cookie
+func login(t *testing.T, r http.Handler, email, password string) loginResult {
+	t.Helper()
+
+	res, w := doGraphQLRequest(t, r, loginMutation, map[string]interface{}{"email": email, "password": password}, nil)
+	if len(res.Errors) > 0 {
+		t.Fatalf("login failed: %+v", res.Errors)
+	}
+	var data struct {
+		Login struct{ AccessToken, RefreshToken string }
+	}
+	_ = json.Unmarshal(res.Data, &data)
+	return loginResult{
+		AccessToken:  data.Login.AccessToken,
+		RefreshToken: data.Login.RefreshToken,
+		Cookie:       sessionCookie(w),
+	}
+}
+
+// sessionCookie returns the session cookie set by the response, if any
+func sessionCookie(w *httptest.ResponseRecorder) *http.Cookie {
+	for _, c := range w.Result().Cookies() {
+		if c.Name == cookie.SessionName {
+			return c
+		}
+	}
+	return nil
+}
+
+func withCookie(c *http.Cookie) func(*http.Request) {
+	return func(req *http.Request) {
+		req.AddCookie(c)
+	}
+}
+
+func TestSessionQuery(t *testing.T) {
+	r := newGraphQLRouter(setupResolver(t, testConfig(t)))
+	doGraphQL(t, r, signupMutation, signupInput("jane@example.com", "Secret#123", "Secret#123"))
+	l := login(t, r, "jane@example.com", "Secret#123")
+
+	if l.Cookie == nil || !l.Cookie.HttpOnly || !l.Cookie.Secure || l.Cookie.Value != l.RefreshToken {
+		t.Fatalf("expected an HttpOnly secure session cookie, got %+v", l.Cookie)
+	}
+
+	res, _ := doGraphQLRequest(t, r, sessionQuery, nil, withBearer(l.AccessToken))
+	var data struct {
+		Session struct {
+			ID    string
+			Roles []string
+			User  struct{ Email string }
+		}
+	}
+	_ = json.Unmarshal(res.Data, &data)
+	if len(res.Errors) > 0 || data.Session.ID == "" || data.Session.User.Email != "jane@example.com" || len(data.Session.Roles) != 1 {
+		t.Fatalf("unexpected session %s %+v", res.Data, res.Errors)
+	}
+
+	res, _ = doGraphQLRequest(t, r, sessionQuery, nil, withCookie(l.Cookie))
+	if len(res.Errors) > 0 {
+		t.Errorf("expected the session cookie to authenticate, got %+v", res.Errors)
+	}
+
+	res = doGraphQL(t, r, sessionQuery, nil)
+	if len(res.Errors) != 1 || res.Errors[0].Extensions["code"] != "UNAUTHENTICATED" {
+		t.Errorf("expected UNAUTHENTICATED without credentials, got %+v", res.Errors)
+	}
+}
+
+func TestLogout(t *testing.T) {
+	r := newGraphQLRouter(setupResolver(t, testConfig(t)))
+	doGraphQL(t, r, signupMutation, signupInput("jane@example.com", "Secret#123", "Secret#123"))
+	current := login(t, r, "jane@example.com", "Secret#123")
+	other := login(t, r, "jane@example.com", "Secret#123")
+
+	res, w := doGraphQLRequest(t, r, logoutMutation, nil, withBearer(current.AccessToken))
+	if len(res.Errors) > 0 {
+		t.Fatalf("logout failed: %+v", res.Errors)
+	}
+	if c := sessionCookie(w); c == nil || c.MaxAge >= 0 {
+		t.Errorf("expected the session cookie to be cleared, got %+v", c)
+	}
+
+	res, _ = doGraphQLRequest(t, r, sessionQuery, nil, withBearer(current.AccessToken))
+	if len(res.Errors) != 1 {
+		t.Error("the access token of a logged out session must be rejected")
+	}
+	if _, res := refreshViaGraphQL(t, r, current.RefreshToken); len(res.Errors) != 1 {
+		t.Error("the refresh token of a logged out session must be rejected")
+	}
+	res, _ = doGraphQLRequest(t, r, sessionQuery, nil, withBearer(other.AccessToken))
+	if len(res.Errors) > 0 {
+		t.Errorf("other sessions must survive a logout, got %+v", res.Errors)
+	}
+}
+
+func TestRevokeAllSessions(t *testing.T) {
+	r := newGraphQLRouter(setupResolver(t, testConfig(t)))
+	doGraphQL(t, r, signupMutation, signupInput("jane@example.com", "Secret#123", "Secret#123"))
+	current := login(t, r, "jane@example.com", "Secret#123")
+	other := login(t, r, "jane@example.com", "Secret#123")
+
+	res, w := doGraphQLRequest(t, r, revokeAllSessionsMutation, nil, withCookie(current.Cookie))
+	if len(res.Errors) > 0 {
+		t.Fatalf("revokeAllSessions failed: %+v", res.Errors)
+	}
+	if c := sessionCookie(w); c == nil || c.MaxAge >= 0 {
+		t.Errorf("expected the session cookie to be cleared, got %+v", c)
+	}
+	for _, l := range []loginResult{current, other} {
+		res, _ = doGraphQLRequest(t, r, sessionQuery, nil, withBearer(l.AccessToken))
+		if len(res.Errors) != 1 {
+			t.Error("every session must be revoked")
+		}
+	}
+
+	res = doGraphQL(t, r, revokeAllSessionsMutation, nil)
+	if len(res.Errors) != 1 || res.Errors[0].Extensions["code"] != "UNAUTHENTICATED" {
+		t.Errorf("expected UNAUTHENTICATED, got %+v", res.Errors)
+	}
+}
+
+func TestRevokeUserSessions(t *testing.T) {
+	resolver := setupResolver(t, testConfig(t))
+	r := newGraphQLRouter(resolver)
+	ctx := context.Background()
+
+	doGraphQL(t, r, signupMutation, signupInput("jane@example.com", "Secret#123", "Secret#123"))
+	doGraphQL(t, r, signupMutation, signupInput("admin@example.com", "Secret#123", "Secret#123"))
+	admin, _ := resolver.DB.GetUserByEmail(ctx, "admin@example.com")
+	admin.SetRoles([]string{"user", "admin"})
+	if _, err := resolver.DB.UpdateUser(ctx, admin); err != nil {
+		t.Fatalf("UpdateUser failed: %v", err)
+	}
+
+	jane := login(t, r, "jane@example.com", "Secret#123")
+	adminLogin := login(t, r, "admin@example.com", "Secret#123")
+	janeUser, _ := resolver.DB.GetUserByEmail(ctx, "jane@example.com")
+
+	res, _ := doGraphQLRequest(t, r, revokeUserSessionsMutation, map[string]interface{}{"userId": admin.ID}, withBearer(jane.AccessToken))
+	if len(res.Errors) != 1 || res.Errors[0].Extensions["code"] != "FORBIDDEN" {
+		t.Fatalf("expected FORBIDDEN for non admins, got %+v", res.Errors)
+	}
+
+	res, _ = doGraphQLRequest(t, r, revokeUserSessionsMutation, map[string]interface{}{"userId": janeUser.ID}, withBearer(adminLogin.AccessToken))
+	if len(res.Errors) > 0 {
+		t.Fatalf("revokeUserSessions failed: %+v", res.Errors)
+	}
+	res, _ = doGraphQLRequest(t, r, sessionQuery, nil, withBearer(jane.AccessToken))
+	if len(res.Errors) != 1 {
+		t.Error("the sessions of the user must be revoked")
+	}
+	res, _ = doGraphQLRequest(t, r, sessionQuery, nil, withBearer(adminLogin.AccessToken))
+	if len(res.Errors) > 0 {
+		t.Errorf("the admin session must survive, got %+v", res.Errors)
+	}
+
+	res, _ = doGraphQLRequest(t, r, revokeUserSessionsMutation, map[string]interface{}{"userId": "unknown"}, withBearer(adminLogin.AccessToken))
+	if len(res.Errors) != 1 || res.Errors[0].Extensions["code"] != "NOT_FOUND" {
+		t.Errorf("expected NOT_FOUND, got %+v", res.Errors)
+	}
+}
+
+func TestRefreshTokenFromCookie(t *testing.T) {
+	r := newGraphQLRouter(setupResolver(t, testConfig(t)))
+	doGraphQL(t, r, signupMutation, signupInput("jane@example.com", "Secret#123", "Secret#123"))
+	l := login(t, r, "jane@example.com", "Secret#123")
+
+	res, w := doGraphQLRequest(t, r, `mutation { refreshToken { refreshToken } }`, nil, withCookie(l.Cookie))
+	if len(res.Errors) > 0 {
+		t.Fatalf("refreshToken from cookie failed: %+v", res.Errors)
+	}
+	rotated := sessionCookie(w)
+	if rotated == nil || rotated.Value == l.Cookie.Value {
+		t.Fatalf("expected the cookie to hold the rotated token, got %+v", rotated)
+	}
+
+	// the old cookie is a reused token: the family is revoked and the cookie cleared
+	res, w = doGraphQLRequest(t, r, `mutation { refreshToken { refreshToken } }`, nil, withCookie(l.Cookie))
+	if len(res.Errors) != 1 {
+		t.Fatal("expected the rotated cookie to be rejected")
+	}
+	if c := sessionCookie(w); c == nil || c.MaxAge >= 0 {
+		t.Errorf("expected the session cookie to be cleared, got %+v", c)
+	}
+	res, _ = doGraphQLRequest(t, r, sessionQuery, nil, withCookie(rotated))
+	if len(res.Errors) != 1 {
+		t.Error("the family must be revoked after reuse")
+	}
+}
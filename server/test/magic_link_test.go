@@ -0,0 +1,213 @@
+package test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"server/config"
+	"server/crypto"
+	"server/database/models"
+	"server/refs"
+	"server/routes"
+	"server/token"
+)
+
+const magicLinkLoginMutation = `mutation($email: String!, $roles: [String!], $scope: [String!], $redirectUri: String) {
+  magicLinkLogin(email: $email, roles: $roles, scope: $scope, redirectUri: $redirectUri) { message }
+}`
+
+func magicLinkConfig(t *testing.T, smtp *fakeSMTP) *config.Config {
+	t.Helper()
+
+	cfg := testConfig(t)
+	cfg.IsEmailServiceEnabled = true
+	cfg.SMTPHost, cfg.SMTPPort = smtp.Host, smtp.Port
+	cfg.AppURL = "http://localhost:3000"
+	cfg.MagicLinkExpiryTime = 15 * time.Minute
+	return cfg
+}
+
+// followMagicLink calls the /verify_email endpoint with the token of msg and
+// returns the redirect location
+func followMagicLink(t *testing.T, r http.Handler, msg receivedEmail) *url.URL {
+	t.Helper()
+
+	if !strings.Contains(msg.Body, "http://localhost:8080/verify_email?token=") {
+		t.Fatalf("expected a link to the verify_email endpoint, got %q", msg.Body)
+	}
+	req := httptest.NewRequest(http.MethodGet, "/verify_email?token="+tokenFromEmail(t, msg), nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusFound {
+		t.Fatalf("expected a redirect, got %d: %s", w.Code, w.Body.String())
+	}
+	location, err := url.Parse(w.Header().Get("Location"))
+	if err != nil {
+		t.Fatalf("invalid location: %v", err)
+	}
+	return location
+}
+
+func TestMagicLinkLoginCreatesUser(t *testing.T) {
+	smtp := startFakeSMTP(t)
+	cfg := magicLinkConfig(t, smtp)
+	resolver := setupResolver(t, cfg)
+	r := routes.InitRouter(logrus.New(), resolver)
+
+	res := doGraphQL(t, r, magicLinkLoginMutation, map[string]interface{}{
+		"email":       "Jane@Example.com",
+		"scope":       []string{"openid", "email"},
+		"redirectUri": "http://localhost:3000/dashboard",
+	})
+	if len(res.Errors) > 0 {
+		t.Fatalf("magicLinkLogin failed: %+v", res.Errors)
+	}
+
+	user, err := resolver.DB.GetUserByEmail(context.Background(), "jane@example.com")
+	if err != nil {
+		t.Fatalf("expected the user to be created: %v", err)
+	}
+	if user.IsEmailVerified() || user.Password != "" || strings.Join(user.RoleList(), ",") != "user" {
+		t.Errorf("unexpected new user %+v", user)
+	}
+
+	msg := smtp.waitForMessage(t, 1)
+	linkToken := tokenFromEmail(t, msg)
+	location := followMagicLink(t, r, msg)
+	if location.Scheme+"://"+location.Host+location.Path != "http://localhost:3000/dashboard" {
+		t.Errorf("unexpected redirect %s", location)
+	}
+	params, _ := url.ParseQuery(location.Fragment)
+	if params.Get("token_type") != "Bearer" || params.Get("refresh_token") == "" {
+		t.Errorf("unexpected fragment %q", location.Fragment)
+	}
+	claims, err := resolver.Tokens.ParseToken(params.Get("access_token"), token.TypeAccessToken)
+	if err != nil {
+		t.Fatalf("access token does not verify: %v", err)
+	}
+	if claims.Subject != user.ID || claims.Scope != "openid email" {
+		t.Errorf("unexpected claims %+v", claims)
+	}
+
+	user, _ = resolver.DB.GetUserByEmail(context.Background(), "jane@example.com")
+	if !user.IsEmailVerified() {
+		t.Error("using the link must verify the email")
+	}
+
+	// links are single use
+	req := httptest.NewRequest(http.MethodGet, "/verify_email?token="+linkToken, nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for a used link, got %d", w.Code)
+	}
+}
+
+func TestMagicLinkLoginRoles(t *testing.T) {
+	smtp := startFakeSMTP(t)
+	cfg := magicLinkConfig(t, smtp)
+	resolver := setupResolver(t, cfg)
+	r := routes.InitRouter(logrus.New(), resolver)
+
+	res := doGraphQL(t, r, magicLinkLoginMutation, map[string]interface{}{"email": "jane@example.com", "roles": []string{"superuser"}})
+	if len(res.Errors) != 1 || res.Errors[0].Extensions["code"] != "BAD_USER_INPUT" {
+		t.Fatalf("expected unknown roles to be rejected, got %+v", res.Errors)
+	}
+
+	// protected roles are not assigned to new users, so the link is refused
+	// when used
+	res = doGraphQL(t, r, magicLinkLoginMutation, map[string]interface{}{"email": "jane@example.com", "roles": []string{"admin"}})
+	if len(res.Errors) > 0 {
+		t.Fatalf("magicLinkLogin failed: %+v", res.Errors)
+	}
+	location := followMagicLink(t, r, smtp.waitForMessage(t, 1))
+	if params, _ := url.ParseQuery(location.Fragment); params.Get("error") != "access_denied" || params.Get("access_token") != "" {
+		t.Errorf("expected access_denied, got %q", location.Fragment)
+	}
+
+	res = doGraphQL(t, r, magicLinkLoginMutation, map[string]interface{}{"email": "jane@example.com", "roles": []string{"user"}})
+	if len(res.Errors) > 0 {
+		t.Fatalf("magicLinkLogin failed: %+v", res.Errors)
+	}
+	location = followMagicLink(t, r, smtp.waitForMessage(t, 2))
+	if location.Scheme+"://"+location.Host != cfg.AppURL {
+		t.Errorf("expected a redirect to APP_URL, got %s", location)
+	}
+	params, _ := url.ParseQuery(location.Fragment)
+	claims, err := resolver.Tokens.ParseToken(params.Get("access_token"), token.TypeAccessToken)
+	if err != nil || strings.Join(claims.Roles, ",") != "user" {
+		t.Errorf("expected the requested roles in the token, got %+v (%v)", claims, err)
+	}
+}
+
+func TestMagicLinkLoginRestrictions(t *testing.T) {
+	smtp := startFakeSMTP(t)
+	cfg := magicLinkConfig(t, smtp)
+	resolver := setupResolver(t, cfg)
+	r := newGraphQLRouter(resolver)
+
+	res := doGraphQL(t, r, magicLinkLoginMutation, map[string]interface{}{"email": "jane@example.com", "redirectUri": "https://evil.example.com"})
+	if len(res.Errors) != 1 || res.Errors[0].Message != "invalid redirect URI" {
+		t.Errorf("expected redirect URIs outside the allowed origins to be rejected, got %+v", res.Errors)
+	}
+
+	cfg.DisableSignUp = true
+	res = doGraphQL(t, r, magicLinkLoginMutation, map[string]interface{}{"email": "jane@example.com"})
+	if len(res.Errors) > 0 {
+		t.Fatalf("expected the generic response, got %+v", res.Errors)
+	}
+	if _, err := resolver.DB.GetUserByEmail(context.Background(), "jane@example.com"); err == nil {
+		t.Error("users must not be created when sign up is disabled")
+	}
+	if n := len(smtp.Messages()); n != 0 {
+		t.Errorf("expected no email, got %d", n)
+	}
+
+	cfg.DisableMagicLinkLogin = true
+	res = doGraphQL(t, r, magicLinkLoginMutation, map[string]interface{}{"email": "jane@example.com"})
+	if len(res.Errors) != 1 || res.Errors[0].Extensions["code"] != "FORBIDDEN" {
+		t.Errorf("expected FORBIDDEN when magic link login is disabled, got %+v", res.Errors)
+	}
+}
+
+func TestMagicLinkLoginMFA(t *testing.T) {
+	smtp := startFakeSMTP(t)
+	resolver := setupResolver(t, magicLinkConfig(t, smtp))
+	r := routes.InitRouter(logrus.New(), resolver)
+	hash, _ := crypto.HashPassword("Secret#123")
+	if _, err := resolver.DB.CreateUser(context.Background(), &models.User{Email: "jane@example.com", Password: hash, Roles: "user", EmailVerifiedAt: refs.NewInt64Ref(time.Now().Unix())}); err != nil {
+		t.Fatalf("CreateUser failed: %v", err)
+	}
+	secret, _ := enrollTotp(t, r, login(t, r, "jane@example.com", "Secret#123").AccessToken)
+
+	// the link is a first factor only, the code is still asked for
+	res := doGraphQL(t, r, magicLinkLoginMutation, map[string]interface{}{"email": "jane@example.com"})
+	if len(res.Errors) > 0 {
+		t.Fatalf("magicLinkLogin failed: %+v", res.Errors)
+	}
+	location := followMagicLink(t, r, smtp.waitForMessage(t, 1))
+	params, _ := url.ParseQuery(location.Fragment)
+	if params.Get("access_token") != "" || params.Get("refresh_token") != "" || params.Get("mfa_required") != "true" || params.Get("mfa_token") == "" {
+		t.Fatalf("expected a TOTP code to be asked for, got %q", location.Fragment)
+	}
+
+	verified, res := verifyTotp(t, r, params.Get("mfa_token"), totpCode(t, secret, 0))
+	if len(res.Errors) > 0 || verified.AccessToken == nil {
+		t.Fatalf("verifyTotp failed: %s %+v", res.Data, res.Errors)
+	}
+	claims, err := resolver.Tokens.ParseToken(*verified.AccessToken, token.TypeAccessToken)
+	if err != nil {
+		t.Fatalf("ParseToken failed: %v", err)
+	}
+	session, err := resolver.Sessions.GetSession(context.Background(), claims.SessionID)
+	if err != nil || strings.Join(session.AMR, " ") != "email otp" {
+		t.Errorf("expected the session to record both factors, got %+v (%v)", session, err)
+	}
+}
@@ -0,0 +1,365 @@
+package test
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pquerna/otp/totp"
+
+	"server/token"
+)
+
+const (
+	mfaLoginMutation = `mutation($email: String!, $password: String!) {
+		login(email: $email, password: $password) {
+			message accessToken mfaRequired mfaToken totpEnrollment { secret uri qrCode }
+		}
+	}`
+	mfaSignupMutation = `mutation($input: SignUpInput!) {
+		signup(input: $input) { accessToken mfaRequired mfaToken totpEnrollment { secret } }
+	}`
+	verifyTotpMutation = `mutation($mfaToken: String!, $code: String!) {
+		verifyTotp(mfaToken: $mfaToken, code: $code) { accessToken refreshToken recoveryCodes user { mfaEnabled } }
+	}`
+	enrollTotpMutation  = `mutation { enrollTotp { secret uri qrCode } }`
+	confirmTotpMutation = `mutation($code: String!) { confirmTotp(code: $code) { recoveryCodes } }`
+	disableTotpMutation = `mutation($code: String!) { disableTotp(code: $code) { message } }`
+)
+
+// mfaAuthResponse is the part of an AuthResponse used by the MFA tests
+type mfaAuthResponse struct {
+	AccessToken    *string
+	MfaRequired    bool
+	MfaToken       *string
+	TotpEnrollment *struct{ Secret, URI, QRCode string } `json:"totpEnrollment"`
+	RecoveryCodes  []string
+}
+
+// totpCode returns the code of the secret for the period at offset periods
+// from now. The server accepts each period once, so successive logins of a
+// user use increasing offsets.
+func totpCode(t *testing.T, secret string, offset int) string {
+	t.Helper()
+
+	code, err := totp.GenerateCode(secret, time.Now().Add(time.Duration(offset)*30*time.Second))
+	if err != nil {
+		t.Fatalf("GenerateCode failed: %v", err)
+	}
+	return code
+}
+
+// mfaLogin logs in with the password and expects a TOTP code to be asked for
+func mfaLogin(t *testing.T, r http.Handler, email, password string) mfaAuthResponse {
+	t.Helper()
+
+	res := doGraphQL(t, r, mfaLoginMutation, map[string]interface{}{"email": email, "password": password})
+	var data struct{ Login mfaAuthResponse }
+	_ = json.Unmarshal(res.Data, &data)
+	if len(res.Errors) > 0 || !data.Login.MfaRequired || data.Login.AccessToken != nil || data.Login.MfaToken == nil {
+		t.Fatalf("expected a TOTP code to be asked for, got %s %+v", res.Data, res.Errors)
+	}
+	return data.Login
+}
+
+func verifyTotp(t *testing.T, r http.Handler, mfaToken, code string) (mfaAuthResponse, graphQLResponse) {
+	t.Helper()
+
+	res := doGraphQL(t, r, verifyTotpMutation, map[string]interface{}{"mfaToken": mfaToken, "code": code})
+	var data struct{ VerifyTotp mfaAuthResponse }
+	_ = json.Unmarshal(res.Data, &data)
+	return data.VerifyTotp, res
+}
+
+// enrollTotp enables an authenticator app for the logged in user and returns
+// its secret and the recovery codes
+func enrollTotp(t *testing.T, r http.Handler, accessToken string) (string, []string) {
+	t.Helper()
+
+	res, _ := doGraphQLRequest(t, r, enrollTotpMutation, nil, withBearer(accessToken))
+	var enrolled struct {
+		EnrollTotp struct{ Secret, URI, QRCode string } `json:"enrollTotp"`
+	}
+	_ = json.Unmarshal(res.Data, &enrolled)
+	if len(res.Errors) > 0 || enrolled.EnrollTotp.Secret == "" {
+		t.Fatalf("enrollTotp failed: %s %+v", res.Data, res.Errors)
+	}
+	secret := enrolled.EnrollTotp.Secret
+
+	res, _ = doGraphQLRequest(t, r, confirmTotpMutation, map[string]interface{}{"code": totpCode(t, secret, -1)}, withBearer(accessToken))
+	var confirmed struct {
+		ConfirmTotp struct{ RecoveryCodes []string }
+	}
+	_ = json.Unmarshal(res.Data, &confirmed)
+	if len(res.Errors) > 0 || len(confirmed.ConfirmTotp.RecoveryCodes) != 10 {
+		t.Fatalf("confirmTotp failed: %s %+v", res.Data, res.Errors)
+	}
+	return secret, confirmed.ConfirmTotp.RecoveryCodes
+}
+
+func TestTOTPEnrollment(t *testing.T) {
+	resolver := setupResolver(t, testConfig(t))
+	r := newGraphQLRouter(resolver)
+	doGraphQL(t, r, signupMutation, signupInput("jane@example.com", "Secret#123", "Secret#123"))
+	jane := login(t, r, "jane@example.com", "Secret#123")
+
+	res, _ := doGraphQLRequest(t, r, confirmTotpMutation, map[string]interface{}{"code": "123456"}, withBearer(jane.AccessToken))
+	if len(res.Errors) != 1 || res.Errors[0].Extensions["code"] != "BAD_USER_INPUT" {
+		t.Errorf("expected confirmTotp to require enrollTotp, got %+v", res.Errors)
+	}
+
+	res, _ = doGraphQLRequest(t, r, enrollTotpMutation, nil, withBearer(jane.AccessToken))
+	var data struct {
+		EnrollTotp struct{ Secret, URI, QRCode string } `json:"enrollTotp"`
+	}
+	_ = json.Unmarshal(res.Data, &data)
+	enrollment := data.EnrollTotp
+	if len(res.Errors) > 0 || !strings.HasPrefix(enrollment.URI, "otpauth://totp/") || !strings.Contains(enrollment.URI, "secret="+enrollment.Secret) {
+		t.Fatalf("enrollTotp returned %s %+v", res.Data, res.Errors)
+	}
+	png, err := base64.StdEncoding.DecodeString(enrollment.QRCode)
+	if err != nil || !bytes.HasPrefix(png, []byte("\x89PNG")) {
+		t.Errorf("expected a base64 encoded PNG QR code, got %q (%v)", enrollment.QRCode, err)
+	}
+
+	// logins are unaffected until the app is confirmed
+	login(t, r, "jane@example.com", "Secret#123")
+	res, _ = doGraphQLRequest(t, r, confirmTotpMutation, map[string]interface{}{"code": "000000"}, withBearer(jane.AccessToken))
+	if len(res.Errors) != 1 || res.Errors[0].Extensions["code"] != "BAD_USER_INPUT" {
+		t.Errorf("expected an invalid code to be refused, got %+v", res.Errors)
+	}
+	confirmCode := totpCode(t, enrollment.Secret, -1)
+	res, _ = doGraphQLRequest(t, r, confirmTotpMutation, map[string]interface{}{"code": confirmCode}, withBearer(jane.AccessToken))
+	if len(res.Errors) > 0 {
+		t.Fatalf("confirmTotp failed: %+v", res.Errors)
+	}
+	res, _ = doGraphQLRequest(t, r, enrollTotpMutation, nil, withBearer(jane.AccessToken))
+	if len(res.Errors) != 1 || res.Errors[0].Extensions["code"] != "CONFLICT" {
+		t.Errorf("expected a second enrollment to conflict, got %+v", res.Errors)
+	}
+
+	pending := mfaLogin(t, r, "jane@example.com", "Secret#123")
+	if pending.TotpEnrollment != nil {
+		t.Error("expected no enrollment for users with an authenticator app")
+	}
+	if _, res := verifyTotp(t, r, *pending.MfaToken, confirmCode); len(res.Errors) != 1 {
+		t.Error("expected the code used to confirm the app to be refused")
+	}
+	verified, res := verifyTotp(t, r, *pending.MfaToken, totpCode(t, enrollment.Secret, 0))
+	if len(res.Errors) > 0 || verified.AccessToken == nil {
+		t.Fatalf("verifyTotp failed: %s %+v", res.Data, res.Errors)
+	}
+	claims, err := resolver.Tokens.ParseToken(*verified.AccessToken, token.TypeAccessToken)
+	if err != nil {
+		t.Fatalf("ParseToken failed: %v", err)
+	}
+	session, err := resolver.Sessions.GetSession(context.Background(), claims.SessionID)
+	if err != nil || strings.Join(session.AMR, " ") != "pwd otp" {
+		t.Errorf("expected the session to record both factors, got %+v (%v)", session, err)
+	}
+	if _, res := verifyTotp(t, r, *pending.MfaToken, totpCode(t, enrollment.Secret, 1)); len(res.Errors) != 1 {
+		t.Error("expected the mfa token to be single use")
+	}
+}
+
+func TestTOTPRecoveryCodes(t *testing.T) {
+	resolver := setupResolver(t, testConfig(t))
+	r := newGraphQLRouter(resolver)
+	doGraphQL(t, r, signupMutation, signupInput("jane@example.com", "Secret#123", "Secret#123"))
+	jane := login(t, r, "jane@example.com", "Secret#123")
+	_, recoveryCodes := enrollTotp(t, r, jane.AccessToken)
+
+	pending := mfaLogin(t, r, "jane@example.com", "Secret#123")
+	verified, res := verifyTotp(t, r, *pending.MfaToken, strings.ToUpper(recoveryCodes[0]))
+	if len(res.Errors) > 0 || verified.AccessToken == nil {
+		t.Fatalf("expected a recovery code to log in, got %+v", res.Errors)
+	}
+	pending = mfaLogin(t, r, "jane@example.com", "Secret#123")
+	if _, res := verifyTotp(t, r, *pending.MfaToken, recoveryCodes[0]); len(res.Errors) != 1 {
+		t.Error("expected recovery codes to be single use")
+	}
+
+	// recovery codes also remove the authenticator app of users who lost it
+	res, _ = doGraphQLRequest(t, r, disableTotpMutation, map[string]interface{}{"code": recoveryCodes[1]}, withBearer(*verified.AccessToken))
+	if len(res.Errors) > 0 {
+		t.Fatalf("disableTotp failed: %+v", res.Errors)
+	}
+	login(t, r, "jane@example.com", "Secret#123")
+}
+
+func TestTOTPConcurrentCodes(t *testing.T) {
+	resolver := setupResolver(t, testConfig(t))
+	r := newGraphQLRouter(resolver)
+	doGraphQL(t, r, signupMutation, signupInput("jane@example.com", "Secret#123", "Secret#123"))
+	secret, recoveryCodes := enrollTotp(t, r, login(t, r, "jane@example.com", "Secret#123").AccessToken)
+
+	// a code sent to several logins at once only completes one of them
+	for name, code := range map[string]string{"totp code": totpCode(t, secret, 0), "recovery code": recoveryCodes[0]} {
+		var pending []string
+		for i := 0; i < 4; i++ {
+			pending = append(pending, *mfaLogin(t, r, "jane@example.com", "Secret#123").MfaToken)
+		}
+		var wg sync.WaitGroup
+		var verified atomic.Int32
+		for _, mfaToken := range pending {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				res := doGraphQL(t, r, verifyTotpMutation, map[string]interface{}{"mfaToken": mfaToken, "code": code})
+				if len(res.Errors) == 0 {
+					verified.Add(1)
+				}
+			}()
+		}
+		wg.Wait()
+		if verified.Load() != 1 {
+			t.Errorf("%s: expected a single login to be completed, got %d", name, verified.Load())
+		}
+	}
+}
+
+func TestTOTPAttempts(t *testing.T) {
+	resolver := setupResolver(t, testConfig(t))
+	r := newGraphQLRouter(resolver)
+	doGraphQL(t, r, signupMutation, signupInput("jane@example.com", "Secret#123", "Secret#123"))
+	secret, _ := enrollTotp(t, r, login(t, r, "jane@example.com", "Secret#123").AccessToken)
+	guess := func(mfaToken string) {
+		t.Helper()
+		if _, res := verifyTotp(t, r, mfaToken, "000000"); len(res.Errors) != 1 || res.Errors[0].Extensions["code"] != "UNAUTHENTICATED" {
+			t.Fatalf("expected UNAUTHENTICATED, got %+v", res.Errors)
+		}
+	}
+
+	// invalid codes lock the login they are entered for
+	pending := mfaLogin(t, r, "jane@example.com", "Secret#123")
+	for i := 0; i < 5; i++ {
+		guess(*pending.MfaToken)
+	}
+	if _, res := verifyTotp(t, r, *pending.MfaToken, totpCode(t, secret, 0)); len(res.Errors) != 1 || !strings.Contains(res.Errors[0].Message, "too many") {
+		t.Errorf("expected the login to be locked after too many invalid codes, got %+v", res.Errors)
+	}
+
+	// other logins of the user are unaffected until the user wide limit
+	for i := 0; i < 9; i++ {
+		pending = mfaLogin(t, r, "jane@example.com", "Secret#123")
+		for j := 0; j < 5; j++ {
+			guess(*pending.MfaToken)
+		}
+	}
+	pending = mfaLogin(t, r, "jane@example.com", "Secret#123")
+	if _, res := verifyTotp(t, r, *pending.MfaToken, totpCode(t, secret, 0)); len(res.Errors) != 1 || !strings.Contains(res.Errors[0].Message, "too many") {
+		t.Errorf("expected the user to be locked out after too many invalid codes, got %+v", res.Errors)
+	}
+}
+
+func TestTOTPAttemptsPerLogin(t *testing.T) {
+	resolver := setupResolver(t, testConfig(t))
+	r := newGraphQLRouter(resolver)
+	doGraphQL(t, r, signupMutation, signupInput("jane@example.com", "Secret#123", "Secret#123"))
+	secret, _ := enrollTotp(t, r, login(t, r, "jane@example.com", "Secret#123").AccessToken)
+
+	// someone guessing codes with the password does not lock the user out
+	for i := 0; i < 2; i++ {
+		pending := mfaLogin(t, r, "jane@example.com", "Secret#123")
+		for j := 0; j < 6; j++ {
+			verifyTotp(t, r, *pending.MfaToken, "000000")
+		}
+	}
+	pending := mfaLogin(t, r, "jane@example.com", "Secret#123")
+	if verified, res := verifyTotp(t, r, *pending.MfaToken, totpCode(t, secret, 0)); len(res.Errors) > 0 || verified.AccessToken == nil {
+		t.Fatalf("expected the user to still log in, got %+v", res.Errors)
+	}
+}
+
+func TestTOTPAttemptsReset(t *testing.T) {
+	resolver := setupResolver(t, testConfig(t))
+	r := newGraphQLRouter(resolver)
+	doGraphQL(t, r, signupMutation, signupInput("jane@example.com", "Secret#123", "Secret#123"))
+	secret, _ := enrollTotp(t, r, login(t, r, "jane@example.com", "Secret#123").AccessToken)
+
+	// a valid code clears the invalid ones before it
+	pending := mfaLogin(t, r, "jane@example.com", "Secret#123")
+	for i := 0; i < 4; i++ {
+		if _, res := verifyTotp(t, r, *pending.MfaToken, "000000"); len(res.Errors) != 1 {
+			t.Fatalf("expected the invalid code to be refused, got %+v", res.Errors)
+		}
+	}
+	if verified, res := verifyTotp(t, r, *pending.MfaToken, totpCode(t, secret, 0)); len(res.Errors) > 0 || verified.AccessToken == nil {
+		t.Fatalf("expected the valid code to log in, got %+v", res.Errors)
+	}
+	pending = mfaLogin(t, r, "jane@example.com", "Secret#123")
+	for i := 0; i < 5; i++ {
+		if _, res := verifyTotp(t, r, *pending.MfaToken, "000000"); len(res.Errors) != 1 || res.Errors[0].Message != "invalid code" {
+			t.Fatalf("expected the attempts to start over, got %+v", res.Errors)
+		}
+	}
+}
+
+func TestMFAEnforced(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.EnforceMultiFactorAuthentication = true
+	cfg.AdminSecret = testAdminSecret
+	resolver := setupResolver(t, cfg)
+	r := newGraphQLRouter(resolver)
+
+	// new users enrol an authenticator app to complete their sign up
+	res := doGraphQL(t, r, mfaSignupMutation, signupInput("jane@example.com", "Secret#123", "Secret#123"))
+	var data struct{ Signup mfaAuthResponse }
+	_ = json.Unmarshal(res.Data, &data)
+	if len(res.Errors) > 0 || data.Signup.AccessToken != nil || data.Signup.MfaToken == nil || data.Signup.TotpEnrollment == nil {
+		t.Fatalf("expected the sign up to require an authenticator app, got %s %+v", res.Data, res.Errors)
+	}
+	secret := data.Signup.TotpEnrollment.Secret
+	verified, res := verifyTotp(t, r, *data.Signup.MfaToken, totpCode(t, secret, -1))
+	if len(res.Errors) > 0 || verified.AccessToken == nil || len(verified.RecoveryCodes) != 10 {
+		t.Fatalf("expected the enrollment to complete the sign up, got %s %+v", res.Data, res.Errors)
+	}
+
+	res, _ = doGraphQLRequest(t, r, disableTotpMutation, map[string]interface{}{"code": totpCode(t, secret, 0)}, withBearer(*verified.AccessToken))
+	if len(res.Errors) != 1 || res.Errors[0].Extensions["code"] != "FORBIDDEN" {
+		t.Errorf("expected the app not to be removable, got %+v", res.Errors)
+	}
+
+	// admins reset the app of users who lost it, they enrol a new one
+	jane, _ := resolver.DB.GetUserByEmail(context.Background(), "jane@example.com")
+	res, _ = doGraphQLRequest(t, r, adminUpdateUserMutation, map[string]interface{}{"params": map[string]interface{}{"id": jane.ID, "mfaEnabled": false}}, withAdminSecret(testAdminSecret))
+	if len(res.Errors) > 0 {
+		t.Fatalf("_updateUser failed: %+v", res.Errors)
+	}
+	if pending := mfaLogin(t, r, "jane@example.com", "Secret#123"); pending.TotpEnrollment == nil {
+		t.Error("expected a new enrollment after the reset")
+	}
+}
+
+func TestMFADisabled(t *testing.T) {
+	cfg := testConfig(t)
+	resolver := setupResolver(t, cfg)
+	r := newGraphQLRouter(resolver)
+	doGraphQL(t, r, signupMutation, signupInput("jane@example.com", "Secret#123", "Secret#123"))
+	jane := login(t, r, "jane@example.com", "Secret#123")
+	enrollTotp(t, r, jane.AccessToken)
+
+	for _, disable := range []*bool{&cfg.DisableMultiFactorAuthentication, &cfg.DisableTOTPLogin} {
+		*disable = true
+		login(t, r, "jane@example.com", "Secret#123")
+		res, _ := doGraphQLRequest(t, r, enrollTotpMutation, nil, withBearer(jane.AccessToken))
+		if len(res.Errors) != 1 || res.Errors[0].Extensions["code"] != "FORBIDDEN" {
+			t.Errorf("expected FORBIDDEN, got %+v", res.Errors)
+		}
+		*disable = false
+	}
+
+	// the enrolled app is kept
+	user, _ := resolver.DB.GetUserByEmail(context.Background(), "jane@example.com")
+	if !user.IsTOTPEnabled() {
+		t.Error("expected the authenticator app to be kept")
+	}
+	mfaLogin(t, r, "jane@example.com", "Secret#123")
+}
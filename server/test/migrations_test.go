@@ -0,0 +1,69 @@
+package test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"server/database/sql"
+)
+
+func TestSQLMigrator(t *testing.T) {
+	ctx := context.Background()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "migrate.db")), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("failed to open sqlite: %v", err)
+	}
+	migrator := sql.NewMigrator(db)
+
+	statuses, err := migrator.Status(ctx)
+	if err != nil {
+		t.Fatalf("Status failed: %v", err)
+	}
+	if len(statuses) == 0 {
+		t.Fatal("expected at least one known migration")
+	}
+	for _, s := range statuses {
+		if s.Applied {
+			t.Errorf("migration %d should be pending on a fresh database", s.Version)
+		}
+	}
+
+	applied, err := migrator.Up(ctx)
+	if err != nil {
+		t.Fatalf("Up failed: %v", err)
+	}
+	if len(applied) != len(statuses) {
+		t.Errorf("expected %d migrations applied, got %d", len(statuses), len(applied))
+	}
+	if !db.Migrator().HasTable("users") {
+		t.Error("expected users table to exist")
+	}
+
+	again, err := migrator.Up(ctx)
+	if err != nil || len(again) != 0 {
+		t.Errorf("second Up should be a no-op, got %d applied, %v", len(again), err)
+	}
+
+	// roll everything back one step at a time
+	for i := len(statuses) - 1; i >= 0; i-- {
+		reverted, err := migrator.Down(ctx)
+		if err != nil {
+			t.Fatalf("Down failed: %v", err)
+		}
+		if reverted == nil || reverted.Version != statuses[i].Version {
+			t.Fatalf("expected migration %d to be rolled back, got %+v", statuses[i].Version, reverted)
+		}
+	}
+	if db.Migrator().HasTable("users") {
+		t.Error("expected users table to be dropped")
+	}
+
+	reverted, err := migrator.Down(ctx)
+	if err != nil || reverted != nil {
+		t.Errorf("Down on an empty history should be a no-op, got %+v, %v", reverted, err)
+	}
+}
@@ -0,0 +1,401 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/sirupsen/logrus"
+
+	"server/config"
+	"server/cookie"
+	"server/crypto"
+	"server/database/models"
+	"server/graph"
+	"server/providers"
+	"server/routes"
+	"server/token"
+)
+
+const (
+	fakeClientID     = "fake-client"
+	fakeClientSecret = "fake-secret"
+	oauthRedirectURI = "http://localhost:8080/done"
+)
+
+// fakeAuthorization is an authorization request approved by the fake provider
+type fakeAuthorization struct {
+	RedirectURI   string
+	CodeChallenge string
+	Nonce         string
+}
+
+// fakeProvider is an OAuth 2.0 provider serving a token endpoint, an OpenID
+// Connect discovery document and userinfo endpoint, and the GitHub user API
+type fakeProvider struct {
+	*httptest.Server
+
+	mu    sync.Mutex
+	codes map[string]fakeAuthorization
+	// userInfo is served by /userinfo, and describes the subject of the ID tokens
+	userInfo map[string]interface{}
+	// githubUser and githubEmails are served by /user and /user/emails
+	githubUser   map[string]interface{}
+	githubEmails []map[string]interface{}
+	// idTokenNonce replaces the nonce of the ID tokens when set
+	idTokenNonce string
+}
+
+func startFakeProvider(t *testing.T) *fakeProvider {
+	t.Helper()
+
+	p := &fakeProvider{codes: map[string]fakeAuthorization{}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, req *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"issuer":                                p.URL,
+			"authorization_endpoint":                p.URL + "/authorize",
+			"token_endpoint":                        p.URL + "/token",
+			"userinfo_endpoint":                     p.URL + "/userinfo",
+			"token_endpoint_auth_methods_supported": []string{"client_secret_post"},
+		})
+	})
+	mux.HandleFunc("/token", p.token)
+	mux.HandleFunc("/userinfo", p.serveJSON(func() interface{} { return p.userInfo }))
+	mux.HandleFunc("/user", p.serveJSON(func() interface{} { return p.githubUser }))
+	mux.HandleFunc("/user/emails", p.serveJSON(func() interface{} { return p.githubEmails }))
+	p.Server = httptest.NewServer(mux)
+	t.Cleanup(p.Close)
+	return p
+}
+
+// approve plays the user logging in at the provider page of authURL and
+// returns the parameters the provider sends back to the callback
+func (p *fakeProvider) approve(t *testing.T, authURL *url.URL) url.Values {
+	t.Helper()
+
+	q := authURL.Query()
+	if q.Get("client_id") != fakeClientID || q.Get("response_type") != "code" || q.Get("code_challenge_method") != "S256" {
+		t.Fatalf("unexpected authorization request %s", authURL)
+	}
+	code, _ := crypto.GenerateToken()
+	p.mu.Lock()
+	p.codes[code] = fakeAuthorization{RedirectURI: q.Get("redirect_uri"), CodeChallenge: q.Get("code_challenge"), Nonce: q.Get("nonce")}
+	p.mu.Unlock()
+	return url.Values{"state": {q.Get("state")}, "code": {code}}
+}
+
+func (p *fakeProvider) token(w http.ResponseWriter, req *http.Request) {
+	_ = req.ParseForm()
+	p.mu.Lock()
+	auth, ok := p.codes[req.PostForm.Get("code")]
+	delete(p.codes, req.PostForm.Get("code"))
+	p.mu.Unlock()
+
+	switch {
+	case req.PostForm.Get("client_id") != fakeClientID || req.PostForm.Get("client_secret") != fakeClientSecret:
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+		return
+	case !ok || req.PostForm.Get("redirect_uri") != auth.RedirectURI || s256(req.PostForm.Get("code_verifier")) != auth.CodeChallenge:
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	res := map[string]interface{}{"access_token": "provider-access-token", "token_type": "Bearer"}
+	if auth.Nonce != "" {
+		nonce := auth.Nonce
+		if p.idTokenNonce != "" {
+			nonce = p.idTokenNonce
+		}
+		idToken, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+			"iss":   p.URL,
+			"sub":   p.userInfo["sub"],
+			"aud":   fakeClientID,
+			"exp":   time.Now().Add(time.Hour).Unix(),
+			"nonce": nonce,
+		}).SignedString([]byte("fake-provider-key"))
+		res["id_token"] = idToken
+	}
+	writeJSON(w, http.StatusOK, res)
+}
+
+func (p *fakeProvider) serveJSON(body func() interface{}) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		if req.Header.Get("Authorization") != "Bearer provider-access-token" {
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_token"})
+			return
+		}
+		writeJSON(w, http.StatusOK, body())
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// setupOAuthLogin returns a router whose google and github providers are
+// served by the fake provider
+func setupOAuthLogin(t *testing.T, resolver *graph.Resolver) (*gin.Engine, *fakeProvider) {
+	t.Helper()
+
+	fake := startFakeProvider(t)
+	fake.userInfo = map[string]interface{}{"sub": "google-1", "email": "Jane@Example.com", "email_verified": true, "name": "Jane"}
+	creds := config.OAuthProviderCredentials{ClientID: fakeClientID, ClientSecret: fakeClientSecret}
+
+	google := providers.NewGoogle(creds)
+	google.AuthURL, google.TokenURL, google.UserInfoURL = fake.URL+"/authorize", fake.URL+"/token", fake.URL+"/userinfo"
+	github := providers.NewGithub(creds)
+	github.AuthURL, github.TokenURL, github.UserInfoURL = fake.URL+"/authorize", fake.URL+"/token", fake.URL+"/user"
+	resolver.OAuthProviders = map[string]providers.OAuthProvider{"google": google, "github": github}
+
+	return routes.InitRouter(logrus.New(), resolver), fake
+}
+
+// startOAuthLogin calls /oauth_login and returns the provider page the user
+// is sent to and the state cookie
+func startOAuthLogin(t *testing.T, r http.Handler, provider string, query url.Values) (*url.URL, *http.Cookie) {
+	t.Helper()
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/oauth_login/"+provider+"?"+query.Encode(), nil))
+	u, _ := redirectParams(t, w, false)
+	for _, c := range w.Result().Cookies() {
+		if c.Name == cookie.OAuthStateName {
+			return u, c
+		}
+	}
+	t.Fatal("expected the state cookie")
+	return nil, nil
+}
+
+func oauthCallback(r http.Handler, provider string, params url.Values, c *http.Cookie) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/oauth_callback/"+provider+"?"+params.Encode(), nil)
+	if c != nil {
+		req.AddCookie(c)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+// oauthLogin runs a login through the provider and returns the callback response
+func oauthLogin(t *testing.T, r http.Handler, fake *fakeProvider, provider string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	authURL, c := startOAuthLogin(t, r, provider, url.Values{"redirect_uri": {oauthRedirectURI}})
+	return oauthCallback(r, provider, fake.approve(t, authURL), c)
+}
+
+func TestOAuthLoginSignsUp(t *testing.T) {
+	resolver := setupResolver(t, testConfig(t))
+	r, fake := setupOAuthLogin(t, resolver)
+
+	authURL, c := startOAuthLogin(t, r, "google", url.Values{"redirect_uri": {oauthRedirectURI}})
+	q := authURL.Query()
+	if q.Get("redirect_uri") != "http://localhost:8080/oauth_callback/google" || q.Get("state") != c.Value || q.Get("nonce") == "" {
+		t.Fatalf("unexpected authorization request %s", authURL)
+	}
+	if !c.HttpOnly || !c.Secure {
+		t.Errorf("expected an HttpOnly Secure state cookie, got %+v", c)
+	}
+
+	w := oauthCallback(r, "google", fake.approve(t, authURL), c)
+	u, params := redirectParams(t, w, true)
+	if u.String() != oauthRedirectURI+"#"+u.Fragment || params.Get("access_token") == "" || params.Get("refresh_token") == "" {
+		t.Fatalf("expected the tokens in the redirect, got %s", u)
+	}
+	if sessionCookie(w) == nil {
+		t.Error("expected the session cookie")
+	}
+
+	user, err := resolver.DB.GetUserByEmail(context.Background(), "jane@example.com")
+	if err != nil {
+		t.Fatalf("expected the user to be signed up: %v", err)
+	}
+	if user.Name != "Jane" || !user.IsEmailVerified() || user.Password != "" || strings.Join(user.RoleList(), ",") != "user" {
+		t.Errorf("unexpected user %+v", user)
+	}
+}
+
+func TestOAuthLoginLinksAccounts(t *testing.T) {
+	resolver := setupResolver(t, testConfig(t))
+	r, fake := setupOAuthLogin(t, resolver)
+	ctx := context.Background()
+
+	// accounts with a verified email are logged in and keep their password
+	doGraphQL(t, r, signupMutation, signupInput("jane@example.com", "Secret#123", "Secret#123"))
+	jane, _ := resolver.DB.GetUserByEmail(ctx, "jane@example.com")
+	w := oauthLogin(t, r, fake, "google")
+	_, params := redirectParams(t, w, true)
+	claims, err := resolver.Tokens.ParseToken(params.Get("access_token"), token.TypeAccessToken)
+	if err != nil || claims.Subject != jane.ID {
+		t.Fatalf("expected a session of the existing user, got %+v (%v)", claims, err)
+	}
+	login(t, r, "jane@example.com", "Secret#123")
+
+	// whoever signed up with an unverified email loses the account
+	hash, _ := crypto.HashPassword("Secret#123")
+	john, err := resolver.DB.CreateUser(ctx, &models.User{Email: "john@example.com", Password: hash, Roles: "user"})
+	if err != nil {
+		t.Fatalf("CreateUser failed: %v", err)
+	}
+	fake.userInfo = map[string]interface{}{"sub": "google-2", "email": "john@example.com", "email_verified": true}
+	_, params = redirectParams(t, oauthLogin(t, r, fake, "google"), true)
+	if params.Get("access_token") == "" {
+		t.Fatalf("expected the login to succeed, got %v", params)
+	}
+	john, _ = resolver.DB.GetUserByID(ctx, john.ID)
+	if !john.IsEmailVerified() || john.Password != "" {
+		t.Errorf("expected the email to be verified and the password dropped, got %+v", john)
+	}
+}
+
+func TestOAuthLoginMFA(t *testing.T) {
+	resolver := setupResolver(t, testConfig(t))
+	r, fake := setupOAuthLogin(t, resolver)
+	doGraphQL(t, r, signupMutation, signupInput("jane@example.com", "Secret#123", "Secret#123"))
+	secret, _ := enrollTotp(t, r, login(t, r, "jane@example.com", "Secret#123").AccessToken)
+
+	// the provider is a first factor only, the code is still asked for
+	w := oauthLogin(t, r, fake, "google")
+	_, params := redirectParams(t, w, true)
+	if params.Get("access_token") != "" || params.Get("mfa_required") != "true" || params.Get("mfa_token") == "" {
+		t.Fatalf("expected a TOTP code to be asked for, got %v", params)
+	}
+	for _, c := range w.Result().Cookies() {
+		if c.Name == cookie.SessionName && c.Value != "" {
+			t.Error("expected no session cookie before the code is verified")
+		}
+	}
+
+	verified, res := verifyTotp(t, r, params.Get("mfa_token"), totpCode(t, secret, 0))
+	if len(res.Errors) > 0 || verified.AccessToken == nil {
+		t.Fatalf("verifyTotp failed: %s %+v", res.Data, res.Errors)
+	}
+}
+
+func TestOAuthLoginRequiresVerifiedEmail(t *testing.T) {
+	cfg := testConfig(t)
+	resolver := setupResolver(t, cfg)
+	r, fake := setupOAuthLogin(t, resolver)
+
+	fake.userInfo["email_verified"] = false
+	_, params := redirectParams(t, oauthLogin(t, r, fake, "google"), true)
+	if params.Get("error") != "access_denied" || params.Get("access_token") != "" {
+		t.Errorf("expected unverified emails to be refused, got %v", params)
+	}
+	if _, err := resolver.DB.GetUserByEmail(context.Background(), "jane@example.com"); err == nil {
+		t.Error("expected no user to be created")
+	}
+
+	fake.userInfo["email_verified"] = true
+	cfg.DisableSignUp = true
+	_, params = redirectParams(t, oauthLogin(t, r, fake, "google"), true)
+	if params.Get("error") != "access_denied" || params.Get("error_description") != graph.ErrSignUpDisabled.Error() {
+		t.Errorf("expected new users to be refused with DISABLE_SIGN_UP, got %v", params)
+	}
+}
+
+func TestOAuthLoginState(t *testing.T) {
+	resolver := setupResolver(t, testConfig(t))
+	r, fake := setupOAuthLogin(t, resolver)
+
+	// the state must come back to the browser it was issued to
+	authURL, c := startOAuthLogin(t, r, "google", url.Values{"redirect_uri": {oauthRedirectURI}})
+	params := fake.approve(t, authURL)
+	if w := oauthCallback(r, "google", params, nil); w.Code != http.StatusBadRequest {
+		t.Errorf("expected callbacks without the state cookie to fail, got %d", w.Code)
+	}
+	authURL, c = startOAuthLogin(t, r, "google", url.Values{"redirect_uri": {oauthRedirectURI}})
+	params = fake.approve(t, authURL)
+	if w := oauthCallback(r, "github", params, &http.Cookie{Name: c.Name, Value: c.Value}); w.Code != http.StatusBadRequest {
+		t.Errorf("expected the state to be bound to the provider, got %d", w.Code)
+	}
+
+	// states are single use
+	authURL, c = startOAuthLogin(t, r, "google", url.Values{"redirect_uri": {oauthRedirectURI}})
+	params = fake.approve(t, authURL)
+	redirectParams(t, oauthCallback(r, "google", params, c), true)
+	if w := oauthCallback(r, "google", params, c); w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "invalid_request") {
+		t.Errorf("expected a replayed state to fail, got %d %s", w.Code, w.Body.String())
+	}
+
+	// the ID token must carry the nonce of the authorization request
+	fake.idTokenNonce = "another-nonce"
+	_, params = redirectParams(t, oauthLogin(t, r, fake, "google"), true)
+	if params.Get("error") != "access_denied" {
+		t.Errorf("expected a nonce mismatch to fail, got %v", params)
+	}
+
+	// errors of the provider are sent to the redirect URI
+	authURL, c = startOAuthLogin(t, r, "google", url.Values{"redirect_uri": {oauthRedirectURI}})
+	params = url.Values{"state": {authURL.Query().Get("state")}, "error": {"access_denied"}}
+	_, params = redirectParams(t, oauthCallback(r, "google", params, c), true)
+	if params.Get("error") != "access_denied" {
+		t.Errorf("expected the provider error to be forwarded, got %v", params)
+	}
+}
+
+func TestOAuthLoginRequest(t *testing.T) {
+	resolver := setupResolver(t, testConfig(t))
+	r, _ := setupOAuthLogin(t, resolver)
+
+	for path, status := range map[string]int{
+		"/oauth_login/twitter": http.StatusNotFound,
+		"/oauth_login/google?redirect_uri=https://evil.example.com": http.StatusBadRequest,
+		"/oauth_login/google?roles=root":                            http.StatusBadRequest,
+	} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		if w.Code != status {
+			t.Errorf("%s: expected %d, got %d", path, status, w.Code)
+		}
+	}
+}
+
+func TestOAuthLoginGithubProfile(t *testing.T) {
+	resolver := setupResolver(t, testConfig(t))
+	r, fake := setupOAuthLogin(t, resolver)
+
+	fake.githubUser = map[string]interface{}{"id": 42, "login": "octocat", "name": nil, "email": "public@example.com"}
+	fake.githubEmails = []map[string]interface{}{
+		{"email": "public@example.com", "primary": false, "verified": false},
+		{"email": "octo@example.com", "primary": true, "verified": true},
+	}
+	_, params := redirectParams(t, oauthLogin(t, r, fake, "github"), true)
+	if params.Get("access_token") == "" {
+		t.Fatalf("expected the login to succeed, got %v", params)
+	}
+	user, err := resolver.DB.GetUserByEmail(context.Background(), "octo@example.com")
+	if err != nil || user.Name != "octocat" {
+		t.Errorf("expected the primary email and the login as name, got %+v (%v)", user, err)
+	}
+}
+
+func TestOAuthProvidersFromConfig(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.OAuthProviders = map[string]config.OAuthProviderCredentials{
+		"google":    {ClientID: "google-client"},
+		"microsoft": {ClientID: "microsoft-client"},
+	}
+	cfg.MicrosoftTenantID = "contoso"
+
+	enabled := providers.FromConfig(cfg)
+	if len(enabled) != 2 || enabled["google"] == nil {
+		t.Fatalf("expected the configured providers, got %v", enabled)
+	}
+	authURL := enabled["microsoft"].AuthCodeURL("http://localhost:8080/oauth_callback/microsoft", "state", "nonce", "challenge")
+	if !strings.HasPrefix(authURL, "https://login.microsoftonline.com/contoso/") || !strings.Contains(authURL, "nonce=nonce") {
+		t.Errorf("unexpected microsoft authorization URL %s", authURL)
+	}
+}
@@ -0,0 +1,215 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+
+	"server/routes"
+)
+
+const (
+	addOIDCProviderMutation = `mutation($params: AddOIDCProviderInput!) {
+		_addOIDCProvider(params: $params) { id name issuer clientId scopes claimMapping { email emailVerified name } }
+	}`
+	updateOIDCProviderMutation = `mutation($params: UpdateOIDCProviderInput!) {
+		_updateOIDCProvider(params: $params) { name scopes claimMapping { email emailVerified name } }
+	}`
+	deleteOIDCProviderMutation = `mutation($name: String!) { _deleteOIDCProvider(name: $name) { message } }`
+	oidcProvidersQuery         = `query { _oidcProviders { name issuer clientId } }`
+)
+
+type oidcProviderData struct {
+	ID           string   `json:"id"`
+	Name         string   `json:"name"`
+	Issuer       string   `json:"issuer"`
+	ClientID     string   `json:"clientId"`
+	Scopes       []string `json:"scopes"`
+	ClaimMapping struct {
+		Email         string `json:"email"`
+		EmailVerified string `json:"emailVerified"`
+		Name          string `json:"name"`
+	} `json:"claimMapping"`
+}
+
+func addOIDCProviderInput(name, issuer string) map[string]interface{} {
+	return map[string]interface{}{"params": map[string]interface{}{
+		"name":         name,
+		"issuer":       issuer,
+		"clientId":     fakeClientID,
+		"clientSecret": fakeClientSecret,
+	}}
+}
+
+func TestOIDCProviderAdmin(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.AdminSecret = testAdminSecret
+	resolver := setupResolver(t, cfg)
+	r := newGraphQLRouter(resolver)
+	fake := startFakeProvider(t)
+	admin := withAdminSecret(testAdminSecret)
+
+	res, _ := doGraphQLRequest(t, r, addOIDCProviderMutation, addOIDCProviderInput("acme", fake.URL), nil)
+	if len(res.Errors) != 1 || res.Errors[0].Extensions["code"] != "UNAUTHENTICATED" {
+		t.Fatalf("expected the admin secret to be required, got %+v", res.Errors)
+	}
+
+	res, _ = doGraphQLRequest(t, r, addOIDCProviderMutation, addOIDCProviderInput("acme", fake.URL), admin)
+	var added struct {
+		Provider oidcProviderData `json:"_addOIDCProvider"`
+	}
+	_ = json.Unmarshal(res.Data, &added)
+	if len(res.Errors) > 0 || added.Provider.ID == "" || added.Provider.Issuer != fake.URL || len(added.Provider.Scopes) != 3 || added.Provider.ClaimMapping.Email != "email" {
+		t.Fatalf("_addOIDCProvider returned %s %+v", res.Data, res.Errors)
+	}
+	res, _ = doGraphQLRequest(t, r, addOIDCProviderMutation, addOIDCProviderInput("acme", fake.URL), admin)
+	if len(res.Errors) != 1 || res.Errors[0].Extensions["code"] != "CONFLICT" {
+		t.Errorf("expected duplicate names to conflict, got %+v", res.Errors)
+	}
+	for name, input := range map[string]map[string]interface{}{
+		"builtin name":    addOIDCProviderInput("google", fake.URL),
+		"invalid name":    addOIDCProviderInput("Acme Corp", fake.URL),
+		"relative issuer": addOIDCProviderInput("other", "/issuer"),
+		"http issuer":     addOIDCProviderInput("other", "http://idp.example.com"),
+		"unknown issuer":  addOIDCProviderInput("other", fake.URL+"/unknown"),
+	} {
+		res, _ = doGraphQLRequest(t, r, addOIDCProviderMutation, input, admin)
+		if len(res.Errors) != 1 || res.Errors[0].Extensions["code"] != "BAD_USER_INPUT" {
+			t.Errorf("%s: expected BAD_USER_INPUT, got %+v", name, res.Errors)
+		}
+	}
+
+	res, _ = doGraphQLRequest(t, r, updateOIDCProviderMutation, map[string]interface{}{"params": map[string]interface{}{
+		"name":         "acme",
+		"scopes":       []string{"openid", "email"},
+		"claimMapping": map[string]interface{}{"email": "mail"},
+	}}, admin)
+	var updated struct {
+		Provider oidcProviderData `json:"_updateOIDCProvider"`
+	}
+	_ = json.Unmarshal(res.Data, &updated)
+	if len(res.Errors) > 0 || len(updated.Provider.Scopes) != 2 || updated.Provider.ClaimMapping.Email != "mail" || updated.Provider.ClaimMapping.Name != "name" {
+		t.Fatalf("_updateOIDCProvider returned %s %+v", res.Data, res.Errors)
+	}
+	stored, _ := resolver.DB.GetOIDCProviderByName(context.Background(), "acme")
+	if stored == nil || stored.ClientSecret != fakeClientSecret {
+		t.Errorf("expected the client secret to be kept, got %+v", stored)
+	}
+
+	res, _ = doGraphQLRequest(t, r, oidcProvidersQuery, nil, admin)
+	var list struct {
+		Providers []oidcProviderData `json:"_oidcProviders"`
+	}
+	_ = json.Unmarshal(res.Data, &list)
+	if len(res.Errors) > 0 || len(list.Providers) != 1 || list.Providers[0].ClientID != fakeClientID {
+		t.Fatalf("_oidcProviders returned %s %+v", res.Data, res.Errors)
+	}
+
+	res, _ = doGraphQLRequest(t, r, deleteOIDCProviderMutation, map[string]interface{}{"name": "acme"}, admin)
+	if len(res.Errors) > 0 {
+		t.Fatalf("_deleteOIDCProvider failed: %+v", res.Errors)
+	}
+	res, _ = doGraphQLRequest(t, r, deleteOIDCProviderMutation, map[string]interface{}{"name": "acme"}, admin)
+	if len(res.Errors) != 1 || res.Errors[0].Extensions["code"] != "NOT_FOUND" {
+		t.Errorf("expected deleted providers to be gone, got %+v", res.Errors)
+	}
+}
+
+func TestOIDCProviderDiscoveryEndpoints(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.AdminSecret = testAdminSecret
+	r := newGraphQLRouter(setupResolver(t, cfg))
+	admin := withAdminSecret(testAdminSecret)
+
+	var tokenEndpoint atomic.Value
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"issuer":                 server.URL,
+			"authorization_endpoint": server.URL + "/authorize",
+			"token_endpoint":         tokenEndpoint.Load(),
+		})
+	}))
+	t.Cleanup(server.Close)
+	expect := func(name string, res graphQLResponse, code string) {
+		t.Helper()
+		if code == "" && len(res.Errors) > 0 {
+			t.Fatalf("%s failed: %+v", name, res.Errors)
+		}
+		if code != "" && (len(res.Errors) != 1 || res.Errors[0].Extensions["code"] != code) {
+			t.Fatalf("%s: expected %s, got %+v", name, code, res.Errors)
+		}
+	}
+	update := map[string]interface{}{"params": map[string]interface{}{"name": "acme"}}
+
+	tokenEndpoint.Store("http://idp.example.com/token")
+	res, _ := doGraphQLRequest(t, r, addOIDCProviderMutation, addOIDCProviderInput("acme", server.URL), admin)
+	expect("http token endpoint", res, "BAD_USER_INPUT")
+
+	tokenEndpoint.Store(server.URL + "/token")
+	res, _ = doGraphQLRequest(t, r, addOIDCProviderMutation, addOIDCProviderInput("acme", server.URL), admin)
+	expect("_addOIDCProvider", res, "")
+
+	// updates and deletes fetch the discovery document again
+	tokenEndpoint.Store("http://idp.example.com/token")
+	res, _ = doGraphQLRequest(t, r, updateOIDCProviderMutation, update, admin)
+	expect("update after the endpoint changed", res, "BAD_USER_INPUT")
+	tokenEndpoint.Store(server.URL + "/token")
+	res, _ = doGraphQLRequest(t, r, updateOIDCProviderMutation, update, admin)
+	expect("_updateOIDCProvider", res, "")
+	res, _ = doGraphQLRequest(t, r, deleteOIDCProviderMutation, map[string]interface{}{"name": "acme"}, admin)
+	expect("_deleteOIDCProvider", res, "")
+	tokenEndpoint.Store("http://idp.example.com/token")
+	res, _ = doGraphQLRequest(t, r, addOIDCProviderMutation, addOIDCProviderInput("acme", server.URL), admin)
+	expect("add after delete", res, "BAD_USER_INPUT")
+}
+
+func TestOIDCProviderLogin(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.AdminSecret = testAdminSecret
+	resolver := setupResolver(t, cfg)
+	r := routes.InitRouter(logrus.New(), resolver)
+	fake := startFakeProvider(t)
+	fake.userInfo = map[string]interface{}{
+		"sub":     "acme-1",
+		"mail":    "jane@acme.example.com",
+		"profile": map[string]interface{}{"display_name": "Jane", "mail_verified": "true"},
+	}
+
+	input := addOIDCProviderInput("acme", fake.URL)
+	input["params"].(map[string]interface{})["claimMapping"] = map[string]interface{}{
+		"email":         "mail",
+		"emailVerified": "profile.mail_verified",
+		"name":          "profile.display_name",
+	}
+	res, _ := doGraphQLRequest(t, r, addOIDCProviderMutation, input, withAdminSecret(testAdminSecret))
+	if len(res.Errors) > 0 {
+		t.Fatalf("_addOIDCProvider failed: %+v", res.Errors)
+	}
+
+	authURL, c := startOAuthLogin(t, r, "acme", url.Values{"redirect_uri": {oauthRedirectURI}})
+	if authURL.String() != fake.URL+"/authorize?"+authURL.RawQuery || authURL.Query().Get("scope") != "openid email profile" {
+		t.Fatalf("unexpected authorization request %s", authURL)
+	}
+	_, params := redirectParams(t, oauthCallback(r, "acme", fake.approve(t, authURL), c), true)
+	if params.Get("access_token") == "" {
+		t.Fatalf("expected the login to succeed, got %v", params)
+	}
+	user, err := resolver.DB.GetUserByEmail(context.Background(), "jane@acme.example.com")
+	if err != nil || user.Name != "Jane" || !user.IsEmailVerified() {
+		t.Errorf("expected the user to be read with the claim mapping, got %+v (%v)", user, err)
+	}
+
+	doGraphQLRequest(t, r, deleteOIDCProviderMutation, map[string]interface{}{"name": "acme"}, withAdminSecret(testAdminSecret))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/oauth_login/acme", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected deleted providers to be unknown, got %d", w.Code)
+	}
+}
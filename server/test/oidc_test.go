@@ -0,0 +1,170 @@
+package test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"server/config"
+	"server/token"
+)
+
+// oidcConfig returns the test configuration signing with RS256, as ID tokens
+// require, and the PEM public key verifying them
+func oidcConfig(t *testing.T) (*config.Config, string) {
+	t.Helper()
+
+	private, public := rsaKeyPEM(t)
+	cfg := testConfig(t)
+	cfg.JwtType, cfg.JwtPrivateKey = "RS256", private
+	return cfg, public
+}
+
+// parseIDToken verifies an ID token signed by the key of oidcConfig
+func parseIDToken(t *testing.T, publicKey, idToken string) jwt.MapClaims {
+	t.Helper()
+
+	key, err := jwt.ParseRSAPublicKeyFromPEM([]byte(publicKey))
+	if err != nil {
+		t.Fatalf("invalid public key: %v", err)
+	}
+	claims := jwt.MapClaims{}
+	_, err = jwt.ParseWithClaims(idToken, claims, func(*jwt.Token) (interface{}, error) {
+		return key, nil
+	}, jwt.WithIssuer("http://localhost:8080"), jwt.WithAudience("spa"), jwt.WithExpirationRequired())
+	if err != nil {
+		t.Fatalf("invalid ID token: %v", err)
+	}
+	return claims
+}
+
+// exchangeSPACode runs the authorization code flow of the spa client
+func exchangeSPACode(t *testing.T, r http.Handler, c *http.Cookie, scope, nonce string) map[string]interface{} {
+	t.Helper()
+
+	request := spaCodeRequest()
+	request.Set("scope", scope)
+	request.Set("nonce", nonce)
+	_, params := redirectParams(t, authorize(r, request, c), false)
+	w, body := postTokenRequest(r, url.Values{"grant_type": {"authorization_code"}, "code": {params.Get("code")}, "client_id": {"spa"}, "code_verifier": {codeVerifier}})
+	if w.Code != http.StatusOK {
+		t.Fatalf("code exchange failed: %d %v", w.Code, body)
+	}
+	return body
+}
+
+func getUserInfo(r http.Handler, prepare func(*http.Request)) (*httptest.ResponseRecorder, map[string]interface{}) {
+	req := httptest.NewRequest(http.MethodGet, "/userinfo", nil)
+	if prepare != nil {
+		prepare(req)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	body := map[string]interface{}{}
+	_ = json.Unmarshal(w.Body.Bytes(), &body)
+	return w, body
+}
+
+func TestIDToken(t *testing.T) {
+	cfg, publicKey := oidcConfig(t)
+	resolver := setupResolver(t, cfg)
+	r, l := setupAuthorize(t, resolver)
+	loggedInAt := time.Now().Unix()
+
+	body := exchangeSPACode(t, r, l.Cookie, "openid email profile", "n-0S6_WzA2Mj")
+	idToken, _ := body["id_token"].(string)
+	claims := parseIDToken(t, publicKey, idToken)
+	if claims["sub"] == "" || claims["nonce"] != "n-0S6_WzA2Mj" || claims["email"] != "jane@example.com" || claims["email_verified"] != true || claims["name"] != "Jane" {
+		t.Errorf("unexpected ID token claims %v", claims)
+	}
+	if authTime, _ := claims["auth_time"].(float64); int64(authTime) < loggedInAt-5 || int64(authTime) > loggedInAt {
+		t.Errorf("auth_time must be the time of the login, got %v", claims["auth_time"])
+	}
+	if amr, _ := claims["amr"].([]interface{}); len(amr) != 1 || amr[0] != "pwd" {
+		t.Errorf("expected amr [pwd], got %v", claims["amr"])
+	}
+	if _, err := resolver.Tokens.ParseToken(idToken, token.TypeAccessToken); err == nil {
+		t.Error("ID tokens must not be accepted as access tokens")
+	}
+
+	claims = parseIDToken(t, publicKey, exchangeSPACode(t, r, l.Cookie, "openid", "")["id_token"].(string))
+	if _, ok := claims["email"]; ok {
+		t.Errorf("claims must be filtered by scope, got %v", claims)
+	}
+	if _, ok := claims["nonce"]; ok {
+		t.Errorf("nonce must only be set when requested, got %v", claims)
+	}
+
+	if body = exchangeSPACode(t, r, l.Cookie, "email profile", ""); body["id_token"] != nil {
+		t.Error("ID tokens must only be issued for the openid scope")
+	}
+
+	// the implicit flow returns the ID token along with the access token
+	request := url.Values{"client_id": {"spa"}, "response_type": {"token"}, "scope": {"openid"}, "nonce": {"abc"}}
+	_, params := redirectParams(t, authorize(r, request, l.Cookie), true)
+	if claims = parseIDToken(t, publicKey, params.Get("id_token")); claims["nonce"] != "abc" {
+		t.Errorf("unexpected implicit ID token claims %v", claims)
+	}
+}
+
+func TestUserInfo(t *testing.T) {
+	cfg, _ := oidcConfig(t)
+	resolver := setupResolver(t, cfg)
+	r, l := setupAuthorize(t, resolver)
+
+	body := exchangeSPACode(t, r, l.Cookie, "openid email", "")
+	accessToken, _ := body["access_token"].(string)
+	w, info := getUserInfo(r, withBearer(accessToken))
+	if w.Code != http.StatusOK || info["sub"] == "" || info["email"] != "jane@example.com" || info["email_verified"] != true {
+		t.Fatalf("unexpected userinfo %d %v", w.Code, info)
+	}
+	if _, ok := info["name"]; ok {
+		t.Errorf("profile claims require the profile scope, got %v", info)
+	}
+
+	// GraphQL logins are not granted openid
+	if w, info = getUserInfo(r, withBearer(l.AccessToken)); w.Code != http.StatusForbidden || info["error"] != "insufficient_scope" {
+		t.Errorf("expected insufficient_scope, got %d %v", w.Code, info)
+	}
+	for name, prepare := range map[string]func(*http.Request){
+		"anonymous": nil,
+		"cookie":    withCookie(l.Cookie),
+		"id token":  withBearer(body["id_token"].(string)),
+	} {
+		if w, _ := getUserInfo(r, prepare); w.Code != http.StatusUnauthorized || w.Header().Get("WWW-Authenticate") == "" {
+			t.Errorf("%s: expected 401 with WWW-Authenticate, got %d", name, w.Code)
+		}
+	}
+}
+
+func TestIDTokensRequireAsymmetricKeys(t *testing.T) {
+	resolver := setupResolver(t, testConfig(t))
+	r, l := setupAuthorize(t, resolver)
+
+	// clients could only verify HS256 ID tokens with the server secret
+	request := spaCodeRequest()
+	request.Set("scope", "openid email")
+	if _, params := redirectParams(t, authorize(r, request, l.Cookie), false); params.Get("error") != "invalid_scope" {
+		t.Errorf("expected the openid scope to be refused, got %v", params)
+	}
+	request.Del("scope")
+	_, params := redirectParams(t, authorize(r, request, l.Cookie), false)
+	w, body := postTokenRequest(r, url.Values{"grant_type": {"authorization_code"}, "code": {params.Get("code")}, "client_id": {"spa"}, "code_verifier": {codeVerifier}})
+	accessToken, _ := body["access_token"].(string)
+	claims, err := resolver.Tokens.ParseToken(accessToken, token.TypeAccessToken)
+	if w.Code != http.StatusOK || body["id_token"] != nil || err != nil || claims.Scope != "email profile" {
+		t.Errorf("expected the default scope without openid, got %d %v", w.Code, body)
+	}
+
+	var doc map[string]interface{}
+	getJSON(t, r, "/.well-known/openid-configuration", &doc)
+	if scopes, _ := doc["scopes_supported"].([]interface{}); len(scopes) != 2 || doc["id_token_signing_alg_values_supported"] != nil {
+		t.Errorf("expected no ID tokens to be advertised, got %v", doc)
+	}
+}
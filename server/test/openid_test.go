@@ -0,0 +1,169 @@
+package test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/sirupsen/logrus"
+
+	"server/database/models"
+	"server/routes"
+	"server/token"
+)
+
+func getJSON(t *testing.T, r http.Handler, path string, v interface{}) *httptest.ResponseRecorder {
+	t.Helper()
+
+	req, _ := http.NewRequest(http.MethodGet, path, nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET %s returned %d: %s", path, w.Code, w.Body.String())
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), v); err != nil {
+		t.Fatalf("invalid JSON from %s: %v", path, err)
+	}
+	return w
+}
+
+// jwkPublicKey rebuilds the public key of a JWK the way a resource server would
+func jwkPublicKey(t *testing.T, jwk token.JSONWebKey) interface{} {
+	t.Helper()
+
+	decode := func(s string) *big.Int {
+		b, err := base64.RawURLEncoding.DecodeString(s)
+		if err != nil {
+			t.Fatalf("invalid base64url %q: %v", s, err)
+		}
+		return new(big.Int).SetBytes(b)
+	}
+	switch jwk.Kty {
+	case "RSA":
+		return &rsa.PublicKey{N: decode(jwk.N), E: int(decode(jwk.E).Int64())}
+	case "EC":
+		curves := map[string]elliptic.Curve{"P-256": elliptic.P256(), "P-384": elliptic.P384(), "P-521": elliptic.P521()}
+		return &ecdsa.PublicKey{Curve: curves[jwk.Crv], X: decode(jwk.X), Y: decode(jwk.Y)}
+	}
+	t.Fatalf("unexpected kty %q", jwk.Kty)
+	return nil
+}
+
+func TestJWKSVerifiesTokens(t *testing.T) {
+	rsaPrivate, _ := rsaKeyPEM(t)
+	ecPrivate, _ := ecKeyPEM(t, elliptic.P384())
+	user := &models.User{ID: "user-id", Email: "jane@example.com", Roles: "user"}
+
+	for alg, private := range map[string]string{"RS256": rsaPrivate, "ES384": ecPrivate} {
+		t.Run(alg, func(t *testing.T) {
+			cfg := testConfig(t)
+			cfg.JwtType, cfg.JwtPrivateKey = alg, private
+			resolver := setupResolver(t, cfg)
+			r := routes.InitRouter(logrus.New(), resolver)
+
+			var jwks token.JSONWebKeySet
+			w := getJSON(t, r, "/.well-known/jwks.json", &jwks)
+			if w.Header().Get("Cache-Control") == "" {
+				t.Error("expected the JWKS to be cacheable")
+			}
+			if len(jwks.Keys) != 1 || jwks.Keys[0].Alg != alg || jwks.Keys[0].Use != "sig" || jwks.Keys[0].Kid == "" {
+				t.Fatalf("unexpected JWKS %+v", jwks)
+			}
+
+			tokens, err := resolver.Tokens.CreateAuthTokens(user)
+			if err != nil {
+				t.Fatalf("CreateAuthTokens failed: %v", err)
+			}
+			parsed, err := jwt.Parse(tokens.AccessToken, func(tok *jwt.Token) (interface{}, error) {
+				for _, key := range jwks.Keys {
+					if key.Kid == tok.Header["kid"] {
+						return jwkPublicKey(t, key), nil
+					}
+				}
+				t.Fatalf("no key for kid %v", tok.Header["kid"])
+				return nil, nil
+			}, jwt.WithValidMethods([]string{alg}))
+			if err != nil || !parsed.Valid {
+				t.Fatalf("token does not verify with the published key: %v", err)
+			}
+		})
+	}
+}
+
+func TestJWKSAdditionalKeys(t *testing.T) {
+	private, _ := rsaKeyPEM(t)
+	cfg := testConfig(t)
+	cfg.JwtType, cfg.JwtPrivateKey = "RS256", private
+	cfg.JWK = `{"keys":[{"kty":"EC","kid":"previous","crv":"P-256","x":"eA","y":"eQ","d":"secret"}]}`
+	m, err := token.NewManager(cfg)
+	if err != nil {
+		t.Fatalf("NewManager failed: %v", err)
+	}
+	jwks := m.JWKS()
+	if len(jwks.Keys) != 2 || jwks.Keys[1].Kid != "previous" {
+		t.Fatalf("expected the current and the previous key, got %+v", jwks.Keys)
+	}
+	body, _ := json.Marshal(jwks)
+	if containsKey(t, body, "d") {
+		t.Error("private members of JWK must not be published")
+	}
+
+	// a single key is accepted too
+	cfg.JWK = `{"kty":"RSA","kid":"single","n":"AQAB","e":"AQAB"}`
+	if m, err = token.NewManager(cfg); err != nil || len(m.JWKS().Keys) != 2 {
+		t.Fatalf("expected a single JWK to be published, got %v", err)
+	}
+
+	for _, invalid := range []string{`not json`, `{"keys":[{"kty":"RSA"}]}`} {
+		cfg.JWK = invalid
+		if _, err := token.NewManager(cfg); err == nil {
+			t.Errorf("expected JWK %q to be rejected", invalid)
+		}
+	}
+
+	// the HS* secret is never published
+	hs := testConfig(t)
+	m, _ = token.NewManager(hs)
+	if keys := m.JWKS().Keys; keys == nil || len(keys) != 0 {
+		t.Errorf("expected an empty key set for HS256, got %+v", keys)
+	}
+}
+
+func containsKey(t *testing.T, body []byte, member string) bool {
+	t.Helper()
+
+	var set struct{ Keys []map[string]interface{} }
+	_ = json.Unmarshal(body, &set)
+	for _, key := range set.Keys {
+		if _, ok := key[member]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+func TestOpenIDConfiguration(t *testing.T) {
+	private, _ := ecKeyPEM(t, elliptic.P256())
+	cfg := testConfig(t)
+	cfg.JwtType, cfg.JwtPrivateKey = "ES256", private
+	r := routes.InitRouter(logrus.New(), setupResolver(t, cfg))
+
+	var doc map[string]interface{}
+	getJSON(t, r, "/.well-known/openid-configuration", &doc)
+	if doc["issuer"] != cfg.AuthorizerURL {
+		t.Errorf("issuer must match the iss claim, got %v", doc["issuer"])
+	}
+	if doc["jwks_uri"] != "http://localhost:8080/.well-known/jwks.json" || doc["token_endpoint"] != "http://localhost:8080/oauth/token" {
+		t.Errorf("unexpected endpoints %v", doc)
+	}
+	if algs, _ := doc["id_token_signing_alg_values_supported"].([]interface{}); len(algs) != 1 || algs[0] != "ES256" {
+		t.Errorf("unexpected signing algorithms %v", doc["id_token_signing_alg_values_supported"])
+	}
+}
@@ -0,0 +1,80 @@
+package test
+
+import (
+	"sort"
+	"strings"
+	"testing"
+
+	"server/validators"
+)
+
+func violatedRules(violations []validators.PasswordViolation) string {
+	rules := make([]string, 0, len(violations))
+	for _, v := range violations {
+		rules = append(rules, v.Rule)
+	}
+	sort.Strings(rules)
+	return strings.Join(rules, ",")
+}
+
+func TestPasswordPolicy(t *testing.T) {
+	policy := validators.NewPasswordPolicy(testConfig(t))
+
+	cases := []struct {
+		password, rules string
+	}{
+		{"Secret#123", ""},
+		{"Sh#1", "min_length"},
+		{"secret#123", "uppercase"},
+		{"SECRET#123", "lowercase"},
+		{"Secret#abc", "digit"},
+		{"Secret1234", "special"},
+		{"abc", "digit,min_length,special,uppercase"},
+		{strings.Repeat("Aa1#", 19), "max_length"},
+		{"Aa1#" + strings.Repeat("é", 35), "max_length"},
+		{"Jane.Doe#1@Example.com", ""},
+	}
+	for _, c := range cases {
+		if got := violatedRules(policy.Validate(c.password, "jane@example.com", "Jane")); got != c.rules {
+			t.Errorf("%q: expected rules %q, got %q", c.password, c.rules, got)
+		}
+	}
+
+	policy.RequireSpecial, policy.RequireDigit, policy.RequireUppercase = false, false, false
+	if got := violatedRules(policy.Validate("jane@example.com", "jane@example.com", "Jane")); got != "not_email" {
+		t.Errorf("expected not_email, got %q", got)
+	}
+	if got := violatedRules(policy.Validate("janedoe1", "janedoe1@example.com", "Jane")); got != "not_email" {
+		t.Errorf("expected the email local part to be rejected, got %q", got)
+	}
+	if got := violatedRules(policy.Validate("jane smith", "js@example.com", "Jane Smith")); got != "not_name" {
+		t.Errorf("expected not_name, got %q", got)
+	}
+}
+
+func TestPasswordPolicyDisabled(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.DisableStrongPassword = true
+	policy := validators.NewPasswordPolicy(cfg)
+
+	if got := violatedRules(policy.Validate("abc", "jane@example.com", "Jane")); got != "" {
+		t.Errorf("expected no rule when disabled, got %q", got)
+	}
+	if got := violatedRules(policy.Validate(strings.Repeat("a", 73), "jane@example.com", "Jane")); got != "max_length" {
+		t.Errorf("the hashing limit must still apply, got %q", got)
+	}
+}
+
+func TestSignupWeakPassword(t *testing.T) {
+	r := newGraphQLRouter(setupResolver(t, testConfig(t)))
+
+	res := doGraphQL(t, r, signupMutation, signupInput("jane@example.com", "secret", "secret"))
+	if len(res.Errors) != 1 {
+		t.Fatalf("expected one error, got %+v", res.Errors)
+	}
+	ext := res.Errors[0].Extensions
+	rules, _ := ext["rules"].([]interface{})
+	if ext["code"] != "BAD_USER_INPUT" || ext["field"] != "password" || len(rules) != 4 {
+		t.Errorf("expected the 4 broken rules in the extensions, got %+v", ext)
+	}
+}
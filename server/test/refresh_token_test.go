@@ -0,0 +1,192 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"server/graph"
+	"server/routes"
+)
+
+const refreshTokenMutation = `mutation($refreshToken: String!) {
+  refreshToken(refreshToken: $refreshToken) { accessToken refreshToken expiresIn user { email } }
+}`
+
+// loginTokens signs Jane up and returns the refresh token of a new session
+func loginTokens(t *testing.T, r http.Handler) string {
+	t.Helper()
+
+	doGraphQL(t, r, signupMutation, signupInput("jane@example.com", "Secret#123", "Secret#123"))
+	res := doGraphQL(t, r, loginMutation, map[string]interface{}{"email": "jane@example.com", "password": "Secret#123"})
+	if len(res.Errors) > 0 {
+		t.Fatalf("login failed: %+v", res.Errors)
+	}
+	var data struct{ Login struct{ RefreshToken string } }
+	_ = json.Unmarshal(res.Data, &data)
+	return data.Login.RefreshToken
+}
+
+func refreshViaGraphQL(t *testing.T, r http.Handler, refreshToken string) (string, graphQLResponse) {
+	t.Helper()
+
+	res := doGraphQL(t, r, refreshTokenMutation, map[string]interface{}{"refreshToken": refreshToken})
+	var data struct {
+		RefreshToken *struct{ RefreshToken string }
+	}
+	_ = json.Unmarshal(res.Data, &data)
+	if data.RefreshToken == nil {
+		return "", res
+	}
+	return data.RefreshToken.RefreshToken, res
+}
+
+func TestRefreshTokenRotation(t *testing.T) {
+	r := newGraphQLRouter(setupResolver(t, testConfig(t)))
+	first := loginTokens(t, r)
+
+	second, res := refreshViaGraphQL(t, r, first)
+	if len(res.Errors) > 0 || second == "" || second == first {
+		t.Fatalf("expected a rotated refresh token, got %s %+v", res.Data, res.Errors)
+	}
+	third, res := refreshViaGraphQL(t, r, second)
+	if len(res.Errors) > 0 || third == "" {
+		t.Fatalf("expected the rotated token to be usable, got %+v", res.Errors)
+	}
+
+	// replaying a used token revokes the whole family
+	_, res = refreshViaGraphQL(t, r, first)
+	if len(res.Errors) != 1 || res.Errors[0].Extensions["code"] != "UNAUTHENTICATED" {
+		t.Fatalf("expected reuse to be rejected, got %+v", res.Errors)
+	}
+	if _, res = refreshViaGraphQL(t, r, third); len(res.Errors) != 1 {
+		t.Error("the latest token of a family must be revoked after reuse")
+	}
+}
+
+func TestRefreshTokenFamiliesAreIndependent(t *testing.T) {
+	resolver := setupResolver(t, testConfig(t))
+	r := newGraphQLRouter(resolver)
+	phone := loginTokens(t, r)
+	res := doGraphQL(t, r, loginMutation, map[string]interface{}{"email": "jane@example.com", "password": "Secret#123"})
+	var data struct{ Login struct{ RefreshToken string } }
+	_ = json.Unmarshal(res.Data, &data)
+	laptop := data.Login.RefreshToken
+
+	rotated, _ := refreshViaGraphQL(t, r, phone)
+	refreshViaGraphQL(t, r, phone)
+	if _, res := refreshViaGraphQL(t, r, rotated); len(res.Errors) != 1 {
+		t.Error("expected the reused family to be revoked")
+	}
+	if _, res := refreshViaGraphQL(t, r, laptop); len(res.Errors) > 0 {
+		t.Errorf("other sessions must not be affected, got %+v", res.Errors)
+	}
+
+	user, _ := resolver.DB.GetUserByEmail(context.Background(), "jane@example.com")
+	sessions, err := resolver.Sessions.ListUserSessions(context.Background(), user.ID)
+	// the sessions of the signup and of the laptop
+	if err != nil || len(sessions) != 2 {
+		t.Errorf("expected two live sessions, got %d (%v)", len(sessions), err)
+	}
+}
+
+func TestRefreshTokenRevokedSessions(t *testing.T) {
+	resolver := setupResolver(t, testConfig(t))
+	r := newGraphQLRouter(resolver)
+	refreshToken := loginTokens(t, r)
+
+	user, _ := resolver.DB.GetUserByEmail(context.Background(), "jane@example.com")
+	revokedAt := time.Now().Add(time.Minute).Unix()
+	user.SessionsRevokedAt = &revokedAt
+	if _, err := resolver.DB.UpdateUser(context.Background(), user); err != nil {
+		t.Fatalf("UpdateUser failed: %v", err)
+	}
+
+	if _, res := refreshViaGraphQL(t, r, refreshToken); len(res.Errors) != 1 {
+		t.Error("tokens issued before the sessions were revoked must be rejected")
+	}
+
+	if _, res := refreshViaGraphQL(t, r, "not-a-token"); len(res.Errors) != 1 {
+		t.Error("expected invalid tokens to be rejected")
+	}
+}
+
+func postTokenRequest(r http.Handler, form url.Values) (*httptest.ResponseRecorder, map[string]interface{}) {
+	req := httptest.NewRequest(http.MethodPost, "/oauth/token", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	body := map[string]interface{}{}
+	_ = json.Unmarshal(w.Body.Bytes(), &body)
+	return w, body
+}
+
+func TestTokenEndpointRefreshGrant(t *testing.T) {
+	r := routes.InitRouter(logrus.New(), setupResolver(t, testConfig(t)))
+	first := loginTokens(t, r)
+
+	w, body := postTokenRequest(r, url.Values{"grant_type": {"refresh_token"}, "refresh_token": {first}})
+	if w.Code != http.StatusOK || body["token_type"] != "Bearer" || body["access_token"] == nil || body["expires_in"] != float64(1800) {
+		t.Fatalf("unexpected token response %d %v", w.Code, body)
+	}
+	if w.Header().Get("Cache-Control") != "no-store" {
+		t.Error("token responses must not be cached")
+	}
+	second, _ := body["refresh_token"].(string)
+	if second == "" || second == first {
+		t.Errorf("expected a rotated refresh token, got %q", second)
+	}
+
+	w, body = postTokenRequest(r, url.Values{"grant_type": {"refresh_token"}, "refresh_token": {first}})
+	if w.Code != http.StatusBadRequest || body["error"] != "invalid_grant" {
+		t.Errorf("expected invalid_grant on reuse, got %d %v", w.Code, body)
+	}
+	w, body = postTokenRequest(r, url.Values{"grant_type": {"refresh_token"}, "refresh_token": {second}})
+	if w.Code != http.StatusBadRequest || body["error"] != "invalid_grant" {
+		t.Errorf("expected the family to be revoked, got %d %v", w.Code, body)
+	}
+
+	w, body = postTokenRequest(r, url.Values{"grant_type": {"password"}})
+	if w.Code != http.StatusBadRequest || body["error"] != "unsupported_grant_type" {
+		t.Errorf("expected unsupported_grant_type, got %d %v", w.Code, body)
+	}
+	w, body = postTokenRequest(r, url.Values{"grant_type": {"refresh_token"}})
+	if w.Code != http.StatusBadRequest || body["error"] != "invalid_request" {
+		t.Errorf("expected invalid_request, got %d %v", w.Code, body)
+	}
+}
+
+func TestRefreshTokenConcurrentUse(t *testing.T) {
+	resolver := setupResolver(t, testConfig(t))
+	refreshToken := loginTokens(t, newGraphQLRouter(resolver))
+
+	const requests = 10
+	var wg sync.WaitGroup
+	var succeeded atomic.Int32
+	for range requests {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, _, err := resolver.RefreshSession(context.Background(), refreshToken, ""); err == nil {
+				succeeded.Add(1)
+			} else if !errors.Is(err, graph.ErrInvalidRefreshToken) {
+				t.Errorf("RefreshSession failed: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := succeeded.Load(); n != 1 {
+		t.Errorf("expected the refresh token to be exchanged once, got %d", n)
+	}
+}
@@ -0,0 +1,212 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"server/token"
+)
+
+const (
+	forgotPasswordMutation = `mutation($email: String!) { forgotPassword(email: $email) { message } }`
+	resetPasswordMutation  = `mutation($token: String!, $password: String!, $confirmPassword: String!) {
+  resetPassword(token: $token, password: $password, confirmPassword: $confirmPassword) { message }
+}`
+	updatePasswordMutation = `mutation($oldPassword: String, $newPassword: String!, $confirmNewPassword: String!) {
+  updatePassword(oldPassword: $oldPassword, newPassword: $newPassword, confirmNewPassword: $confirmNewPassword) { message }
+}`
+)
+
+func resetPasswordInput(token, password string) map[string]interface{} {
+	return map[string]interface{}{"token": token, "password": password, "confirmPassword": password}
+}
+
+func TestResetPasswordFlow(t *testing.T) {
+	smtp := startFakeSMTP(t)
+	cfg := testConfig(t)
+	cfg.IsEmailServiceEnabled = true
+	cfg.DisableEmailVerification = true
+	cfg.SMTPHost, cfg.SMTPPort = smtp.Host, smtp.Port
+	cfg.ResetPasswordURL = "http://localhost:3000/reset-password"
+	cfg.ResetPasswordTokenExpiryTime = time.Hour
+	resolver := setupResolver(t, cfg)
+	r := newGraphQLRouter(resolver)
+
+	res := doGraphQL(t, r, signupMutation, signupInput("jane@example.com", "Secret#123", "Secret#123"))
+	if len(res.Errors) > 0 {
+		t.Fatalf("signup failed: %+v", res.Errors)
+	}
+	var signup signupData
+	_ = json.Unmarshal(res.Data, &signup)
+
+	// tokens issued in the same second as the reset are not revoked
+	time.Sleep(time.Second)
+
+	res = doGraphQL(t, r, forgotPasswordMutation, map[string]interface{}{"email": "Jane@Example.com"})
+	if len(res.Errors) > 0 {
+		t.Fatalf("forgotPassword failed: %+v", res.Errors)
+	}
+	msg := smtp.waitForMessage(t, 1)
+	if msg.Subject != "Reset your password" || !strings.Contains(msg.Body, "http://localhost:3000/reset-password?token=") {
+		t.Fatalf("unexpected email %+v", msg)
+	}
+	resetToken := tokenFromEmail(t, msg)
+
+	// a password rejected by the policy does not consume the token
+	res = doGraphQL(t, r, resetPasswordMutation, resetPasswordInput(resetToken, "weak"))
+	if len(res.Errors) != 1 || res.Errors[0].Extensions["code"] != "BAD_USER_INPUT" {
+		t.Fatalf("expected policy error, got %+v", res.Errors)
+	}
+
+	res = doGraphQL(t, r, resetPasswordMutation, resetPasswordInput(resetToken, "NewSecret#456"))
+	if len(res.Errors) > 0 {
+		t.Fatalf("resetPassword failed: %+v", res.Errors)
+	}
+
+	res = doGraphQL(t, r, resetPasswordMutation, resetPasswordInput(resetToken, "Other#789xyz"))
+	if len(res.Errors) != 1 {
+		t.Error("reset tokens must be single use")
+	}
+
+	res = doGraphQL(t, r, loginMutation, map[string]interface{}{"email": "jane@example.com", "password": "Secret#123"})
+	if len(res.Errors) != 1 {
+		t.Error("the old password must no longer work")
+	}
+	res = doGraphQL(t, r, loginMutation, map[string]interface{}{"email": "jane@example.com", "password": "NewSecret#456"})
+	if len(res.Errors) > 0 {
+		t.Fatalf("login with the new password failed: %+v", res.Errors)
+	}
+
+	user, err := resolver.DB.GetUserByEmail(context.Background(), "jane@example.com")
+	if err != nil {
+		t.Fatalf("GetUserByEmail failed: %v", err)
+	}
+	claims, err := resolver.Tokens.ParseToken(*signup.Signup.RefreshToken, token.TypeRefreshToken)
+	if err != nil {
+		t.Fatalf("ParseToken failed: %v", err)
+	}
+	if !user.IsSessionRevoked(claims.IssuedAt.Time) {
+		t.Error("sessions issued before the reset must be revoked")
+	}
+	if user.IsSessionRevoked(time.Now()) {
+		t.Error("sessions issued after the reset must stay valid")
+	}
+}
+
+func TestForgotPasswordUnknownEmail(t *testing.T) {
+	smtp := startFakeSMTP(t)
+	cfg := testConfig(t)
+	cfg.IsEmailServiceEnabled = true
+	cfg.SMTPHost, cfg.SMTPPort = smtp.Host, smtp.Port
+	r := newGraphQLRouter(setupResolver(t, cfg))
+
+	res := doGraphQL(t, r, forgotPasswordMutation, map[string]interface{}{"email": "nobody@example.com"})
+	if len(res.Errors) > 0 {
+		t.Fatalf("unknown emails must get the generic response, got %+v", res.Errors)
+	}
+	if n := len(smtp.Messages()); n != 0 {
+		t.Errorf("expected no email, got %d", n)
+	}
+}
+
+func TestResetPasswordRejectsInvalidTokens(t *testing.T) {
+	smtp := startFakeSMTP(t)
+	cfg := testConfig(t)
+	cfg.IsEmailServiceEnabled = true
+	cfg.DisableEmailVerification = true
+	cfg.SMTPHost, cfg.SMTPPort = smtp.Host, smtp.Port
+	cfg.ResetPasswordTokenExpiryTime = -time.Minute
+	r := newGraphQLRouter(setupResolver(t, cfg))
+
+	res := doGraphQL(t, r, resetPasswordMutation, resetPasswordInput("unknown", "NewSecret#456"))
+	if len(res.Errors) != 1 || res.Errors[0].Message != "invalid token" {
+		t.Errorf("expected invalid token, got %+v", res.Errors)
+	}
+
+	doGraphQL(t, r, signupMutation, signupInput("jane@example.com", "Secret#123", "Secret#123"))
+	doGraphQL(t, r, forgotPasswordMutation, map[string]interface{}{"email": "jane@example.com"})
+	expired := tokenFromEmail(t, smtp.waitForMessage(t, 1))
+
+	res = doGraphQL(t, r, resetPasswordMutation, resetPasswordInput(expired, "NewSecret#456"))
+	if len(res.Errors) != 1 || res.Errors[0].Message != "token has expired" {
+		t.Errorf("expected expired token, got %+v", res.Errors)
+	}
+
+	res = doGraphQL(t, r, resetPasswordMutation, map[string]interface{}{"token": expired, "password": "NewSecret#456", "confirmPassword": "Other"})
+	if len(res.Errors) != 1 || res.Errors[0].Extensions["code"] != "BAD_USER_INPUT" {
+		t.Errorf("expected mismatch error, got %+v", res.Errors)
+	}
+}
+
+func TestResetPasswordRejectsOtherTokens(t *testing.T) {
+	smtp := startFakeSMTP(t)
+	cfg := testConfig(t)
+	cfg.IsEmailServiceEnabled = true
+	cfg.SMTPHost, cfg.SMTPPort = smtp.Host, smtp.Port
+	cfg.VerifyEmailURL = "http://localhost:3000/verify-email"
+	cfg.VerificationTokenExpiryTime = time.Hour
+	r := newGraphQLRouter(setupResolver(t, cfg))
+
+	doGraphQL(t, r, signupMutation, signupInput("jane@example.com", "Secret#123", "Secret#123"))
+	verifyToken := tokenFromEmail(t, smtp.waitForMessage(t, 1))
+
+	// the token is refused before the password is looked at
+	for _, password := range []string{"weak", "NewSecret#456"} {
+		res := doGraphQL(t, r, resetPasswordMutation, resetPasswordInput(verifyToken, password))
+		if len(res.Errors) != 1 || res.Errors[0].Message != "invalid token" {
+			t.Errorf("%s: expected the verification token to be refused, got %+v", password, res.Errors)
+		}
+	}
+
+	// the refused token is left for the flow it was issued for
+	res := doGraphQL(t, r, verifyEmailMutation, map[string]interface{}{"token": verifyToken})
+	if len(res.Errors) > 0 {
+		t.Fatalf("verifyEmail failed: %+v", res.Errors)
+	}
+	login(t, r, "jane@example.com", "Secret#123")
+}
+
+func updatePasswordInput(oldPassword, newPassword string) map[string]interface{} {
+	return map[string]interface{}{"oldPassword": oldPassword, "newPassword": newPassword, "confirmNewPassword": newPassword}
+}
+
+func TestUpdatePassword(t *testing.T) {
+	r := newGraphQLRouter(setupResolver(t, testConfig(t)))
+	doGraphQL(t, r, signupMutation, signupInput("jane@example.com", "Secret#123", "Secret#123"))
+	session := login(t, r, "jane@example.com", "Secret#123")
+
+	res, _ := doGraphQLRequest(t, r, updatePasswordMutation, updatePasswordInput("Secret#123", "NewSecret#456"), nil)
+	if len(res.Errors) != 1 || res.Errors[0].Extensions["code"] != "UNAUTHENTICATED" {
+		t.Fatalf("expected a session to be required, got %+v", res.Errors)
+	}
+	for name, input := range map[string]map[string]interface{}{
+		"wrong old password": updatePasswordInput("Wrong#123", "NewSecret#456"),
+		"no old password":    {"newPassword": "NewSecret#456", "confirmNewPassword": "NewSecret#456"},
+		"weak password":      updatePasswordInput("Secret#123", "weak"),
+		"mismatch":           {"oldPassword": "Secret#123", "newPassword": "NewSecret#456", "confirmNewPassword": "NewSecret#789"},
+	} {
+		res, _ = doGraphQLRequest(t, r, updatePasswordMutation, input, withBearer(session.AccessToken))
+		if len(res.Errors) != 1 || res.Errors[0].Extensions["code"] != "BAD_USER_INPUT" {
+			t.Errorf("%s: expected BAD_USER_INPUT, got %+v", name, res.Errors)
+		}
+	}
+
+	// tokens issued in the same second as the update are not revoked
+	time.Sleep(time.Second)
+	res, _ = doGraphQLRequest(t, r, updatePasswordMutation, updatePasswordInput("Secret#123", "NewSecret#456"), withBearer(session.AccessToken))
+	if len(res.Errors) > 0 {
+		t.Fatalf("updatePassword failed: %+v", res.Errors)
+	}
+	res, _ = doGraphQLRequest(t, r, updatePasswordMutation, updatePasswordInput("NewSecret#456", "Other#789xyz"), withBearer(session.AccessToken))
+	if len(res.Errors) != 1 || res.Errors[0].Extensions["code"] != "UNAUTHENTICATED" {
+		t.Errorf("expected the session to be logged out, got %+v", res.Errors)
+	}
+	res = doGraphQL(t, r, loginMutation, map[string]interface{}{"email": "jane@example.com", "password": "Secret#123"})
+	if len(res.Errors) != 1 {
+		t.Error("the old password must no longer work")
+	}
+	login(t, r, "jane@example.com", "NewSecret#456")
+}
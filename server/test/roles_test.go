@@ -0,0 +1,197 @@
+package test
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"server/database/models"
+	"server/graph"
+	"server/token"
+)
+
+const (
+	assignRolesMutation = `mutation($userId: ID!, $roles: [String!]!) { assignRoles(userId: $userId, roles: $roles) { roles } }`
+	removeRolesMutation = `mutation($userId: ID!, $roles: [String!]!) { removeRoles(userId: $userId, roles: $roles) { roles } }`
+	signupRolesMutation = `mutation($input: SignUpInput!) { signup(input: $input) { accessToken user { roles } } }`
+	loginRolesMutation  = `mutation($email: String!, $password: String!, $roles: [String!]) {
+  login(email: $email, password: $password, roles: $roles) { accessToken user { roles } }
+}`
+)
+
+// createAdmin signs a user up, grants them the admin role and logs them in
+func createAdmin(t *testing.T, resolver *graph.Resolver, r http.Handler, email string) loginResult {
+	t.Helper()
+
+	doGraphQL(t, r, signupMutation, signupInput(email, "Secret#123", "Secret#123"))
+	user, err := resolver.DB.GetUserByEmail(context.Background(), email)
+	if err != nil {
+		t.Fatalf("GetUserByEmail failed: %v", err)
+	}
+	user.SetRoles(append(user.RoleList(), "admin"))
+	if _, err := resolver.DB.UpdateUser(context.Background(), user); err != nil {
+		t.Fatalf("UpdateUser failed: %v", err)
+	}
+	return login(t, r, email, "Secret#123")
+}
+
+func signupWithRoles(email string, roles []string) map[string]interface{} {
+	input := signupInput(email, "Secret#123", "Secret#123")
+	input["input"].(map[string]interface{})["roles"] = roles
+	return input
+}
+
+func TestSignupRoles(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.Roles = []string{"user", "editor", "admin"}
+	cfg.ProtectedRoles = []string{"admin"}
+	r := newGraphQLRouter(setupResolver(t, cfg))
+
+	res := doGraphQL(t, r, signupRolesMutation, signupWithRoles("admin@example.com", []string{"admin"}))
+	if len(res.Errors) != 1 || res.Errors[0].Extensions["code"] != "FORBIDDEN" {
+		t.Errorf("expected protected roles to be refused, got %+v", res.Errors)
+	}
+	res = doGraphQL(t, r, signupRolesMutation, signupWithRoles("root@example.com", []string{"root"}))
+	if len(res.Errors) != 1 || res.Errors[0].Extensions["code"] != "BAD_USER_INPUT" {
+		t.Errorf("expected roles outside ROLES to be refused, got %+v", res.Errors)
+	}
+
+	res = doGraphQL(t, r, signupRolesMutation, signupWithRoles("editor@example.com", []string{"editor"}))
+	if len(res.Errors) > 0 || !strings.Contains(string(res.Data), `"roles":["editor"]`) {
+		t.Errorf("expected the requested role, got %s %+v", res.Data, res.Errors)
+	}
+	res = doGraphQL(t, r, signupRolesMutation, signupInput("jane@example.com", "Secret#123", "Secret#123"))
+	if len(res.Errors) > 0 || !strings.Contains(string(res.Data), `"roles":["user"]`) {
+		t.Errorf("expected DEFAULT_ROLES, got %s %+v", res.Data, res.Errors)
+	}
+}
+
+func TestLoginRoles(t *testing.T) {
+	resolver := setupResolver(t, testConfig(t))
+	r := newGraphQLRouter(resolver)
+	createAdmin(t, resolver, r, "admin@example.com")
+
+	vars := map[string]interface{}{"email": "admin@example.com", "password": "Secret#123", "roles": []string{"user"}}
+	res := doGraphQL(t, r, loginRolesMutation, vars)
+	var data struct{ Login struct{ AccessToken string } }
+	_ = json.Unmarshal(res.Data, &data)
+	claims, err := resolver.Tokens.ParseToken(data.Login.AccessToken, token.TypeAccessToken)
+	if err != nil || strings.Join(claims.Roles, ",") != "user" {
+		t.Errorf("expected the session to be narrowed to the requested roles, got %+v (%v)", claims, err)
+	}
+
+	doGraphQL(t, r, signupMutation, signupInput("jane@example.com", "Secret#123", "Secret#123"))
+	vars = map[string]interface{}{"email": "jane@example.com", "password": "Secret#123", "roles": []string{"admin"}}
+	res = doGraphQL(t, r, loginRolesMutation, vars)
+	if len(res.Errors) != 1 || res.Errors[0].Extensions["code"] != "FORBIDDEN" {
+		t.Errorf("expected roles the user does not have to be refused, got %+v", res.Errors)
+	}
+}
+
+func TestAssignAndRemoveRoles(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.Roles = []string{"user", "editor", "admin"}
+	cfg.ProtectedRoles = []string{"admin"}
+	resolver := setupResolver(t, cfg)
+	r := newGraphQLRouter(resolver)
+	admin := createAdmin(t, resolver, r, "admin@example.com")
+
+	doGraphQL(t, r, signupMutation, signupInput("jane@example.com", "Secret#123", "Secret#123"))
+	jane := login(t, r, "jane@example.com", "Secret#123")
+	user, _ := resolver.DB.GetUserByEmail(context.Background(), "jane@example.com")
+
+	vars := map[string]interface{}{"userId": user.ID, "roles": []string{"admin"}}
+	res, _ := doGraphQLRequest(t, r, assignRolesMutation, vars, withBearer(jane.AccessToken))
+	if len(res.Errors) != 1 || res.Errors[0].Extensions["code"] != "FORBIDDEN" {
+		t.Fatalf("users must not assign roles to themselves, got %+v", res.Errors)
+	}
+
+	// admins can assign protected roles
+	vars["roles"] = []string{"admin", "editor"}
+	res, _ = doGraphQLRequest(t, r, assignRolesMutation, vars, withBearer(admin.AccessToken))
+	if len(res.Errors) > 0 || !strings.Contains(string(res.Data), `"roles":["user","admin","editor"]`) {
+		t.Fatalf("assignRoles failed: %s %+v", res.Data, res.Errors)
+	}
+
+	vars["roles"] = []string{"admin"}
+	res, _ = doGraphQLRequest(t, r, removeRolesMutation, vars, withBearer(admin.AccessToken))
+	if len(res.Errors) > 0 || !strings.Contains(string(res.Data), `"roles":["user","editor"]`) {
+		t.Fatalf("removeRoles failed: %s %+v", res.Data, res.Errors)
+	}
+
+	vars["roles"] = []string{"superuser"}
+	res, _ = doGraphQLRequest(t, r, assignRolesMutation, vars, withBearer(admin.AccessToken))
+	if len(res.Errors) != 1 || res.Errors[0].Extensions["code"] != "BAD_USER_INPUT" {
+		t.Errorf("expected roles outside ROLES to be refused, got %+v", res.Errors)
+	}
+	res, _ = doGraphQLRequest(t, r, assignRolesMutation, map[string]interface{}{"userId": "unknown", "roles": []string{"user"}}, withBearer(admin.AccessToken))
+	if len(res.Errors) != 1 || res.Errors[0].Extensions["code"] != "NOT_FOUND" {
+		t.Errorf("expected NOT_FOUND, got %+v", res.Errors)
+	}
+}
+
+func TestRemovedRolesStopWorking(t *testing.T) {
+	resolver := setupResolver(t, testConfig(t))
+	r := newGraphQLRouter(resolver)
+	admin := createAdmin(t, resolver, r, "admin@example.com")
+	former := createAdmin(t, resolver, r, "former@example.com")
+	user, _ := resolver.DB.GetUserByEmail(context.Background(), "former@example.com")
+
+	vars := map[string]interface{}{"userId": user.ID, "roles": []string{"admin"}}
+	res, _ := doGraphQLRequest(t, r, removeRolesMutation, vars, withBearer(admin.AccessToken))
+	if len(res.Errors) > 0 {
+		t.Fatalf("removeRoles failed: %s %+v", res.Data, res.Errors)
+	}
+
+	// neither the refresh token cookie nor the access token still carry the
+	// removed role, although both list it in their claims
+	vars["roles"] = []string{"admin"}
+	for name, prepare := range map[string]func(*http.Request){
+		"cookie": withCookie(former.Cookie),
+		"bearer": withBearer(former.AccessToken),
+	} {
+		res, _ = doGraphQLRequest(t, r, assignRolesMutation, vars, prepare)
+		if len(res.Errors) != 1 || res.Errors[0].Extensions["code"] != "FORBIDDEN" {
+			t.Errorf("%s: expected the removed admin role to be refused, got %+v", name, res.Errors)
+		}
+	}
+}
+
+func TestJwtRoleClaim(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.JwtRoleClaim = "https://example.com/roles"
+	m, err := token.NewManager(cfg)
+	if err != nil {
+		t.Fatalf("NewManager failed: %v", err)
+	}
+
+	user := &models.User{ID: "user-id"}
+	user.SetRoles([]string{"user", "editor"})
+	tokens, err := m.CreateAuthTokens(user)
+	if err != nil {
+		t.Fatalf("CreateAuthTokens failed: %v", err)
+	}
+
+	payload, _ := base64.RawURLEncoding.DecodeString(strings.Split(tokens.AccessToken, ".")[1])
+	var raw map[string]interface{}
+	_ = json.Unmarshal(payload, &raw)
+	if _, ok := raw["roles"]; ok {
+		t.Error("roles must only be emitted under JWT_ROLE_CLAIM")
+	}
+	if roles, ok := raw["https://example.com/roles"].([]interface{}); !ok || len(roles) != 2 {
+		t.Errorf("expected the roles under JWT_ROLE_CLAIM, got %s", payload)
+	}
+
+	claims, err := m.ParseToken(tokens.AccessToken, token.TypeAccessToken)
+	if err != nil || strings.Join(claims.Roles, ",") != "user,editor" {
+		t.Errorf("expected the roles to be parsed back, got %+v (%v)", claims, err)
+	}
+
+	cfg.JwtRoleClaim = "sub"
+	if _, err := token.NewManager(cfg); err == nil {
+		t.Error("expected reserved claims to be refused as JWT_ROLE_CLAIM")
+	}
+}
@@ -0,0 +1,146 @@
+package test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"server/crypto"
+	"server/seed"
+)
+
+const seedFixture = `
+users:
+  - id: 7d3f0c3e-0000-4000-8000-000000000001
+    name: Admin
+    email: Admin@Example.com
+    roles: [user, admin]
+    password: Adm1n-Passw0rd
+    email_verified: true
+oauth_clients:
+  - client_id: web
+    client_secret: s3cret
+    name: Web
+    redirect_uris: [http://localhost:3000/callback]
+`
+
+func TestSeedIsIdempotent(t *testing.T) {
+	ctx := context.Background()
+	cfg := testConfig(t)
+	db := setupDatabase(t)
+
+	path := filepath.Join(t.TempDir(), "fixtures.yaml")
+	if err := os.WriteFile(path, []byte(seedFixture), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	fixtures, err := seed.Load(path)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	res, err := seed.Run(ctx, cfg, db, fixtures)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if res.UsersCreated != 1 || res.OAuthClientsCreated != 1 {
+		t.Errorf("unexpected first run result %+v", res)
+	}
+
+	res, err = seed.Run(ctx, cfg, db, fixtures)
+	if err != nil {
+		t.Fatalf("second Run failed: %v", err)
+	}
+	if *res != (seed.Result{}) {
+		t.Errorf("second run should change nothing, got %+v", res)
+	}
+
+	user, err := db.GetUserByEmail(ctx, "admin@example.com")
+	if err != nil {
+		t.Fatalf("seeded user not found: %v", err)
+	}
+	if user.ID != "7d3f0c3e-0000-4000-8000-000000000001" || user.Roles != "user,admin" || !user.IsEmailVerified() {
+		t.Errorf("unexpected seeded user %+v", user)
+	}
+	if !crypto.VerifyPassword(user.Password, "Adm1n-Passw0rd") {
+		t.Error("expected the password to be stored hashed")
+	}
+
+	client, err := db.GetOAuthClientByClientID(ctx, "web")
+	if err != nil {
+		t.Fatalf("seeded client not found: %v", err)
+	}
+	if client.ClientSecret != crypto.HashToken("s3cret") {
+		t.Error("expected the client secret to be stored hashed")
+	}
+
+	fixtures.Users[0].Roles = []string{"user"}
+	res, err = seed.Run(ctx, cfg, db, fixtures)
+	if err != nil || res.UsersUpdated != 1 {
+		t.Errorf("expected the changed user to be updated, got %+v, %v", res, err)
+	}
+}
+
+func TestSeedValidatesUsers(t *testing.T) {
+	ctx := context.Background()
+	cfg := testConfig(t)
+	db := setupDatabase(t)
+
+	for name, f := range map[string]seed.UserFixture{
+		"unknown role":  {Email: "jane@example.com", Roles: []string{"user", "owner"}},
+		"weak password": {Email: "jane@example.com", Roles: []string{"user"}, Password: "password"},
+	} {
+		if _, err := seed.Run(ctx, cfg, db, &seed.Fixtures{Users: []seed.UserFixture{f}}); err == nil {
+			t.Errorf("%s: expected the fixture to be refused", name)
+		}
+	}
+	if _, err := db.GetUserByEmail(ctx, "jane@example.com"); err == nil {
+		t.Error("expected refused fixtures not to be stored")
+	}
+
+	verified := false
+	fixtures := &seed.Fixtures{Users: []seed.UserFixture{{Email: "jane@example.com", Roles: []string{"user"}, EmailVerified: &verified}}}
+	if _, err := seed.Run(ctx, cfg, db, fixtures); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	verified = true
+	if res, err := seed.Run(ctx, cfg, db, fixtures); err != nil || res.UsersUpdated != 1 {
+		t.Fatalf("expected the user to be verified, got %+v, %v", res, err)
+	}
+	user, _ := db.GetUserByEmail(ctx, "jane@example.com")
+	if user == nil || !user.IsEmailVerified() || user.Password != "" {
+		t.Errorf("unexpected seeded user %+v", user)
+	}
+}
+
+func TestSeedDefaultRoles(t *testing.T) {
+	ctx := context.Background()
+	cfg := testConfig(t)
+	db := setupDatabase(t)
+
+	// users without roles get the default roles, as when signing up
+	fixtures := &seed.Fixtures{Users: []seed.UserFixture{{Email: "jane@example.com"}}}
+	if res, err := seed.Run(ctx, cfg, db, fixtures); err != nil || res.UsersCreated != 1 {
+		t.Fatalf("expected the user to be created, got %+v, %v", res, err)
+	}
+	user, _ := db.GetUserByEmail(ctx, "jane@example.com")
+	if user == nil || user.Roles != "user" {
+		t.Errorf("expected the default roles, got %+v", user)
+	}
+	if res, err := seed.Run(ctx, cfg, db, fixtures); err != nil || *res != (seed.Result{}) {
+		t.Errorf("second run should change nothing, got %+v, %v", res, err)
+	}
+}
+
+func TestSeedDevFixturesParse(t *testing.T) {
+	fixtures, err := seed.Load("../seeds/dev.yaml")
+	if err != nil {
+		t.Fatalf("failed to load dev fixtures: %v", err)
+	}
+	if len(fixtures.Users) == 0 {
+		t.Error("expected dev fixtures to contain users")
+	}
+	if _, err := seed.Run(context.Background(), testConfig(t), setupDatabase(t), fixtures); err != nil {
+		t.Errorf("failed to seed dev fixtures: %v", err)
+	}
+}
@@ -0,0 +1,235 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+
+	"server/config"
+	"server/sessionstore"
+)
+
+// sessionStores returns every session store backend, Redis being backed by
+// miniredis. expire makes the given duration elapse for the backend.
+func sessionStores() map[string]func(t *testing.T) (sessionstore.SessionStore, func(time.Duration)) {
+	return map[string]func(t *testing.T) (sessionstore.SessionStore, func(time.Duration)){
+		"memory": func(t *testing.T) (sessionstore.SessionStore, func(time.Duration)) {
+			store := sessionstore.NewMemoryStore()
+			t.Cleanup(func() { store.Close() })
+			return store, nil
+		},
+		"redis": func(t *testing.T) (sessionstore.SessionStore, func(time.Duration)) {
+			mr := miniredis.RunT(t)
+			store, err := sessionstore.NewRedisStore("redis://" + mr.Addr())
+			if err != nil {
+				t.Fatalf("NewRedisStore failed: %v", err)
+			}
+			t.Cleanup(func() { store.Close() })
+			return store, mr.FastForward
+		},
+	}
+}
+
+func newSession(id, userID string, ttl time.Duration) *sessionstore.Session {
+	now := time.Now()
+	return &sessionstore.Session{
+		ID:        id,
+		UserID:    userID,
+		Roles:     []string{"user"},
+		CreatedAt: now.Unix(),
+		ExpiresAt: now.Add(ttl).Unix(),
+	}
+}
+
+func sessionIDs(sessions []*sessionstore.Session) []string {
+	ids := make([]string, 0, len(sessions))
+	for _, s := range sessions {
+		ids = append(ids, s.ID)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func TestSessionStore(t *testing.T) {
+	for name, setup := range sessionStores() {
+		t.Run(name, func(t *testing.T) {
+			store, _ := setup(t)
+			ctx := context.Background()
+
+			for _, s := range []*sessionstore.Session{
+				newSession("s1", "jane", time.Hour),
+				newSession("s2", "jane", time.Hour),
+				newSession("s3", "john", time.Hour),
+			} {
+				if err := store.SetSession(ctx, s); err != nil {
+					t.Fatalf("SetSession failed: %v", err)
+				}
+			}
+
+			got, err := store.GetSession(ctx, "s1")
+			if err != nil || got.UserID != "jane" || len(got.Roles) != 1 || got.Roles[0] != "user" {
+				t.Fatalf("unexpected session %+v (%v)", got, err)
+			}
+			if _, err := store.GetSession(ctx, "unknown"); !errors.Is(err, sessionstore.ErrNotFound) {
+				t.Errorf("expected ErrNotFound, got %v", err)
+			}
+
+			sessions, err := store.ListUserSessions(ctx, "jane")
+			if ids := sessionIDs(sessions); err != nil || len(ids) != 2 || ids[0] != "s1" || ids[1] != "s2" {
+				t.Errorf("unexpected sessions %v (%v)", ids, err)
+			}
+
+			if err := store.DeleteSession(ctx, "s1"); err != nil {
+				t.Fatalf("DeleteSession failed: %v", err)
+			}
+			if err := store.DeleteSession(ctx, "s1"); err != nil {
+				t.Errorf("deleting an unknown session must be a no-op, got %v", err)
+			}
+			sessions, _ = store.ListUserSessions(ctx, "jane")
+			if ids := sessionIDs(sessions); len(ids) != 1 || ids[0] != "s2" {
+				t.Errorf("unexpected sessions after delete %v", ids)
+			}
+
+			if err := store.DeleteUserSessions(ctx, "jane"); err != nil {
+				t.Fatalf("DeleteUserSessions failed: %v", err)
+			}
+			if _, err := store.GetSession(ctx, "s2"); !errors.Is(err, sessionstore.ErrNotFound) {
+				t.Errorf("expected the sessions of the user to be deleted, got %v", err)
+			}
+			if _, err := store.GetSession(ctx, "s3"); err != nil {
+				t.Errorf("sessions of other users must be kept, got %v", err)
+			}
+		})
+	}
+}
+
+func TestSessionStoreDeleteUserSessionsConcurrently(t *testing.T) {
+	for name, setup := range sessionStores() {
+		t.Run(name, func(t *testing.T) {
+			store, _ := setup(t)
+			ctx := context.Background()
+
+			// sessions created while the sessions of the user are deleted are
+			// either deleted or still listed, never left live and unindexed
+			var wg sync.WaitGroup
+			ids := make([]string, 20)
+			for i := range ids {
+				ids[i] = fmt.Sprintf("s%d", i)
+				wg.Add(2)
+				go func() {
+					defer wg.Done()
+					_ = store.SetSession(ctx, newSession(ids[i], "jane", time.Hour))
+				}()
+				go func() {
+					defer wg.Done()
+					_ = store.DeleteUserSessions(ctx, "jane")
+				}()
+			}
+			wg.Wait()
+
+			sessions, _ := store.ListUserSessions(ctx, "jane")
+			listed := sessionIDs(sessions)
+			for _, id := range ids {
+				if _, err := store.GetSession(ctx, id); err == nil && !slices.Contains(listed, id) {
+					t.Errorf("session %s is live but not listed", id)
+				}
+			}
+		})
+	}
+}
+
+func TestSessionStoreState(t *testing.T) {
+	for name, setup := range sessionStores() {
+		t.Run(name, func(t *testing.T) {
+			store, _ := setup(t)
+			ctx := context.Background()
+
+			if err := store.SetState(ctx, "mfa:jane", "pending", time.Minute); err != nil {
+				t.Fatalf("SetState failed: %v", err)
+			}
+			if v, err := store.GetState(ctx, "mfa:jane"); err != nil || v != "pending" {
+				t.Errorf("unexpected state %q (%v)", v, err)
+			}
+			if err := store.DeleteState(ctx, "mfa:jane"); err != nil {
+				t.Fatalf("DeleteState failed: %v", err)
+			}
+			if _, err := store.GetState(ctx, "mfa:jane"); !errors.Is(err, sessionstore.ErrNotFound) {
+				t.Errorf("expected ErrNotFound, got %v", err)
+			}
+		})
+	}
+}
+
+func TestSessionStoreExpiry(t *testing.T) {
+	for name, setup := range sessionStores() {
+		t.Run(name, func(t *testing.T) {
+			store, fastForward := setup(t)
+			ctx := context.Background()
+
+			// the memory store relies on the clock, so its entries are
+			// created already expired
+			ttl := time.Second
+			if fastForward == nil {
+				ttl = -time.Second
+			}
+			_ = store.SetSession(ctx, newSession("short", "jane", ttl))
+			_ = store.SetSession(ctx, newSession("long", "jane", time.Hour))
+			_ = store.SetState(ctx, "otp", "123456", ttl)
+			if fastForward != nil {
+				fastForward(2 * time.Second)
+			}
+			// the memory store also drops expired entries nobody reads again
+			if memory, ok := store.(*sessionstore.MemoryStore); ok {
+				memory.DeleteExpired()
+			}
+
+			if _, err := store.GetSession(ctx, "short"); !errors.Is(err, sessionstore.ErrNotFound) {
+				t.Errorf("expected the session to expire, got %v", err)
+			}
+			if _, err := store.GetState(ctx, "otp"); !errors.Is(err, sessionstore.ErrNotFound) {
+				t.Errorf("expected the state to expire, got %v", err)
+			}
+			sessions, _ := store.ListUserSessions(ctx, "jane")
+			if ids := sessionIDs(sessions); len(ids) != 1 || ids[0] != "long" {
+				t.Errorf("expected only the live session, got %v", ids)
+			}
+		})
+	}
+}
+
+func TestNewSessionStore(t *testing.T) {
+	mr := miniredis.RunT(t)
+
+	cases := []struct {
+		name    string
+		cfg     config.Config
+		isRedis bool
+	}{
+		{"no redis url", config.Config{}, false},
+		{"redis", config.Config{RedisURL: "redis://" + mr.Addr()}, true},
+		{"redis disabled", config.Config{RedisURL: "redis://" + mr.Addr(), DisableRedisForEnv: true}, false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			store, err := sessionstore.New(&tc.cfg)
+			if err != nil {
+				t.Fatalf("New failed: %v", err)
+			}
+			defer store.Close()
+			if _, ok := store.(*sessionstore.RedisStore); ok != tc.isRedis {
+				t.Errorf("expected redis=%t, got %T", tc.isRedis, store)
+			}
+		})
+	}
+
+	if _, err := sessionstore.New(&config.Config{RedisURL: "not a url"}); err == nil {
+		t.Error("expected an invalid REDIS_URL to fail")
+	}
+}
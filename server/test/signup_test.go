@@ -0,0 +1,115 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"server/crypto"
+	"server/token"
+)
+
+const signupMutation = `mutation($input: SignUpInput!) {
+  signup(input: $input) { message accessToken refreshToken expiresIn user { id email } }
+}`
+
+type signupData struct {
+	Signup struct {
+		Message      string
+		AccessToken  *string
+		RefreshToken *string
+		ExpiresIn    *int
+		User         *struct{ ID, Email string }
+	}
+}
+
+func signupInput(email, password, confirm string) map[string]interface{} {
+	return map[string]interface{}{
+		"input": map[string]interface{}{
+			"name":            "Jane",
+			"email":           email,
+			"password":        password,
+			"confirmPassword": confirm,
+		},
+	}
+}
+
+func TestSignup(t *testing.T) {
+	cfg := testConfig(t)
+	resolver := setupResolver(t, cfg)
+	r := newGraphQLRouter(resolver)
+
+	res := doGraphQL(t, r, signupMutation, signupInput("jane@example.com", "Secret#123", "Secret#123"))
+	if len(res.Errors) > 0 {
+		t.Fatalf("unexpected errors: %+v", res.Errors)
+	}
+	var data signupData
+	_ = json.Unmarshal(res.Data, &data)
+	if data.Signup.AccessToken == nil || data.Signup.RefreshToken == nil || data.Signup.ExpiresIn == nil {
+		t.Fatalf("expected tokens in response, got %+v", data.Signup)
+	}
+
+	claims, err := resolver.Tokens.ParseToken(*data.Signup.AccessToken, token.TypeAccessToken)
+	if err != nil {
+		t.Fatalf("access token does not verify: %v", err)
+	}
+	if claims.Subject != data.Signup.User.ID || len(claims.Roles) != 1 || claims.Roles[0] != "user" {
+		t.Errorf("unexpected claims %+v", claims)
+	}
+	if _, err := resolver.Tokens.ParseToken(*data.Signup.AccessToken, token.TypeRefreshToken); err == nil {
+		t.Error("access token must not be accepted as a refresh token")
+	}
+
+	user, err := resolver.DB.GetUserByEmail(context.Background(), "jane@example.com")
+	if err != nil {
+		t.Fatalf("user not stored: %v", err)
+	}
+	if user.Password == "Secret#123" || !crypto.VerifyPassword(user.Password, "Secret#123") {
+		t.Error("expected the password to be stored as a bcrypt hash")
+	}
+	if user.Roles != "user" {
+		t.Errorf("expected default roles, got %q", user.Roles)
+	}
+
+	res = doGraphQL(t, r, signupMutation, signupInput("jane@example.com", "Secret#123", "Secret#123"))
+	if len(res.Errors) != 1 || res.Errors[0].Extensions["code"] != "CONFLICT" {
+		t.Errorf("expected CONFLICT for duplicate email, got %+v", res.Errors)
+	}
+
+	res = doGraphQL(t, r, signupMutation, signupInput("john@example.com", "Secret#123", "Other#123"))
+	if len(res.Errors) != 1 || res.Errors[0].Extensions["code"] != "BAD_USER_INPUT" {
+		t.Errorf("expected BAD_USER_INPUT for mismatched passwords, got %+v", res.Errors)
+	}
+}
+
+func TestSignupDisabled(t *testing.T) {
+	for _, disable := range []string{"signup", "basic"} {
+		cfg := testConfig(t)
+		cfg.DisableSignUp = disable == "signup"
+		cfg.DisableBasicAuthentication = disable == "basic"
+		r := newGraphQLRouter(setupResolver(t, cfg))
+
+		res := doGraphQL(t, r, signupMutation, signupInput("jane@example.com", "Secret#123", "Secret#123"))
+		if len(res.Errors) != 1 || res.Errors[0].Extensions["code"] != "FORBIDDEN" {
+			t.Errorf("%s disabled: expected FORBIDDEN, got %+v", disable, res.Errors)
+		}
+	}
+}
+
+func TestSignupCannotRequestAdmin(t *testing.T) {
+	// the admin role is protected even when PROTECTED_ROLES is empty
+	cfg := testConfig(t)
+	cfg.ProtectedRoles = nil
+	resolver := setupResolver(t, cfg)
+	r := newGraphQLRouter(resolver)
+
+	input := signupInput("jane@example.com", "Secret#123", "Secret#123")
+	input["input"].(map[string]interface{})["roles"] = []string{"user", "admin"}
+	res := doGraphQL(t, r, signupMutation, input)
+	if len(res.Errors) != 1 || res.Errors[0].Extensions["code"] != "FORBIDDEN" {
+		t.Fatalf("expected FORBIDDEN when requesting admin, got %+v", res.Errors)
+	}
+	if _, err := resolver.DB.GetUserByEmail(context.Background(), "jane@example.com"); err == nil {
+		t.Error("expected no user to be created")
+	}
+}
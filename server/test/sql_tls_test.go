@@ -0,0 +1,166 @@
+package test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"server/config"
+	"server/database/sql"
+)
+
+// testCACert returns a self-signed CA certificate in PEM
+func testCACert(t *testing.T) string {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey failed: %v", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("CreateCertificate failed: %v", err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+}
+
+func sqlTLSConfig(t *testing.T, mode string) *config.Config {
+	return &config.Config{
+		DBHost:     "db.example.com",
+		DBPort:     "5432",
+		DBName:     "app",
+		DBUser:     "app",
+		DBPassword: "p@ss/word",
+		DBSSLMode:  mode,
+		DBCACert:   testCACert(t),
+	}
+}
+
+func TestNewTLSConfig(t *testing.T) {
+	for _, c := range []struct {
+		mode       string
+		disabled   bool
+		skipVerify bool
+		verifyCA   bool
+	}{
+		{mode: "", disabled: true},
+		{mode: "disable", disabled: true},
+		{mode: "allow", skipVerify: true},
+		{mode: "prefer", skipVerify: true},
+		{mode: "require", skipVerify: true},
+		{mode: "verify-ca", skipVerify: true, verifyCA: true},
+		{mode: "verify-full"},
+	} {
+		tlsConfig, err := sql.NewTLSConfig(sqlTLSConfig(t, c.mode), "db.example.com")
+		if err != nil {
+			t.Errorf("%q: NewTLSConfig failed: %v", c.mode, err)
+			continue
+		}
+		if c.disabled {
+			if tlsConfig != nil {
+				t.Errorf("%q: expected no TLS", c.mode)
+			}
+			continue
+		}
+		if tlsConfig == nil || tlsConfig.RootCAs == nil || tlsConfig.ServerName != "db.example.com" || tlsConfig.MinVersion != tls.VersionTLS12 {
+			t.Errorf("%q: unexpected TLS config %+v", c.mode, tlsConfig)
+			continue
+		}
+		if tlsConfig.InsecureSkipVerify != c.skipVerify || (tlsConfig.VerifyPeerCertificate != nil) != c.verifyCA {
+			t.Errorf("%q: unexpected verification, skip %v and chain %v", c.mode, tlsConfig.InsecureSkipVerify, tlsConfig.VerifyPeerCertificate != nil)
+		}
+	}
+
+	if _, err := sql.NewTLSConfig(sqlTLSConfig(t, "sometimes"), "db.example.com"); err == nil {
+		t.Error("expected unknown SSL modes to be refused")
+	}
+	cfg := sqlTLSConfig(t, "require")
+	cfg.DBCert = cfg.DBCACert
+	if _, err := sql.NewTLSConfig(cfg, "db.example.com"); err == nil {
+		t.Error("expected a client certificate without key to be refused")
+	}
+}
+
+func TestPostgresConfig(t *testing.T) {
+	for _, c := range []struct {
+		mode string
+		// attempts are the connections pgx tries in order, true for TLS
+		attempts []bool
+	}{
+		{mode: "disable", attempts: []bool{false}},
+		{mode: "allow", attempts: []bool{false, true}},
+		{mode: "prefer", attempts: []bool{true, false}},
+		{mode: "require", attempts: []bool{true}},
+		{mode: "verify-ca", attempts: []bool{true}},
+		{mode: "verify-full", attempts: []bool{true}},
+	} {
+		pgxConfig, err := sql.PostgresConfig(sqlTLSConfig(t, c.mode))
+		if err != nil {
+			t.Errorf("%q: PostgresConfig failed: %v", c.mode, err)
+			continue
+		}
+		tlsConfigs := []*tls.Config{pgxConfig.TLSConfig}
+		for _, fallback := range pgxConfig.Fallbacks {
+			tlsConfigs = append(tlsConfigs, fallback.TLSConfig)
+		}
+		if len(tlsConfigs) != len(c.attempts) {
+			t.Errorf("%q: expected %d attempts, got %d", c.mode, len(c.attempts), len(tlsConfigs))
+			continue
+		}
+		for i, tlsConfig := range tlsConfigs {
+			if (tlsConfig != nil) != c.attempts[i] {
+				t.Errorf("%q: attempt %d expected TLS %v", c.mode, i, c.attempts[i])
+			}
+			if tlsConfig != nil && tlsConfig.RootCAs == nil {
+				t.Errorf("%q: attempt %d does not use the configured CA", c.mode, i)
+			}
+		}
+		if pgxConfig.Password != "p@ss/word" || pgxConfig.Database != "app" {
+			t.Errorf("%q: unexpected credentials %q %q", c.mode, pgxConfig.Password, pgxConfig.Database)
+		}
+	}
+}
+
+func TestMySQLConfig(t *testing.T) {
+	for _, c := range []struct {
+		mode      string
+		tls       bool
+		plaintext bool
+	}{
+		{mode: "disable", plaintext: true},
+		{mode: "allow", tls: true, plaintext: true},
+		{mode: "prefer", tls: true, plaintext: true},
+		{mode: "require", tls: true},
+		{mode: "verify-ca", tls: true},
+		{mode: "verify-full", tls: true},
+	} {
+		dsn, err := sql.MySQLConfig(sqlTLSConfig(t, c.mode))
+		if err != nil {
+			t.Errorf("%q: MySQLConfig failed: %v", c.mode, err)
+			continue
+		}
+		if (dsn.TLSConfig != "") != c.tls || (dsn.TLSConfig == "" || dsn.AllowFallbackToPlaintext) != c.plaintext {
+			t.Errorf("%q: unexpected TLS %q with plaintext fallback %v", c.mode, dsn.TLSConfig, dsn.AllowFallbackToPlaintext)
+		}
+		if !dsn.ClientFoundRows || !strings.Contains(dsn.FormatDSN(), "clientFoundRows=true") {
+			t.Errorf("%q: expected matched rows to be reported, got %s", c.mode, dsn.FormatDSN())
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"strings"
+	"testing"
+
+	"server/database/models"
+	"server/token"
+)
+
+func rsaKeyPEM(t *testing.T) (string, string) {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return encodeKeyPair(t, key, &key.PublicKey)
+}
+
+func ecKeyPEM(t *testing.T, curve elliptic.Curve) (string, string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(curve, rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return encodeKeyPair(t, key, &key.PublicKey)
+}
+
+func encodeKeyPair(t *testing.T, private, public interface{}) (string, string) {
+	t.Helper()
+
+	privateDER, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicDER, err := x509.MarshalPKIXPublicKey(public)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privateDER})),
+		string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER}))
+}
+
+func TestTokenAlgorithms(t *testing.T) {
+	rsaPrivate, rsaPublic := rsaKeyPEM(t)
+	keys := map[string]elliptic.Curve{"ES256": elliptic.P256(), "ES384": elliptic.P384(), "ES512": elliptic.P521()}
+	user := &models.User{ID: "user-id", Email: "jane@example.com", Roles: "user"}
+
+	for _, alg := range []string{"HS256", "HS384", "HS512", "RS256", "RS384", "RS512", "ES256", "ES384", "ES512"} {
+		t.Run(alg, func(t *testing.T) {
+			cfg := testConfig(t)
+			cfg.JwtType = alg
+			switch {
+			case strings.HasPrefix(alg, "RS"):
+				cfg.JwtPrivateKey, cfg.JwtPublicKey = rsaPrivate, rsaPublic
+			case strings.HasPrefix(alg, "ES"):
+				cfg.JwtPrivateKey, cfg.JwtPublicKey = ecKeyPEM(t, keys[alg])
+				// keys are commonly passed as single line env variables
+				cfg.JwtPrivateKey = strings.ReplaceAll(cfg.JwtPrivateKey, "\n", `\n`)
+			}
+
+			m, err := token.NewManager(cfg)
+			if err != nil {
+				t.Fatalf("NewManager failed: %v", err)
+			}
+			tokens, err := m.CreateAuthTokens(user)
+			if err != nil {
+				t.Fatalf("CreateAuthTokens failed: %v", err)
+			}
+			claims, err := m.ParseToken(tokens.AccessToken, token.TypeAccessToken)
+			if err != nil {
+				t.Fatalf("ParseToken failed: %v", err)
+			}
+			if claims.Subject != user.ID || claims.Email != user.Email {
+				t.Errorf("unexpected claims %+v", claims)
+			}
+			if _, err := m.ParseToken(tokens.RefreshToken, token.TypeRefreshToken); err != nil {
+				t.Errorf("refresh token does not verify: %v", err)
+			}
+		})
+	}
+}
+
+func TestTokenKeyMismatch(t *testing.T) {
+	private, _ := ecKeyPEM(t, elliptic.P256())
+	_, otherPublic := ecKeyPEM(t, elliptic.P256())
+
+	cfg := testConfig(t)
+	cfg.JwtType = "ES256"
+	cfg.JwtPrivateKey, cfg.JwtPublicKey = private, otherPublic
+	if _, err := token.NewManager(cfg); err == nil {
+		t.Error("expected mismatched key pair to be rejected")
+	}
+
+	cfg.JwtType = "ES384"
+	cfg.JwtPublicKey = ""
+	if _, err := token.NewManager(cfg); err == nil {
+		t.Error("expected a P-256 key to be rejected for ES384")
+	}
+
+	cfg.JwtType = "RS256"
+	cfg.JwtPrivateKey = ""
+	if _, err := token.NewManager(cfg); err == nil {
+		t.Error("expected a missing private key to be rejected")
+	}
+}
+
+func TestTokenRejectsOtherSigner(t *testing.T) {
+	cfg := testConfig(t)
+	m, _ := token.NewManager(cfg)
+	tokens, _ := m.CreateAuthTokens(&models.User{ID: "user-id"})
+
+	other := testConfig(t)
+	other.JwtSecret = "another-secret"
+	m2, _ := token.NewManager(other)
+	if _, err := m2.ParseToken(tokens.AccessToken, token.TypeAccessToken); err == nil {
+		t.Error("expected a token signed with another secret to be rejected")
+	}
+}
@@ -0,0 +1,62 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"server/database/models"
+	"server/database/sql"
+)
+
+func setupUserRepository(t *testing.T) *sql.UserRepository {
+	t.Helper()
+
+	return sql.NewUserRepository(setupSQLite(t))
+}
+
+func TestSQLUserRepository(t *testing.T) {
+	ctx := context.Background()
+	repo := setupUserRepository(t)
+
+	user, err := repo.CreateUser(ctx, &models.User{Name: "Jane", Email: "jane@example.com"})
+	if err != nil {
+		t.Fatalf("CreateUser failed: %v", err)
+	}
+	if user.ID == "" {
+		t.Fatal("expected an id to be generated")
+	}
+
+	if _, err := repo.CreateUser(ctx, &models.User{Name: "Other", Email: "jane@example.com"}); !errors.Is(err, models.ErrDuplicate) {
+		t.Errorf("expected ErrDuplicate, got %v", err)
+	}
+
+	byEmail, err := repo.GetUserByEmail(ctx, "jane@example.com")
+	if err != nil || byEmail.ID != user.ID {
+		t.Fatalf("GetUserByEmail returned %v, %v", byEmail, err)
+	}
+
+	user.Name = "Jane Doe"
+	if _, err := repo.UpdateUser(ctx, user); err != nil {
+		t.Fatalf("UpdateUser failed: %v", err)
+	}
+	byID, err := repo.GetUserByID(ctx, user.ID)
+	if err != nil || byID.Name != "Jane Doe" {
+		t.Fatalf("GetUserByID returned %v, %v", byID, err)
+	}
+
+	users, err := repo.ListUsers(ctx)
+	if err != nil || len(users) != 1 {
+		t.Fatalf("ListUsers returned %d users, %v", len(users), err)
+	}
+
+	if err := repo.DeleteUser(ctx, user.ID); err != nil {
+		t.Fatalf("DeleteUser failed: %v", err)
+	}
+	if _, err := repo.GetUserByID(ctx, user.ID); !errors.Is(err, models.ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+	if err := repo.DeleteUser(ctx, user.ID); !errors.Is(err, models.ErrNotFound) {
+		t.Errorf("expected ErrNotFound on second delete, got %v", err)
+	}
+}
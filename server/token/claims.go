@@ -0,0 +1,66 @@
+package token
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+)
+
+// DefaultRoleClaim is the claim carrying the roles when JWT_ROLE_CLAIM is unset
+const DefaultRoleClaim = "roles"
+
+// reservedClaims cannot be used as JWT_ROLE_CLAIM
+var reservedClaims = []string{"iss", "sub", "aud", "exp", "nbf", "iat", "jti", "token_type", "email", "scope", "sid", "client_id"}
+
+// claimsJSON is Claims without its JSON methods
+type claimsJSON Claims
+
+// MarshalJSON emits the roles under the configured role claim
+func (c Claims) MarshalJSON() ([]byte, error) {
+	data, err := json.Marshal(claimsJSON(c))
+	if err != nil || len(c.Roles) == 0 {
+		return data, err
+	}
+
+	fields := map[string]json.RawMessage{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	if fields[c.roleClaimName()], err = json.Marshal(c.Roles); err != nil {
+		return nil, err
+	}
+	return json.Marshal(fields)
+}
+
+// UnmarshalJSON reads the roles from the configured role claim
+func (c *Claims) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, (*claimsJSON)(c)); err != nil {
+		return err
+	}
+
+	fields := map[string]json.RawMessage{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	if raw, ok := fields[c.roleClaimName()]; ok {
+		if err := json.Unmarshal(raw, &c.Roles); err != nil {
+			return fmt.Errorf("invalid %s claim: %w", c.roleClaimName(), err)
+		}
+	}
+	return nil
+}
+
+func (c *Claims) roleClaimName() string {
+	if c.roleClaim == "" {
+		return DefaultRoleClaim
+	}
+	return c.roleClaim
+}
+
+// validateRoleClaim rejects role claim names clashing with another claim
+func validateRoleClaim(name string) error {
+	if slices.Contains(reservedClaims, name) {
+		return fmt.Errorf("JWT_ROLE_CLAIM cannot be the reserved claim %q", name)
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package token
+
+import (
+	"slices"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"server/database/models"
+)
+
+// IDTokenParams describe the authentication an ID token asserts
+type IDTokenParams struct {
+	// Audience is the client_id of the client the token is issued to
+	Audience  string
+	Nonce     string
+	SessionID string
+	// AuthTime is the unix time the user authenticated and AMR the methods
+	// they used
+	AuthTime int64
+	AMR      []string
+	// Scope selects the claims about the user, see UserInfoClaims
+	Scope []string
+}
+
+// UserInfoClaims returns the claims about the user the scope gives access
+// to: name and updated_at for profile, email and email_verified for email.
+// The phone scope is accepted but adds nothing, users have no phone number.
+func UserInfoClaims(user *models.User, scope []string) map[string]interface{} {
+	claims := map[string]interface{}{"sub": user.ID}
+	if slices.Contains(scope, "profile") {
+		claims["name"] = user.Name
+		claims["updated_at"] = user.UpdatedAt
+	}
+	if slices.Contains(scope, "email") {
+		claims["email"] = user.Email
+		claims["email_verified"] = user.IsEmailVerified()
+	}
+	return claims
+}
+
+// CreateIDToken issues an OpenID Connect ID token for the user. It expires
+// with the access token issued along with it.
+func (m *Manager) CreateIDToken(user *models.User, p IDTokenParams) (string, error) {
+	if !m.IssuesIDTokens() {
+		return "", ErrIDTokensUnsupported
+	}
+	now := time.Now()
+	claims := jwt.MapClaims(UserInfoClaims(user, p.Scope))
+	claims["iss"] = m.cfg.AuthorizerURL
+	claims["aud"] = p.Audience
+	claims["iat"] = now.Unix()
+	claims["exp"] = now.Add(m.cfg.AccessTokenExpiryTime).Unix()
+	claims["auth_time"] = p.AuthTime
+	// keeps ID tokens from being accepted as access tokens by ParseToken
+	claims["token_type"] = TypeIDToken
+	if p.Nonce != "" {
+		claims["nonce"] = p.Nonce
+	}
+	if len(p.AMR) > 0 {
+		claims["amr"] = p.AMR
+	}
+	if p.SessionID != "" {
+		claims["sid"] = p.SessionID
+	}
+	return m.sign(claims)
+}
@@ -0,0 +1,121 @@
+package token
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// JSONWebKey is the public part of a signing key as published in the JWKS
+type JSONWebKey struct {
+	Kty string `json:"kty"`
+	Use string `json:"use,omitempty"`
+	Alg string `json:"alg,omitempty"`
+	Kid string `json:"kid"`
+	// N and E are the modulus and exponent of RSA keys
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+	// Crv, X and Y are the curve and coordinates of EC keys
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+// JSONWebKeySet is the document served at /.well-known/jwks.json
+type JSONWebKeySet struct {
+	Keys []JSONWebKey `json:"keys"`
+}
+
+// curveNames maps the ES algorithms to their JWK curve name
+var curveNames = map[string]string{
+	"ES256": "P-256",
+	"ES384": "P-384",
+	"ES512": "P-521",
+}
+
+// publicJWK describes the verification key of an RS* or ES* algorithm, the
+// key id is its RFC 7638 thumbprint
+func publicJWK(alg string, key crypto.PublicKey) (JSONWebKey, error) {
+	jwk := JSONWebKey{Use: "sig", Alg: alg}
+	var thumbprint []byte
+	var err error
+
+	switch key := key.(type) {
+	case *rsa.PublicKey:
+		jwk.Kty = "RSA"
+		jwk.N = base64.RawURLEncoding.EncodeToString(key.N.Bytes())
+		jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes())
+		// the members are listed in lexicographic order as RFC 7638 requires
+		thumbprint, err = json.Marshal(struct {
+			E   string `json:"e"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+		}{jwk.E, jwk.Kty, jwk.N})
+	case *ecdsa.PublicKey:
+		point, ecdhErr := key.ECDH()
+		if ecdhErr != nil {
+			return JSONWebKey{}, ecdhErr
+		}
+		// uncompressed point: 0x04 || X || Y
+		raw := point.Bytes()[1:]
+		size := len(raw) / 2
+		jwk.Kty = "EC"
+		jwk.Crv = curveNames[alg]
+		jwk.X = base64.RawURLEncoding.EncodeToString(raw[:size])
+		jwk.Y = base64.RawURLEncoding.EncodeToString(raw[size:])
+		thumbprint, err = json.Marshal(struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+		}{jwk.Crv, jwk.Kty, jwk.X, jwk.Y})
+	default:
+		return JSONWebKey{}, fmt.Errorf("unsupported public key type %T", key)
+	}
+	if err != nil {
+		return JSONWebKey{}, err
+	}
+
+	sum := sha256.Sum256(thumbprint)
+	jwk.Kid = base64.RawURLEncoding.EncodeToString(sum[:])
+	return jwk, nil
+}
+
+// parseJWK parses the JWK variable, a single key or a key set published next
+// to the current key, e.g. the previous key while tokens it signed are still
+// valid. Private members are dropped.
+func parseJWK(value string) ([]JSONWebKey, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	var set struct {
+		Keys []JSONWebKey `json:"keys"`
+		JSONWebKey
+	}
+	if err := json.Unmarshal([]byte(value), &set); err != nil {
+		return nil, fmt.Errorf("invalid JWK: %w", err)
+	}
+	keys := set.Keys
+	if keys == nil {
+		keys = []JSONWebKey{set.JSONWebKey}
+	}
+	for _, key := range keys {
+		if key.Kty == "" || key.Kid == "" {
+			return nil, errors.New("invalid JWK: every key needs kty and kid")
+		}
+	}
+	return keys, nil
+}
+
+// JWKS returns the public keys resource servers can verify tokens with. It
+// is empty for the HS* algorithms whose secret must not be published.
+func (m *Manager) JWKS() JSONWebKeySet {
+	return JSONWebKeySet{Keys: append([]JSONWebKey{}, m.jwks...)}
+}
@@ -0,0 +1,72 @@
+package token
+
+import (
+	"crypto"
+	"crypto/elliptic"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// curves maps the ES algorithms to the curve their keys must use
+var curves = map[string]elliptic.Curve{
+	"ES256": elliptic.P256(),
+	"ES384": elliptic.P384(),
+	"ES512": elliptic.P521(),
+}
+
+// parseKeyPair parses the PEM encoded JWT_PRIVATE_KEY / JWT_PUBLIC_KEY for
+// an RS* or ES* algorithm. The public key is derived from the private key
+// when it is not provided.
+func parseKeyPair(alg, privatePEM, publicPEM string) (crypto.PrivateKey, crypto.PublicKey, error) {
+	if privatePEM == "" {
+		return nil, nil, fmt.Errorf("JWT_PRIVATE_KEY is required for %s", alg)
+	}
+	privatePEM = normalizePEM(privatePEM)
+	publicPEM = normalizePEM(publicPEM)
+
+	if strings.HasPrefix(alg, "RS") {
+		private, err := jwt.ParseRSAPrivateKeyFromPEM([]byte(privatePEM))
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid JWT_PRIVATE_KEY: %w", err)
+		}
+		if publicPEM == "" {
+			return private, &private.PublicKey, nil
+		}
+		public, err := jwt.ParseRSAPublicKeyFromPEM([]byte(publicPEM))
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid JWT_PUBLIC_KEY: %w", err)
+		}
+		if !public.Equal(&private.PublicKey) {
+			return nil, nil, errors.New("JWT_PUBLIC_KEY does not match JWT_PRIVATE_KEY")
+		}
+		return private, public, nil
+	}
+
+	private, err := jwt.ParseECPrivateKeyFromPEM([]byte(privatePEM))
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid JWT_PRIVATE_KEY: %w", err)
+	}
+	if private.Curve != curves[alg] {
+		return nil, nil, fmt.Errorf("JWT_PRIVATE_KEY uses curve %s which does not match %s", private.Curve.Params().Name, alg)
+	}
+	if publicPEM == "" {
+		return private, &private.PublicKey, nil
+	}
+	public, err := jwt.ParseECPublicKeyFromPEM([]byte(publicPEM))
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid JWT_PUBLIC_KEY: %w", err)
+	}
+	if !public.Equal(&private.PublicKey) {
+		return nil, nil, errors.New("JWT_PUBLIC_KEY does not match JWT_PRIVATE_KEY")
+	}
+	return private, public, nil
+}
+
+// normalizePEM restores the line breaks of keys passed as a single line
+// environment variable with escaped "\n"
+func normalizePEM(key string) string {
+	return strings.TrimSpace(strings.ReplaceAll(key, `\n`, "\n"))
+}
@@ -0,0 +1,234 @@
+package token
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	log "github.com/sirupsen/logrus"
+
+	"server/config"
+	"server/database/models"
+)
+
+// Token types carried in the token_type claim
+const (
+	TypeAccessToken  = "access_token"
+	TypeRefreshToken = "refresh_token"
+	TypeIDToken      = "id_token"
+)
+
+var (
+	// ErrInvalidToken is returned when a token cannot be verified
+	ErrInvalidToken = errors.New("invalid token")
+	// ErrIDTokensUnsupported is returned when asked for an ID token while
+	// signing with an HMAC secret, see IssuesIDTokens
+	ErrIDTokensUnsupported = errors.New("ID tokens require an RS or ES JWT_TYPE")
+)
+
+// Claims are the claims of the tokens issued by the server
+type Claims struct {
+	jwt.RegisteredClaims
+	TokenType string `json:"token_type"`
+	Email     string `json:"email,omitempty"`
+	// Roles are emitted under the claim named by JWT_ROLE_CLAIM, see MarshalJSON
+	Roles []string `json:"-"`
+	// Scope is the space separated list of granted scopes
+	Scope string `json:"scope,omitempty"`
+	// SessionID identifies the session, i.e. the refresh token family
+	SessionID string `json:"sid,omitempty"`
+	// ClientID is the OAuth client the tokens were issued to, empty for the
+	// applications of the server itself
+	ClientID string `json:"client_id,omitempty"`
+
+	roleClaim string
+}
+
+// Grant narrows what issued tokens allow. Nil roles stand for every role of
+// the user and nil scope leaves the scope claim out.
+type Grant struct {
+	Roles []string
+	Scope []string
+	// SessionID is carried by the tokens so they can be checked against the
+	// session store
+	SessionID string
+	// ClientID is the OAuth client the tokens are issued to
+	ClientID string
+}
+
+// AuthTokens is a freshly issued access / refresh token pair
+type AuthTokens struct {
+	AccessToken string
+	// ExpiresIn is the lifetime of the access token in seconds
+	ExpiresIn    int64
+	RefreshToken string
+	// IDToken is only issued to OAuth clients granted the openid scope
+	IDToken string
+}
+
+// Manager signs and verifies the tokens issued by the server
+type Manager struct {
+	cfg       *config.Config
+	method    jwt.SigningMethod
+	signKey   interface{}
+	verifyKey interface{}
+	// keyID is sent in the kid header of tokens signed with a key pair
+	keyID string
+	jwks  []JSONWebKey
+}
+
+// NewManager builds a token manager for the algorithm configured in JWT_TYPE
+func NewManager(cfg *config.Config) (*Manager, error) {
+	m := &Manager{cfg: cfg}
+	if err := validateRoleClaim(cfg.JwtRoleClaim); err != nil {
+		return nil, err
+	}
+
+	switch cfg.JwtType {
+	case "HS256", "HS384", "HS512":
+		secret := cfg.JwtSecret
+		if secret == "" {
+			generated, err := randomSecret()
+			if err != nil {
+				return nil, err
+			}
+			log.Warn("JWT_SECRET is not set, using a random secret: tokens will not survive restarts")
+			secret = generated
+		}
+		m.method = jwt.GetSigningMethod(cfg.JwtType)
+		m.signKey = []byte(secret)
+		m.verifyKey = []byte(secret)
+	case "RS256", "RS384", "RS512", "ES256", "ES384", "ES512":
+		signKey, verifyKey, err := parseKeyPair(cfg.JwtType, cfg.JwtPrivateKey, cfg.JwtPublicKey)
+		if err != nil {
+			return nil, err
+		}
+		jwk, err := publicJWK(cfg.JwtType, verifyKey)
+		if err != nil {
+			return nil, err
+		}
+		m.method = jwt.GetSigningMethod(cfg.JwtType)
+		m.signKey = signKey
+		m.verifyKey = verifyKey
+		m.keyID = jwk.Kid
+		m.jwks = append(m.jwks, jwk)
+	default:
+		return nil, fmt.Errorf("unsupported JWT_TYPE: %s", cfg.JwtType)
+	}
+
+	extra, err := parseJWK(cfg.JWK)
+	if err != nil {
+		return nil, err
+	}
+	for _, jwk := range extra {
+		if jwk.Kid != m.keyID {
+			m.jwks = append(m.jwks, jwk)
+		}
+	}
+
+	return m, nil
+}
+
+// Algorithm returns the JWT algorithm used to sign tokens
+func (m *Manager) Algorithm() string {
+	return m.method.Alg()
+}
+
+// IssuesIDTokens reports whether ID tokens can be issued. Clients verify them
+// with the JWKS, which cannot publish the HMAC secret of the HS algorithms.
+func (m *Manager) IssuesIDTokens() bool {
+	_, hmac := m.method.(*jwt.SigningMethodHMAC)
+	return !hmac
+}
+
+// CreateAuthTokens issues an access and a refresh token for the user
+func (m *Manager) CreateAuthTokens(user *models.User) (*AuthTokens, error) {
+	return m.CreateAuthTokensWithGrant(user, Grant{})
+}
+
+// CreateAuthTokensWithGrant issues an access and a refresh token for the
+// user, limited to the roles and scope of the grant
+func (m *Manager) CreateAuthTokensWithGrant(user *models.User, grant Grant) (*AuthTokens, error) {
+	now := time.Now()
+
+	accessToken, err := m.sign(m.claims(user, grant, TypeAccessToken, now, m.cfg.AccessTokenExpiryTime))
+	if err != nil {
+		return nil, err
+	}
+	refreshToken, err := m.sign(m.claims(user, grant, TypeRefreshToken, now, m.cfg.RefreshTokenExpiryTime))
+	if err != nil {
+		return nil, err
+	}
+
+	return &AuthTokens{
+		AccessToken:  accessToken,
+		ExpiresIn:    int64(m.cfg.AccessTokenExpiryTime.Seconds()),
+		RefreshToken: refreshToken,
+	}, nil
+}
+
+// ParseToken verifies the signature, issuer and expiry of the token and
+// checks its token_type
+func (m *Manager) ParseToken(tokenString, tokenType string) (*Claims, error) {
+	claims := &Claims{roleClaim: m.cfg.JwtRoleClaim}
+	_, err := jwt.ParseWithClaims(tokenString, claims, func(t *jwt.Token) (interface{}, error) {
+		return m.verifyKey, nil
+	},
+		jwt.WithValidMethods([]string{m.method.Alg()}),
+		jwt.WithIssuer(m.cfg.AuthorizerURL),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+	if claims.TokenType != tokenType {
+		return nil, fmt.Errorf("%w: expected %s, got %s", ErrInvalidToken, tokenType, claims.TokenType)
+	}
+	return claims, nil
+}
+
+func (m *Manager) claims(user *models.User, grant Grant, tokenType string, now time.Time, ttl time.Duration) *Claims {
+	roles := grant.Roles
+	if roles == nil {
+		roles = user.RoleList()
+	}
+
+	return &Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.New().String(),
+			Issuer:    m.cfg.AuthorizerURL,
+			Subject:   user.ID,
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+		},
+		TokenType: tokenType,
+		Email:     user.Email,
+		Roles:     roles,
+		Scope:     strings.Join(grant.Scope, " "),
+		SessionID: grant.SessionID,
+		ClientID:  grant.ClientID,
+		roleClaim: m.cfg.JwtRoleClaim,
+	}
+}
+
+func (m *Manager) sign(claims jwt.Claims) (string, error) {
+	t := jwt.NewWithClaims(m.method, claims)
+	if m.keyID != "" {
+		t.Header["kid"] = m.keyID
+	}
+	return t.SignedString(m.signKey)
+}
+
+func randomSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
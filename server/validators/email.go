@@ -0,0 +1,21 @@
+package validators
+
+import (
+	"net/mail"
+	"strings"
+)
+
+// IsValidEmail reports whether email is a bare, syntactically valid address
+// (no display name or angle brackets)
+func IsValidEmail(email string) bool {
+	addr, err := mail.ParseAddress(email)
+	if err != nil {
+		return false
+	}
+	return addr.Address == email
+}
+
+// NormalizeEmail trims and lowercases the email so lookups are case insensitive
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
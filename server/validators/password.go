@@ -0,0 +1,126 @@
+package validators
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"server/config"
+)
+
+// Password rules reported by PasswordPolicy.Validate
+const (
+	PasswordRuleMinLength = "min_length"
+	PasswordRuleMaxLength = "max_length"
+	PasswordRuleLowercase = "lowercase"
+	PasswordRuleUppercase = "uppercase"
+	PasswordRuleDigit     = "digit"
+	PasswordRuleSpecial   = "special"
+	PasswordRuleNotEmail  = "not_email"
+	PasswordRuleNotName   = "not_name"
+)
+
+// bcryptMaxLength is the number of bytes bcrypt can hash, longer passwords
+// are always rejected whatever the policy
+const bcryptMaxLength = 72
+
+// PasswordPolicy describes the rules a password must follow
+type PasswordPolicy struct {
+	// Disabled turns every rule off except the hashing limits
+	Disabled         bool
+	MinLength        int
+	MaxLength        int
+	RequireLowercase bool
+	RequireUppercase bool
+	RequireDigit     bool
+	RequireSpecial   bool
+}
+
+// PasswordViolation is a rule the password does not follow
+type PasswordViolation struct {
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// NewPasswordPolicy returns the policy configured through the PASSWORD_* and
+// DISABLE_STRONG_PASSWORD variables
+func NewPasswordPolicy(cfg *config.Config) PasswordPolicy {
+	return PasswordPolicy{
+		Disabled:         cfg.DisableStrongPassword,
+		MinLength:        cfg.PasswordMinLength,
+		MaxLength:        cfg.PasswordMaxLength,
+		RequireLowercase: cfg.PasswordRequireLowercase,
+		RequireUppercase: cfg.PasswordRequireUppercase,
+		RequireDigit:     cfg.PasswordRequireDigit,
+		RequireSpecial:   cfg.PasswordRequireSpecial,
+	}
+}
+
+// Validate returns every rule the password breaks, nil when it is accepted.
+// The email and name of the user are used to reject trivial passwords.
+func (p PasswordPolicy) Validate(password, email, name string) []PasswordViolation {
+	var violations []PasswordViolation
+	fail := func(rule, format string, args ...interface{}) {
+		violations = append(violations, PasswordViolation{Rule: rule, Message: fmt.Sprintf(format, args...)})
+	}
+
+	maxLength := p.MaxLength
+	if maxLength <= 0 || maxLength > bcryptMaxLength {
+		maxLength = bcryptMaxLength
+	}
+	if len(password) > maxLength {
+		// bcrypt limits bytes, so characters outside ASCII count several times
+		fail(PasswordRuleMaxLength, "password must be at most %d bytes long", maxLength)
+	}
+
+	if p.Disabled {
+		if password == "" {
+			fail(PasswordRuleMinLength, "password is required")
+		}
+		return violations
+	}
+
+	if utf8.RuneCountInString(password) < p.MinLength || password == "" {
+		fail(PasswordRuleMinLength, "password must be at least %d characters long", p.MinLength)
+	}
+
+	var hasLower, hasUpper, hasDigit, hasSpecial bool
+	for _, c := range password {
+		switch {
+		case unicode.IsLower(c):
+			hasLower = true
+		case unicode.IsUpper(c):
+			hasUpper = true
+		case unicode.IsDigit(c):
+			hasDigit = true
+		case unicode.IsPunct(c) || unicode.IsSymbol(c) || unicode.IsSpace(c):
+			hasSpecial = true
+		}
+	}
+	if p.RequireLowercase && !hasLower {
+		fail(PasswordRuleLowercase, "password must contain a lowercase letter")
+	}
+	if p.RequireUppercase && !hasUpper {
+		fail(PasswordRuleUppercase, "password must contain an uppercase letter")
+	}
+	if p.RequireDigit && !hasDigit {
+		fail(PasswordRuleDigit, "password must contain a digit")
+	}
+	if p.RequireSpecial && !hasSpecial {
+		fail(PasswordRuleSpecial, "password must contain a special character")
+	}
+
+	normalized := strings.ToLower(strings.TrimSpace(password))
+	if email = strings.ToLower(strings.TrimSpace(email)); email != "" {
+		local, _, _ := strings.Cut(email, "@")
+		if normalized == email || normalized == local {
+			fail(PasswordRuleNotEmail, "password must not be your email address")
+		}
+	}
+	if name = strings.ToLower(strings.TrimSpace(name)); name != "" && normalized == name {
+		fail(PasswordRuleNotName, "password must not be your name")
+	}
+
+	return violations
+}
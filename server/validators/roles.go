@@ -0,0 +1,18 @@
+package validators
+
+import (
+	"slices"
+
+	"server/config"
+)
+
+// UnknownRole returns the first role that is not one of ROLES, "" when they
+// all are
+func UnknownRole(cfg *config.Config, roles []string) string {
+	for _, role := range roles {
+		if !slices.Contains(cfg.Roles, role) {
+			return role
+		}
+	}
+	return ""
+}
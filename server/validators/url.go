@@ -0,0 +1,29 @@
+package validators
+
+import (
+	"net/url"
+	"strings"
+
+	"server/config"
+)
+
+// IsAllowedRedirectURI reports whether uri is an absolute http(s) URL whose
+// origin is APP_URL, AUTHORIZER_URL or one of ALLOWED_ORIGINS
+func IsAllowedRedirectURI(cfg *config.Config, uri string) bool {
+	u, err := url.Parse(uri)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return false
+	}
+	origin := strings.ToLower(u.Scheme + "://" + u.Host)
+
+	allowed := append([]string{cfg.AppURL, cfg.AuthorizerURL}, cfg.AllowedOrigins...)
+	for _, o := range allowed {
+		if o == "*" {
+			return true
+		}
+		if a, err := url.Parse(o); err == nil && strings.ToLower(a.Scheme+"://"+a.Host) == origin {
+			return true
+		}
+	}
+	return false
+}